@@ -294,6 +294,34 @@ func TokenUnmarshal(token string, key string, v interface{}) error {
 	return json.NewDecoder(bytes.NewBuffer(msg)).Decode(&v)
 }
 
+//Encrypt encrypts data with the fernet key, the token never expires.
+func Encrypt(data []byte, key string) ([]byte, error) {
+	k, err := fernet.DecodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return fernet.EncryptAndSign(data, k)
+}
+
+//Decrypt decrypts a token encrypted by one of the fernet keys, so the old keys still decrypt the tokens after
+//the key rotated.
+func Decrypt(token []byte, keys ...string) ([]byte, error) {
+	ks := []*fernet.Key{}
+	for _, key := range keys {
+		k, err := fernet.DecodeKey(key)
+		if err != nil {
+			return nil, err
+		}
+		ks = append(ks, k)
+	}
+
+	msg := fernet.VerifyAndDecrypt(token, 0, ks)
+	if msg == nil {
+		return nil, errors.New("invalid token or key")
+	}
+	return msg, nil
+}
+
 //GetFileSize get the size(bytes) of file.
 func GetFileSize(path string) (int64, error) {
 	if file, err := os.Open(path); err != nil {
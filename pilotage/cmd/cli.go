@@ -17,8 +17,12 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"strconv"
 
 	. "github.com/logrusorgru/aurora"
 	"github.com/spf13/cobra"
@@ -48,14 +52,50 @@ var runCliCmd = &cobra.Command{
 	Run:   runCliFlow,
 }
 
+var validateCliCmd = &cobra.Command{
+	Use:   "validate <flow file>",
+	Short: "Validate a orchestration flow file.",
+	Long: `Validate the orchestration flow file and print all the invalid fields with their line numbers.
+The JSON Schema of the flow file is pilotage/docs/flow.schema.json.`,
+	Run: validateCliFlow,
+}
+
+var cancelCliCmd = &cobra.Command{
+	Use:   "cancel <namespace/repository/flow> <tag> <number>",
+	Short: "Cancel a running flow of pilotage daemon.",
+	Long:  ``,
+	Run:   cancelCliFlow,
+}
+
+var logsCliCmd = &cobra.Command{
+	Use:   "logs <namespace/repository/flow> <tag> <number> [stage[.action[.job]]]",
+	Short: "Print the logs of a running flow of pilotage daemon.",
+	Long: `Print the logs of a running flow, or a stage, action or job of it. With the follow flag,
+it keeps printing the new logs until the flow is finished.`,
+	Run: logsCliFlow,
+}
+
+var followOption bool
+
 // init()
 func init() {
 	// Add cli sub command.
 	RootCmd.AddCommand(cliCmd)
 
+	cliCmd.PersistentFlags().StringVarP(&serverOption, "server", "s", "", "The pilotage daemon address, default is from the web configurations.")
+
 	//Add run sub command to cli.
 	cliCmd.AddCommand(runCliCmd)
 
+	//Add validate sub command to cli.
+	cliCmd.AddCommand(validateCliCmd)
+
+	//Add cancel sub command to cli.
+	cliCmd.AddCommand(cancelCliCmd)
+
+	//Add logs sub command to cli.
+	logsCliCmd.Flags().BoolVarP(&followOption, "follow", "f", false, "Follow the logs until the flow is finished.")
+	cliCmd.AddCommand(logsCliCmd)
 }
 
 // Run orchestration flow from a flow definition file.
@@ -68,6 +108,13 @@ func runCliFlow(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	openLogSinks(cmd)
+	defer module.CloseLogSinks()
+
+	// The flow file is run by the user of CLI, so its jobs could run on the host.
+	module.RegisterHostExecutors(true, true)
+	module.HostPathVolumes = true
+
 	flow := new(module.Flow)
 
 	if err := flow.ParseFlowFromFile(args[0], module.CliRun, verbose, timestamp); err != nil {
@@ -75,6 +122,102 @@ func runCliFlow(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	flow.LocalRun(verbose, timestamp)
+	flow.LocalRun(context.Background(), verbose, timestamp)
+	flow.WaitDownstreams()
+}
+
+// Validate the flow definition file without running it.
+func validateCliFlow(cmd *cobra.Command, args []string) {
+	if len(args) <= 0 || utils.IsFileExist(args[0]) == false {
+		cmd.Println(Red("The orchestration flow file is required."))
+		os.Exit(1)
+	}
+
+	module.RegisterHostExecutors(true, true)
+	module.HostPathVolumes = true
+
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		cmd.Println(Red(fmt.Sprintf("Read orchestration flow file %s error: %s", args[0], err.Error())))
+		os.Exit(1)
+	}
+
+	flow := new(module.Flow)
+	if err := flow.ParseFlow(data); err != nil {
+		cmd.Println(Red(fmt.Sprintf("Unmarshal the flow file error: %s", err.Error())))
+		os.Exit(1)
+	}
+
+	if errs := flow.Validate(); len(errs) > 0 {
+		for _, e := range errs {
+			cmd.Println(Red(fmt.Sprintf("%s:%d: %s: %s", args[0], e.Line, e.Path, e.Message)))
+		}
+		os.Exit(1)
+	}
+
+	cmd.Println(Green(fmt.Sprintf("The orchestration flow file %s is valid.", args[0])))
+}
+
+// Cancel a running flow in the pilotage daemon.
+func cancelCliFlow(cmd *cobra.Command, args []string) {
+	if len(args) != 3 {
+		cmd.Println(Red("The flow URI, tag and number are required."))
+		os.Exit(1)
+	}
+
+	number, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		cmd.Println(Red(fmt.Sprintf("Invalid flow number: %s", args[2])))
+		os.Exit(1)
+	}
+
+	message, err := requestDaemon(http.MethodDelete, fmt.Sprintf("/flow/v1/%s/%s/%d", args[0], args[1], number), nil)
+	if err != nil {
+		cmd.Println(Red(fmt.Sprintf("Cancel flow error: %s", err.Error())))
+		os.Exit(1)
+	}
+
+	cmd.Println(Green(message))
+}
+
+// Print the logs of a running flow in the pilotage daemon.
+func logsCliFlow(cmd *cobra.Command, args []string) {
+	if len(args) != 3 && len(args) != 4 {
+		cmd.Println(Red("The flow URI, tag and number are required."))
+		os.Exit(1)
+	}
+
+	number, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		cmd.Println(Red(fmt.Sprintf("Invalid flow number: %s", args[2])))
+		os.Exit(1)
+	}
 
+	path := fmt.Sprintf("/flow/v1/%s/%s/%d/logs", args[0], args[1], number)
+	if len(args) == 4 {
+		path = fmt.Sprintf("%s/%s", path, args[3])
+	}
+	if !followOption {
+		path = fmt.Sprintf("%s?follow=false", path)
+	}
+
+	status, err := streamDaemon(path, func(line module.LogLine) {
+		if line.Job != "" && len(args) != 4 {
+			cmd.Println(fmt.Sprintf("[%s] %s", line.Job, line.Line))
+		} else {
+			cmd.Println(line.Line)
+		}
+	})
+	if err != nil {
+		cmd.Println(Red(fmt.Sprintf("Get flow logs error: %s", err.Error())))
+		os.Exit(1)
+	}
+
+	if followOption {
+		if status != module.Success {
+			cmd.Println(Red(fmt.Sprintf("Flow status: %s", status)))
+			os.Exit(1)
+		}
+		cmd.Println(Green(fmt.Sprintf("Flow status: %s", status)))
+	}
 }
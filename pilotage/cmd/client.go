@@ -0,0 +1,168 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Huawei/containerops/common"
+	"github.com/Huawei/containerops/pilotage/module"
+)
+
+var serverOption string
+
+// daemonURL returns the address of pilotage daemon from the server flag or the web configurations.
+func daemonURL() string {
+	if serverOption != "" {
+		return strings.TrimSuffix(serverOption, "/")
+	}
+
+	switch common.Web.Mode {
+	case "unix":
+		// The host is ignored by the Unix Socket transport.
+		return "http://pilotage"
+	case "https":
+		return fmt.Sprintf("https://%s:%d", daemonAddress(), common.Web.Port)
+	default:
+		port := common.Web.Port
+		if port == 0 {
+			port = 8080
+		}
+		return fmt.Sprintf("http://%s:%d", daemonAddress(), port)
+	}
+}
+
+func daemonAddress() string {
+	if common.Web.Address == "" || common.Web.Address == "0.0.0.0" {
+		return "127.0.0.1"
+	}
+	return common.Web.Address
+}
+
+// daemonClient returns the HTTP client of pilotage daemon, it dials the socket file in the unix mode.
+func daemonClient() *http.Client {
+	if serverOption == "" && common.Web.Mode == "unix" {
+		return &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", common.Web.Address)
+				},
+			},
+		}
+	}
+
+	return &http.Client{}
+}
+
+// requestDaemon sends a request to the pilotage daemon, and returns the message of response.
+func requestDaemon(method, path string, body io.Reader) (string, error) {
+	req, err := http.NewRequest(method, fmt.Sprintf("%s%s", daemonURL(), path), body)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := daemonClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	message := map[string]string{}
+	if err := json.Unmarshal(data, &message); err != nil || message["message"] == "" {
+		message["message"] = string(data)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", fmt.Errorf("%s", message["message"])
+	}
+
+	return message["message"], nil
+}
+
+// streamDaemon reads the Server-Sent Events of a log stream from the pilotage daemon, hands every log line to
+// the print function, and returns the status of flow in the end event.
+func streamDaemon(path string, print func(line module.LogLine)) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s%s", daemonURL(), path), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := daemonClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		data, _ := ioutil.ReadAll(resp.Body)
+		message := map[string]string{}
+		if err := json.Unmarshal(data, &message); err != nil || message["message"] == "" {
+			message["message"] = string(data)
+		}
+		return "", fmt.Errorf("%s", message["message"])
+	}
+
+	event, data := "", ""
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		switch {
+		case strings.HasPrefix(text, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(text, "event:"))
+		case strings.HasPrefix(text, "data:"):
+			data = strings.TrimSpace(strings.TrimPrefix(text, "data:"))
+		case text == "":
+			// A blank line dispatches the event.
+			message := map[string]string{}
+			switch event {
+			case "log":
+				line := module.LogLine{}
+				if err := json.Unmarshal([]byte(data), &line); err != nil {
+					return "", err
+				}
+				print(line)
+			case "end":
+				json.Unmarshal([]byte(data), &message)
+				return message["status"], nil
+			case "error":
+				json.Unmarshal([]byte(data), &message)
+				return "", fmt.Errorf("%s", message["message"])
+			}
+			event, data = "", ""
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("The log stream is closed without end")
+}
@@ -33,8 +33,10 @@ import (
 
 	"github.com/Huawei/containerops/common"
 	"github.com/Huawei/containerops/common/utils"
+	"github.com/Huawei/containerops/pilotage/config"
 	"github.com/Huawei/containerops/pilotage/middleware"
 	"github.com/Huawei/containerops/pilotage/model"
+	"github.com/Huawei/containerops/pilotage/module"
 	"github.com/Huawei/containerops/pilotage/router"
 )
 
@@ -71,6 +73,17 @@ var startDaemonCmd = &cobra.Command{
 	Run:   startDaemonFlow,
 }
 
+var gcDaemonCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Delete the finished and orphaned job pods in Kubernetes",
+	Long: `Delete the job pods labelled by pilotage in all the namespaces of Kubernetes. The pods finished
+before the TTL and the pods orphaned by the finished runs are deleted with their secrets.
+The daemon runs it every gc_interval seconds of [pilotage.kubernetes].`,
+	Run: gcDaemonPods,
+}
+
+var podTTLOption int64
+
 // init()
 func init() {
 	// Add cli daemon sub command.
@@ -91,12 +104,18 @@ func init() {
 	daemonCmd.AddCommand(runDaemonCmd)
 
 	daemonCmd.AddCommand(startDaemonCmd)
+
+	gcDaemonCmd.Flags().Int64Var(&podTTLOption, "ttl", -1, "Seconds keeping the finished pods, default is the pod_ttl of [pilotage.kubernetes].")
+	daemonCmd.AddCommand(gcDaemonCmd)
 }
 
 // runDaemonFlow is
 func runDaemonFlow(cmd *cobra.Command, args []string) {
 	model.OpenDatabase(&common.Database)
 	model.Migrate()
+	startLogRetention()
+	openLogSinks(cmd)
+	defer module.CloseLogSinks()
 
 	if len(args) <= 0 || utils.IsFileExist(args[0]) == false {
 		cmd.Println(Red("The orchestration flow file is required."))
@@ -175,11 +194,32 @@ func runDaemonFlow(cmd *cobra.Command, args []string) {
 func startDaemonFlow(cmd *cobra.Command, args []string) {
 	model.OpenDatabase(&common.Database)
 	model.Migrate()
+	startLogRetention()
+	openLogSinks(cmd)
+	defer module.CloseLogSinks()
 
 	m := macaron.New()
 	middleware.SetStartDaemonMiddlewares(m, cfgFile)
 	router.SetStartDaemonRouters(m)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The jobs of daemon run on its host only by the executors enabled in the configuration.
+	module.RegisterHostExecutors(config.Pilotage.Daemon.LocalExecutor, config.Pilotage.Daemon.DockerExecutor)
+	module.HostPathVolumes = config.Pilotage.Daemon.HostPath
+
+	// The flows of daemon run by the queue, the runs left by the last daemon are recovered.
+	module.StartRunQueue(ctx, config.Pilotage.Queue.Concurrency, config.Pilotage.Queue.FlowConcurrency)
+
+	// The finished and orphaned job pods in Kubernetes are deleted.
+	startPodCollector(ctx)
+
+	// The schedule triggers of the flow files run in the daemon.
+	if config.WebHook.FlowBaseDir != "" {
+		module.StartScheduler(ctx, config.WebHook.FlowBaseDir)
+	}
+
 	var server *http.Server
 
 	stopChan := make(chan os.Signal)
@@ -273,3 +313,53 @@ func overrideConfig() {
 	}
 
 }
+
+// startLogRetention purges the logs in database with the retention of `[pilotage.log]` configurations.
+func startLogRetention() {
+	logConfig := config.Pilotage.Log
+
+	interval := time.Duration(logConfig.PurgeInterval) * time.Hour
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	module.StartLogRetention(context.Background(), time.Duration(logConfig.Retention)*24*time.Hour,
+		time.Duration(logConfig.DebugRetention)*24*time.Hour, interval)
+}
+
+// startPodCollector deletes the job pods in Kubernetes with the `[pilotage.kubernetes]` configurations.
+func startPodCollector(ctx context.Context) {
+	kubernetesConfig := config.Pilotage.Kubernetes
+
+	interval := time.Duration(kubernetesConfig.GCInterval) * time.Second
+	if kubernetesConfig.GCInterval == 0 {
+		interval = 300 * time.Second
+	}
+
+	module.StartPodCollector(ctx, time.Duration(kubernetesConfig.PodTTL)*time.Second, interval)
+}
+
+// gcDaemonPods deletes the finished and orphaned job pods once, the runs finished are found in the database.
+func gcDaemonPods(cmd *cobra.Command, args []string) {
+	model.OpenDatabase(&common.Database)
+
+	ttl := config.Pilotage.Kubernetes.PodTTL
+	if podTTLOption >= 0 {
+		ttl = podTTLOption
+	}
+
+	count, err := module.CollectPods(time.Duration(ttl) * time.Second)
+	if err != nil {
+		cmd.Println(Red(fmt.Sprintf("Delete job pods error: %s", err.Error())))
+		os.Exit(1)
+	}
+	cmd.Println(Green(fmt.Sprintf("Deleted %d job pods", count)))
+}
+
+// openLogSinks opens the log sinks of `[[pilotage.sinks]]` configurations.
+func openLogSinks(cmd *cobra.Command) {
+	if err := module.OpenLogSinks(config.Pilotage.Sinks); err != nil {
+		cmd.Println(Red(fmt.Sprintf("Open log sinks error: %s", err.Error())))
+		os.Exit(1)
+	}
+}
@@ -15,7 +15,76 @@ type WebHookConfig struct {
 	FlowBaseDir string `json:"flowBaseDir"` // Temporary, engine will find flow in database in the future.
 }
 
+// PilotageConfig is the `[pilotage]` section of configuration file.
+type PilotageConfig struct {
+	Log        LogConfig        `json:"log"`
+	Sinks      []SinkConfig     `json:"sinks"`
+	Queue      QueueConfig      `json:"queue"`
+	Secret     SecretConfig     `json:"secret"`
+	Artifact   ArtifactConfig   `json:"artifact"`
+	Kubernetes KubernetesConfig `json:"kubernetes"`
+	Daemon     DaemonConfig     `json:"daemon"`
+}
+
+// DaemonConfig is the executors of daemon running the jobs on its host and the host_path volumes of pods, they're
+// disabled by default because anyone posting a flow could run commands on the hosts by them. The CLI running the
+// flow files enables them.
+type DaemonConfig struct {
+	LocalExecutor  bool `json:"local_executor"`  // Runs the endpoints of local jobs as the shell commands of host.
+	DockerExecutor bool `json:"docker_executor"` // Runs the docker jobs in the Docker of host.
+	HostPath       bool `json:"host_path"`       // Mounts the host_path volumes of Kubernetes jobs, the paths of nodes.
+}
+
+// KubernetesConfig is the garbage collection of the job pods in Kubernetes, the finished pods are deleted after
+// the TTL and the pods of the finished runs are deleted as orphans.
+type KubernetesConfig struct {
+	PodTTL     int64 `json:"pod_ttl"`     // Seconds keeping the finished pods for debugging, default is 0.
+	GCInterval int64 `json:"gc_interval"` // Seconds between the collections of daemon, default is 300, negative disables it.
+}
+
+// ArtifactConfig is the Dockyard archiving the artifacts of jobs, they're uploaded to its binary repository.
+type ArtifactConfig struct {
+	Dockyard string `json:"dockyard"` // The domain or URL of Dockyard, the domain is accessed by HTTPS.
+	Image    string `json:"image"`    // The image with tar and curl uploading the artifacts, default is curlimages/curl.
+}
+
+// SecretConfig is the fernet keys encrypting the secrets in database. The first key encrypts the secrets, and all
+// of them decrypt, so a new key is prepended when the key rotates.
+type SecretConfig struct {
+	Keys []string `json:"keys"`
+}
+
+// QueueConfig is the concurrency limits of the flow runs in daemon, zero means unlimited.
+type QueueConfig struct {
+	Concurrency     int `json:"concurrency"`      // The max running flows of daemon.
+	FlowConcurrency int `json:"flow_concurrency"` // The max running runs of a flow, the concurrency of flow overrides it.
+}
+
+// LogConfig is the retention of logs in database, the zero retention keeps the logs forever.
+type LogConfig struct {
+	Retention      int64 `json:"retention"`       // Days keeping the logs.
+	DebugRetention int64 `json:"debug_retention"` // Days keeping the DEBUG logs, it's usually shorter than retention.
+	PurgeInterval  int64 `json:"purge_interval"`  // Hours between purging the logs, default is 24.
+}
+
+// SinkConfig is a `[[pilotage.sinks]]` sending the logs to the file, Elasticsearch or Loki, the options depend on the type.
+type SinkConfig struct {
+	Type          string            `json:"type"`           // file, elasticsearch or loki.
+	Path          string            `json:"path"`           // The log file path of file sink.
+	MaxSize       int64             `json:"max_size"`       // Megabytes of the log file before rotating, default is 100.
+	MaxBackups    int               `json:"max_backups"`    // The count of rotated log files to keep, default is 5.
+	URL           string            `json:"url"`            // The address of Elasticsearch or Loki.
+	Index         string            `json:"index"`          // The Elasticsearch index, default is pilotage.
+	Tenant        string            `json:"tenant"`         // The X-Scope-OrgID tenant of Loki.
+	Labels        map[string]string `json:"labels"`         // The labels of Loki streams besides flow and level.
+	Username      string            `json:"username"`       // The basic auth user of Elasticsearch or Loki.
+	Password      string            `json:"password"`       // The basic auth password of Elasticsearch or Loki.
+	BatchSize     int               `json:"batch_size"`     // The max logs of a write, default is 500.
+	FlushInterval int64             `json:"flush_interval"` // Seconds between writes of an incomplete batch, default is 1.
+}
+
 var WebHook WebHookConfig
+var Pilotage PilotageConfig
 
 func InitConfig(cfgFile string) error {
 	viper.SetConfigFile(cfgFile)
@@ -29,5 +98,14 @@ func InitConfig(cfgFile string) error {
 		return err
 	}
 
-	return json.Unmarshal(bs, &WebHook)
+	if err := json.Unmarshal(bs, &WebHook); err != nil {
+		return err
+	}
+
+	pilotageMap := viper.GetStringMap("pilotage")
+	if bs, err = json.Marshal(pilotageMap); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(bs, &Pilotage)
 }
@@ -0,0 +1,395 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// RunResponse is the result and duration in seconds of a flow, stage, action or job run.
+type RunResponse struct {
+	ID       int64     `json:"id,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	Number   int64     `json:"number,omitempty"`
+	Status   string    `json:"status"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration float64   `json:"duration"`
+}
+
+type JobRunResponse struct {
+	RunResponse
+	JobID     int64             `json:"job_id"`
+	ExitCode  int64             `json:"exit_code"`
+	Reason    string            `json:"reason,omitempty"`
+	Artifacts map[string]string `json:"artifacts,omitempty"`
+	Attempts  []model.AttemptV1 `json:"attempts,omitempty"`
+}
+
+type ActionRunResponse struct {
+	RunResponse
+	Jobs []JobRunResponse `json:"jobs"`
+}
+
+type StageRunResponse struct {
+	RunResponse
+	Type    string              `json:"type"`
+	Actions []ActionRunResponse `json:"actions"`
+}
+
+// RunLinkResponse is the parent or child run of a flow run, the path is the stage or action of parent
+// triggering the child.
+type RunLinkResponse struct {
+	URI    string `json:"uri"`
+	Tag    string `json:"tag"`
+	Number int64  `json:"number"`
+	Status string `json:"status,omitempty"`
+	Path   string `json:"path"`
+}
+
+type FlowRunResponse struct {
+	RunResponse
+	Parent   *RunLinkResponse   `json:"parent,omitempty"`
+	Children []RunLinkResponse  `json:"children,omitempty"`
+	Stages   []StageRunResponse `json:"stages,omitempty"`
+}
+
+func newRunResponse(id int64, name string, number int64, result string, start, end time.Time) RunResponse {
+	return RunResponse{ID: id, Name: name, Number: number, Status: result, Start: start, End: end,
+		Duration: end.Sub(start).Seconds()}
+}
+
+// pagination returns the offset and limit from the page and per_page query, the page starts from 1.
+func pagination(ctx *macaron.Context) (page, perPage int) {
+	page, perPage = ctx.QueryInt("page"), ctx.QueryInt("per_page")
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = defaultPerPage
+	} else if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	return page, perPage
+}
+
+// GetFlows returns the flows of a namespace or repository.
+func GetFlows(ctx *macaron.Context) (int, []byte) {
+	page, perPage := pagination(ctx)
+
+	flows, total, err := new(model.FlowV1).List(ctx.Params("namespace"), ctx.Params("repository"), (page-1)*perPage, perPage)
+	if err != nil {
+		result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("List flows error: %s", err.Error())})
+		return http.StatusInternalServerError, result
+	}
+
+	result, _ := json.Marshal(map[string]interface{}{"total": total, "page": page, "per_page": perPage, "flows": flows})
+	return http.StatusOK, result
+}
+
+// GetFlowRuns returns the runs of flow, filtered by the status and the start time between since and until in RFC3339.
+func GetFlowRuns(ctx *macaron.Context) (int, []byte) {
+	flow, status, result := getFlow(ctx)
+	if flow == nil {
+		return status, result
+	}
+
+	page, perPage := pagination(ctx)
+	filter := model.RunFilter{Result: ctx.Query("status"), Offset: (page - 1) * perPage, Limit: perPage}
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := ctx.Query(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("Invalid %s time: %s", name, value)})
+				return http.StatusBadRequest, result
+			}
+			*t = parsed
+		}
+	}
+
+	runs, total, err := new(model.FlowDataV1).List(flow.ID, filter)
+	if err != nil {
+		result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("List flow runs error: %s", err.Error())})
+		return http.StatusInternalServerError, result
+	}
+
+	flows, responses := map[int64]*model.FlowV1{}, []FlowRunResponse{}
+	for _, run := range runs {
+		responses = append(responses, FlowRunResponse{Parent: parentRun(run, flows),
+			RunResponse: newRunResponse(0, "", run.Number, run.Result, run.Start, run.End)})
+	}
+
+	result, _ = json.Marshal(map[string]interface{}{"total": total, "page": page, "per_page": perPage, "runs": responses})
+	return http.StatusOK, result
+}
+
+// GetFlowRun returns a run of flow with the results of its stages, actions and jobs.
+func GetFlowRun(ctx *macaron.Context) (int, []byte) {
+	flow, status, result := getFlow(ctx)
+	if flow == nil {
+		return status, result
+	}
+
+	run, status, result := getFlowRun(ctx, flow)
+	if run == nil {
+		return status, result
+	}
+
+	result, _ = json.Marshal(run)
+	return http.StatusOK, result
+}
+
+// GetFlowJobLog returns the logs of a job in the run of flow, the job is the id of job run.
+func GetFlowJobLog(ctx *macaron.Context) (int, []byte) {
+	flow, status, result := getFlow(ctx)
+	if flow == nil {
+		return status, result
+	}
+
+	run, status, result := getFlowRun(ctx, flow)
+	if run == nil {
+		return status, result
+	}
+
+	id, err := strconv.ParseInt(ctx.Params("job"), 10, 64)
+	if err != nil {
+		result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("Invalid job: %s", ctx.Params("job"))})
+		return http.StatusBadRequest, result
+	}
+
+	for _, stage := range run.Stages {
+		for _, action := range stage.Actions {
+			for _, job := range action.Jobs {
+				if job.ID != id {
+					continue
+				}
+
+				page, perPage := pagination(ctx)
+				logs, err := new(model.LogV1).List(model.JOB, job.JobID, run.Number, (page-1)*perPage, perPage)
+				if err != nil {
+					result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("List job logs error: %s", err.Error())})
+					return http.StatusInternalServerError, result
+				}
+
+				result, _ := json.Marshal(map[string]interface{}{"page": page, "per_page": perPage, "logs": logs})
+				return http.StatusOK, result
+			}
+		}
+	}
+
+	result, _ = json.Marshal(map[string]string{"message": fmt.Sprintf("Job [%d] is not in the flow run", id)})
+	return http.StatusNotFound, result
+}
+
+func getFlow(ctx *macaron.Context) (*model.FlowV1, int, []byte) {
+	namespace, repository, name, tag := ctx.Params("namespace"), ctx.Params("repository"), ctx.Params("flow"), ctx.Params("tag")
+
+	flow := new(model.FlowV1)
+	if err := flow.Get(namespace, repository, name, tag); err != nil {
+		result, _ := json.Marshal(map[string]string{
+			"message": fmt.Sprintf("Get flow [%s/%s/%s:%s] error: %s", namespace, repository, name, tag, err.Error())})
+		return nil, http.StatusNotFound, result
+	}
+
+	return flow, http.StatusOK, nil
+}
+
+func getFlowRun(ctx *macaron.Context, flow *model.FlowV1) (*FlowRunResponse, int, []byte) {
+	number, err := strconv.ParseInt(ctx.Params("number"), 10, 64)
+	if err != nil {
+		result, _ := json.Marshal(map[string]string{
+			"message": fmt.Sprintf("Invalid flow number: %s", ctx.Params("number"))})
+		return nil, http.StatusBadRequest, result
+	}
+
+	flowData := new(model.FlowDataV1)
+	if err := flowData.Get(flow.ID, number); err != nil {
+		result, _ := json.Marshal(map[string]string{
+			"message": fmt.Sprintf("Get flow run [%d] error: %s", number, err.Error())})
+		return nil, http.StatusNotFound, result
+	}
+
+	run, err := flowRun(flow.ID, flowData)
+	if err != nil {
+		result, _ := json.Marshal(map[string]string{
+			"message": fmt.Sprintf("Get flow run [%d] error: %s", number, err.Error())})
+		return nil, http.StatusInternalServerError, result
+	}
+
+	return run, http.StatusOK, nil
+}
+
+// flowRun collects the data of stages, actions and jobs in the run of flow, the ones not run are omitted.
+func flowRun(flowID int64, flowData *model.FlowDataV1) (*FlowRunResponse, error) {
+	flows := map[int64]*model.FlowV1{}
+	run := &FlowRunResponse{Parent: parentRun(*flowData, flows),
+		RunResponse: newRunResponse(0, "", flowData.Number, flowData.Result, flowData.Start, flowData.End)}
+
+	children, err := new(model.FlowDataV1).ListChildren(flowID, flowData.Number)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		if flow := getFlowByID(child.FlowID, flows); flow != nil {
+			run.Children = append(run.Children, RunLinkResponse{URI: flowURI(flow), Tag: flow.Tag,
+				Number: child.Number, Status: child.Result, Path: child.ParentPath})
+		}
+	}
+
+	stages, err := new(model.StageV1).List(flowID)
+	if err != nil {
+		return nil, err
+	}
+	stageIDs := []int64{}
+	for _, stage := range stages {
+		stageIDs = append(stageIDs, stage.ID)
+	}
+	stageData, err := new(model.StageDataV1).ListByFlowNumber(stageIDs, flowData.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	actions, err := new(model.ActionV1).List(stageIDs)
+	if err != nil {
+		return nil, err
+	}
+	actionIDs := []int64{}
+	for _, action := range actions {
+		actionIDs = append(actionIDs, action.ID)
+	}
+	actionData, err := new(model.ActionDataV1).ListByFlowNumber(actionIDs, flowData.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := new(model.JobV1).List(actionIDs)
+	if err != nil {
+		return nil, err
+	}
+	jobIDs := []int64{}
+	for _, job := range jobs {
+		jobIDs = append(jobIDs, job.ID)
+	}
+	jobData, err := new(model.JobDataV1).ListByFlowNumber(jobIDs, flowData.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	stageRuns := map[int64]model.StageDataV1{}
+	for _, data := range stageData {
+		stageRuns[data.StageID] = data
+	}
+	actionRuns := map[int64]model.ActionDataV1{}
+	for _, data := range actionData {
+		actionRuns[data.ActionID] = data
+	}
+	jobRuns := map[int64]model.JobDataV1{}
+	for _, data := range jobData {
+		jobRuns[data.JobID] = data
+	}
+
+	for _, stage := range stages {
+		data, ok := stageRuns[stage.ID]
+		if !ok {
+			continue
+		}
+		stageRun := StageRunResponse{Type: stage.StageType, Actions: []ActionRunResponse{},
+			RunResponse: newRunResponse(data.ID, stage.Name, data.Number, data.Result, data.Start, data.End)}
+
+		for _, action := range actions {
+			data, ok := actionRuns[action.ID]
+			if action.StageID != stage.ID || !ok {
+				continue
+			}
+			actionRun := ActionRunResponse{Jobs: []JobRunResponse{},
+				RunResponse: newRunResponse(data.ID, action.Name, data.Number, data.Result, data.Start, data.End)}
+
+			for _, job := range jobs {
+				data, ok := jobRuns[job.ID]
+				if job.ActionID != action.ID || !ok {
+					continue
+				}
+				attempts, err := new(model.AttemptV1).List(data.ID)
+				if err != nil {
+					return nil, err
+				}
+				jobRun := JobRunResponse{JobID: job.ID, ExitCode: data.ExitCode, Reason: data.Reason, Attempts: attempts,
+					RunResponse: newRunResponse(data.ID, job.Name, data.Number, data.Result, data.Start, data.End)}
+				if data.Artifacts != "" {
+					if err := json.Unmarshal([]byte(data.Artifacts), &jobRun.Artifacts); err != nil {
+						return nil, err
+					}
+				}
+				actionRun.Jobs = append(actionRun.Jobs, jobRun)
+			}
+
+			stageRun.Actions = append(stageRun.Actions, actionRun)
+		}
+
+		run.Stages = append(run.Stages, stageRun)
+	}
+
+	return run, nil
+}
+
+// parentRun returns the run triggering the flow run, the flows are cached by id.
+func parentRun(run model.FlowDataV1, flows map[int64]*model.FlowV1) *RunLinkResponse {
+	if run.ParentFlowID == 0 {
+		return nil
+	}
+
+	flow := getFlowByID(run.ParentFlowID, flows)
+	if flow == nil {
+		return nil
+	}
+
+	parent := &RunLinkResponse{URI: flowURI(flow), Tag: flow.Tag, Number: run.ParentNumber, Path: run.ParentPath}
+	if data := new(model.FlowDataV1); data.Get(flow.ID, run.ParentNumber) == nil {
+		parent.Status = data.Result
+	}
+	return parent
+}
+
+func getFlowByID(id int64, flows map[int64]*model.FlowV1) *model.FlowV1 {
+	if flow, ok := flows[id]; ok {
+		return flow
+	}
+
+	flow := new(model.FlowV1)
+	if err := flow.GetByID(id); err != nil {
+		flow = nil
+	}
+	flows[id] = flow
+	return flow
+}
+
+func flowURI(flow *model.FlowV1) string {
+	return fmt.Sprintf("%s/%s/%s", flow.Namespace, flow.Repository, flow.Name)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/Huawei/containerops/pilotage/module"
+)
+
+// GetQueuedRuns returns the queued, pending and running runs of the daemon queue in the order of submission,
+// all the runs of daemon or the ones of a flow.
+func GetQueuedRuns(ctx *macaron.Context) (int, []byte) {
+	q := module.GetRunQueue()
+	if q == nil {
+		result, _ := json.Marshal(map[string]string{"message": "The run queue is not started"})
+		return http.StatusNotFound, result
+	}
+
+	uri := ""
+	if ctx.Params("flow") != "" {
+		uri = fmt.Sprintf("%s/%s/%s", ctx.Params("namespace"), ctx.Params("repository"), ctx.Params("flow"))
+	}
+
+	result, _ := json.Marshal(q.Runs(uri, ctx.Params("tag")))
+	return http.StatusOK, result
+}
+
+// DeleteQueuedRun removes a queued run before it starts.
+func DeleteQueuedRun(ctx *macaron.Context) (int, []byte) {
+	q := module.GetRunQueue()
+	if q == nil {
+		result, _ := json.Marshal(map[string]string{"message": "The run queue is not started"})
+		return http.StatusNotFound, result
+	}
+
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil {
+		result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("Invalid queued run: %s", ctx.Params("id"))})
+		return http.StatusBadRequest, result
+	}
+
+	if err := q.Cancel(id); err != nil {
+		result, _ := json.Marshal(map[string]string{"message": err.Error()})
+		return http.StatusConflict, result
+	}
+
+	result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("The queued run %d is removed", id)})
+	return http.StatusOK, result
+}
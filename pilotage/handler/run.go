@@ -20,12 +20,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"gopkg.in/macaron.v1"
-	"gopkg.in/yaml.v2"
 
-	"time"
 
+	"github.com/Huawei/containerops/pilotage/model"
 	"github.com/Huawei/containerops/pilotage/module"
 )
 
@@ -92,20 +92,20 @@ func PostFlowRuntime(ctx *macaron.Context) (int, []byte) {
 	data, _ := ctx.Req.Body().Bytes()
 
 
-	f := module.Flow{Number: 1, Status: module.Pending}
+	f := module.Flow{Model: module.DaemonStart, Number: 1, Status: module.Pending}
 	switch ctx.Params("type") {
 	case "json":
 		if err := json.Unmarshal(data, &f); err != nil {
 			info:=fmt.Sprintf("Unmarshal the flow file error: %s", err.Error())
-			f.Log(info, true, true)
+			f.LogLevel(model.ERROR, info, true, true)
 			result, _ := json.Marshal(map[string]string{"message": info})
 			return http.StatusBadRequest, result
 		}
 
 	case "yaml":
-		if err := yaml.Unmarshal(data, &f); err != nil {
+		if err := f.ParseFlow(data); err != nil {
 			info:=fmt.Sprintf("Unmarshal the flow file error: %s", err.Error())
-			f.Log(info, true, true)
+			f.LogLevel(model.ERROR, info, true, true)
 			result, _ := json.Marshal(map[string]string{"message": info})
 			return http.StatusBadRequest, result
 		}
@@ -115,19 +115,123 @@ func PostFlowRuntime(ctx *macaron.Context) (int, []byte) {
 		return http.StatusBadRequest, result
 	}
 
-	go func() {
-		f.LocalRun(true, true)
-	}()
-	// Sleep one second to wait the init status change of flow
-	time.Sleep(1 * time.Second)
-	resp := PostFlowResponse{Namespace: namespace, Repository: repository, Name: flowName, Tag: f.Tag,
-		Version: f.Version, Title: f.Title, Status: f.Status}
+	if errs := f.Validate(); len(errs) > 0 {
+		result, _ := json.Marshal(map[string]interface{}{
+			"message": "Validate the flow error", "errors": errs})
+		return http.StatusBadRequest, result
+	}
+
+	// The flow runs by the queue of daemon, the status is queued until the concurrency limits allow it to run.
+	run, err := module.Submit(&f)
+	if err != nil {
+		result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("Queue the flow error: %s", err.Error())})
+		return http.StatusInternalServerError, result
+	}
+	resp := PostFlowResponse{ID: strconv.FormatInt(run.ID, 10), Namespace: namespace, Repository: repository,
+		Name: flowName, Tag: f.Tag, Version: f.Version, Title: f.Title, Status: run.Status}
 	result, _ := json.Marshal(resp)
 	return http.StatusCreated, result
 }
 
-// GetFlowJobLog is return log of a Job
-func GetFlowJobLog(ctx *macaron.Context) (int, []byte) {
-	result, _ := json.Marshal(map[string]string{})
+// PostFlowResume resumes the paused stage of a running flow with the approver and comment.
+func PostFlowResume(ctx *macaron.Context) (int, []byte) {
+	uri := fmt.Sprintf("%s/%s/%s", ctx.Params("namespace"), ctx.Params("repository"), ctx.Params("flow"))
+	tag := ctx.Params("tag")
+
+	number, err := strconv.ParseInt(ctx.Params("number"), 10, 64)
+	if err != nil {
+		result, _ := json.Marshal(map[string]string{
+			"message": fmt.Sprintf("Invalid flow number: %s", ctx.Params("number"))})
+		return http.StatusBadRequest, result
+	}
+
+	f := module.GetRuntime(uri, tag, number)
+	if f == nil {
+		result, _ := json.Marshal(map[string]string{
+			"message": fmt.Sprintf("Flow [%s:%s] number [%d] is not running", uri, tag, number)})
+		return http.StatusNotFound, result
+	}
+
+	approval := module.Approval{}
+	if data, _ := ctx.Req.Body().Bytes(); len(data) > 0 {
+		if err := json.Unmarshal(data, &approval); err != nil {
+			result, _ := json.Marshal(map[string]string{
+				"message": fmt.Sprintf("Unmarshal the approval error: %s", err.Error())})
+			return http.StatusBadRequest, result
+		}
+	}
+
+	if err := f.Resume(approval); err != nil {
+		result, _ := json.Marshal(map[string]string{"message": err.Error()})
+		return http.StatusBadRequest, result
+	}
+
+	result, _ := json.Marshal(map[string]string{
+		"message": fmt.Sprintf("Flow [%s:%s] number [%d] is resumed", uri, tag, number)})
+	return http.StatusOK, result
+}
+
+// DeleteFlowRuntime cancels a running flow.
+func DeleteFlowRuntime(ctx *macaron.Context) (int, []byte) {
+	uri := fmt.Sprintf("%s/%s/%s", ctx.Params("namespace"), ctx.Params("repository"), ctx.Params("flow"))
+	tag := ctx.Params("tag")
+
+	number, err := strconv.ParseInt(ctx.Params("number"), 10, 64)
+	if err != nil {
+		result, _ := json.Marshal(map[string]string{
+			"message": fmt.Sprintf("Invalid flow number: %s", ctx.Params("number"))})
+		return http.StatusBadRequest, result
+	}
+
+	f := module.GetRuntime(uri, tag, number)
+	if f == nil {
+		result, _ := json.Marshal(map[string]string{
+			"message": fmt.Sprintf("Flow [%s:%s] number [%d] is not running", uri, tag, number)})
+		return http.StatusNotFound, result
+	}
+
+	if err := f.Cancel(); err != nil {
+		result, _ := json.Marshal(map[string]string{"message": err.Error()})
+		return http.StatusBadRequest, result
+	}
+
+	result, _ := json.Marshal(map[string]string{
+		"message": fmt.Sprintf("Flow [%s:%s] number [%d] is cancelled", uri, tag, number)})
+	return http.StatusOK, result
+}
+
+// GetFlowOutputs returns the job outputs of a flow run, which is running or finished.
+func GetFlowOutputs(ctx *macaron.Context) (int, []byte) {
+	namespace, repository, name := ctx.Params("namespace"), ctx.Params("repository"), ctx.Params("flow")
+	uri := fmt.Sprintf("%s/%s/%s", namespace, repository, name)
+	tag := ctx.Params("tag")
+
+	number, err := strconv.ParseInt(ctx.Params("number"), 10, 64)
+	if err != nil {
+		result, _ := json.Marshal(map[string]string{
+			"message": fmt.Sprintf("Invalid flow number: %s", ctx.Params("number"))})
+		return http.StatusBadRequest, result
+	}
+
+	if f := module.GetRuntime(uri, tag, number); f != nil {
+		result, _ := json.Marshal(f.GetOutputs().All())
+		return http.StatusOK, result
+	}
+
+	flow := new(model.FlowV1)
+	if err := flow.Get(namespace, repository, name, tag); err != nil {
+		result, _ := json.Marshal(map[string]string{
+			"message": fmt.Sprintf("Get flow [%s:%s] error: %s", uri, tag, err.Error())})
+		return http.StatusNotFound, result
+	}
+
+	outputs := module.NewRunOutputs(flow.ID, number)
+	if err := outputs.Load(); err != nil {
+		result, _ := json.Marshal(map[string]string{
+			"message": fmt.Sprintf("Get outputs of flow [%s:%s] number [%d] error: %s", uri, tag, number, err.Error())})
+		return http.StatusInternalServerError, result
+	}
+
+	result, _ := json.Marshal(outputs.All())
 	return http.StatusOK, result
 }
@@ -0,0 +1,47 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/Huawei/containerops/pilotage/module"
+)
+
+// GetSchedules returns the schedule triggers with the next fire times, all the schedules of daemon or the
+// ones of a flow.
+func GetSchedules(ctx *macaron.Context) (int, []byte) {
+	schedules := module.GetSchedules()
+
+	if ctx.Params("flow") != "" {
+		uri := fmt.Sprintf("%s/%s/%s", ctx.Params("namespace"), ctx.Params("repository"), ctx.Params("flow"))
+		filtered := []module.Schedule{}
+		for _, schedule := range schedules {
+			if schedule.URI == uri && schedule.Tag == ctx.Params("tag") {
+				filtered = append(filtered, schedule)
+			}
+		}
+		schedules = filtered
+	}
+
+	result, _ := json.Marshal(schedules)
+	return http.StatusOK, result
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/Huawei/containerops/pilotage/module"
+)
+
+// SecretRequest is the value of secret, it's never returned by the API.
+type SecretRequest struct {
+	Value string `json:"value"`
+}
+
+// GetSecrets returns the names of secrets in the namespace.
+func GetSecrets(ctx *macaron.Context) (int, []byte) {
+	names, err := module.Secrets.List(ctx.Params("namespace"))
+	if err != nil {
+		result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("List secrets error: %s", err.Error())})
+		return http.StatusInternalServerError, result
+	}
+
+	result, _ := json.Marshal(map[string]interface{}{"secrets": names})
+	return http.StatusOK, result
+}
+
+// PutSecret creates or updates a secret of the namespace, the value is encrypted in database.
+func PutSecret(ctx *macaron.Context) (int, []byte) {
+	namespace, name := ctx.Params("namespace"), ctx.Params("name")
+	if !module.ValidSecretName(name) {
+		result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("Invalid secret name: %s", name)})
+		return http.StatusBadRequest, result
+	}
+
+	body, _ := ctx.Req.Body().Bytes()
+	request := SecretRequest{}
+	if err := json.Unmarshal(body, &request); err != nil || request.Value == "" {
+		result, _ := json.Marshal(map[string]string{"message": "The value of secret is required"})
+		return http.StatusBadRequest, result
+	}
+
+	if err := module.Secrets.Put(namespace, name, request.Value); err != nil {
+		result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("Save secret [%s/%s] error: %s", namespace, name, err.Error())})
+		return http.StatusInternalServerError, result
+	}
+
+	result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("Secret [%s/%s] is saved", namespace, name)})
+	return http.StatusOK, result
+}
+
+// DeleteSecret removes a secret of the namespace.
+func DeleteSecret(ctx *macaron.Context) (int, []byte) {
+	namespace, name := ctx.Params("namespace"), ctx.Params("name")
+
+	if err := module.Secrets.Delete(namespace, name); err != nil {
+		result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("Delete secret [%s/%s] error: %s", namespace, name, err.Error())})
+		return http.StatusInternalServerError, result
+	}
+
+	result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("Secret [%s/%s] is deleted", namespace, name)})
+	return http.StatusOK, result
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+	"gopkg.in/macaron.v1"
+
+	"github.com/Huawei/containerops/pilotage/module"
+)
+
+const (
+	// The interval of SSE comment keeping the connection alive through the proxies when there is no log.
+	streamPingInterval = 15 * time.Second
+	// The buffer size of event stream, the stream is closed when it's full.
+	streamEventBuffer = 1024
+)
+
+// StreamEvent is a message of the WebSocket log stream, the data is a module.LogLine of `log` event,
+// and the status of flow for the `end` event.
+type StreamEvent struct {
+	Event string      `json:"event"`
+	Data  interface{} `json:"data"`
+}
+
+// GetFlowLogStream streams the logs of a running flow, or a stage, action or job of it when the `:job` is
+// `stage`, `stage.action` or `stage.action.job`. The buffered lines are replayed first, then the new lines are
+// sent until the flow is finished, or only the buffered lines are sent with the query `follow=false`.
+// It's a WebSocket connection with the Upgrade header, otherwise it's Server-Sent Events.
+func GetFlowLogStream(ctx *macaron.Context) {
+	f := getRunningFlow(ctx)
+	if f == nil {
+		return
+	}
+
+	stream := f.GetLogStream()
+	lines, ch := stream.Subscribe(ctx.Params("job"))
+	defer stream.Unsubscribe(ch)
+
+	follow := ctx.Query("follow") != "false"
+	if !follow {
+		stream.Unsubscribe(ch)
+	}
+
+	if strings.EqualFold(ctx.Req.Header.Get("Upgrade"), "websocket") {
+		// The Handshake is nil that doesn't check the Origin, the API isn't served for the browser of other sites.
+		websocket.Server{Handler: func(ws *websocket.Conn) {
+			streamWebSocket(ws, f, follow, lines, ch)
+		}}.ServeHTTP(ctx.Resp, ctx.Req.Request)
+		return
+	}
+
+	streamSSE(ctx, f, follow, lines, ch)
+}
+
+func streamSSE(ctx *macaron.Context, f *module.Flow, follow bool, lines []module.LogLine, ch <-chan module.LogLine) {
+	ctx.Resp.Header().Set("Content-Type", "text/event-stream")
+	ctx.Resp.Header().Set("Cache-Control", "no-cache")
+	ctx.Resp.Header().Set("Connection", "keep-alive")
+	ctx.Resp.WriteHeader(http.StatusOK)
+
+	id := 0
+	send := func(event string, data interface{}) {
+		content, _ := json.Marshal(data)
+		id++
+		fmt.Fprintf(ctx.Resp, "id: %d\nevent: %s\ndata: %s\n\n", id, event, content)
+		ctx.Resp.Flush()
+	}
+
+	for _, line := range lines {
+		send("log", line)
+	}
+
+	ticker := time.NewTicker(streamPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case line, ok := <-ch:
+			if !ok {
+				send(streamEnd(f, follow))
+				return
+			}
+			send("log", line)
+		case <-ticker.C:
+			fmt.Fprint(ctx.Resp, ": ping\n\n")
+			ctx.Resp.Flush()
+		case <-ctx.Req.Context().Done():
+			return
+		}
+	}
+}
+
+func streamWebSocket(ws *websocket.Conn, f *module.Flow, follow bool, lines []module.LogLine, ch <-chan module.LogLine) {
+	defer ws.Close()
+
+	closed := webSocketClosed(ws)
+
+	for _, line := range lines {
+		if err := websocket.JSON.Send(ws, StreamEvent{Event: "log", Data: line}); err != nil {
+			return
+		}
+	}
+
+	for {
+		select {
+		case line, ok := <-ch:
+			if !ok {
+				event, data := streamEnd(f, follow)
+				websocket.JSON.Send(ws, StreamEvent{Event: event, Data: data})
+				return
+			}
+			if err := websocket.JSON.Send(ws, StreamEvent{Event: "log", Data: line}); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// GetFlowEventStream streams the events of a running flow like stage.finished and job.output until the flow
+// is finished. The event of SSE is the type of event and the data is module.Event, the last one is `end` with
+// the status of flow, or `error` when the client receives too slow.
+// It's a WebSocket connection with the Upgrade header, otherwise it's Server-Sent Events.
+func GetFlowEventStream(ctx *macaron.Context) {
+	f := getRunningFlow(ctx)
+	if f == nil {
+		return
+	}
+
+	events, lost := make(chan module.Event, streamEventBuffer), make(chan struct{})
+	var once sync.Once
+	unsubscribe := f.SubscribeEvents(func(e module.Event) {
+		select {
+		case events <- e:
+		default:
+			once.Do(func() { close(lost) })
+		}
+	})
+	defer unsubscribe()
+
+	if strings.EqualFold(ctx.Req.Header.Get("Upgrade"), "websocket") {
+		websocket.Server{Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			streamEvents(f, events, lost, webSocketClosed(ws), func(event string, data interface{}) error {
+				return websocket.JSON.Send(ws, StreamEvent{Event: event, Data: data})
+			}, nil)
+		}}.ServeHTTP(ctx.Resp, ctx.Req.Request)
+		return
+	}
+
+	ctx.Resp.Header().Set("Content-Type", "text/event-stream")
+	ctx.Resp.Header().Set("Cache-Control", "no-cache")
+	ctx.Resp.Header().Set("Connection", "keep-alive")
+	ctx.Resp.WriteHeader(http.StatusOK)
+
+	id := 0
+	streamEvents(f, events, lost, ctx.Req.Context().Done(), func(event string, data interface{}) error {
+		content, _ := json.Marshal(data)
+		id++
+		if _, err := fmt.Fprintf(ctx.Resp, "id: %d\nevent: %s\ndata: %s\n\n", id, event, content); err != nil {
+			return err
+		}
+		ctx.Resp.Flush()
+		return nil
+	}, func() {
+		fmt.Fprint(ctx.Resp, ": ping\n\n")
+		ctx.Resp.Flush()
+	})
+}
+
+// streamEvents sends the events until the flow is finished or the client is closed.
+func streamEvents(f *module.Flow, events <-chan module.Event, lost, closed <-chan struct{}, send func(event string, data interface{}) error, ping func()) {
+	ticker := time.NewTicker(streamPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e := <-events:
+			if err := send(e.Type, e); err != nil {
+				return
+			}
+			if e.Type == module.FlowFinished {
+				send("end", map[string]string{"status": e.Status})
+				return
+			}
+		case <-lost:
+			send("error", map[string]string{"message": "The event stream is closed for receiving too slow"})
+			return
+		case <-ticker.C:
+			// The flow finished before subscribing never sends the flow.finished event.
+			if f.GetLogStream().Closed() {
+				send("end", map[string]string{"status": f.Status})
+				return
+			}
+			if ping != nil {
+				ping()
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// getRunningFlow returns the running flow of the URI, tag and number, it writes the error response and
+// returns nil when the flow is not running.
+func getRunningFlow(ctx *macaron.Context) *module.Flow {
+	uri := fmt.Sprintf("%s/%s/%s", ctx.Params("namespace"), ctx.Params("repository"), ctx.Params("flow"))
+	tag := ctx.Params("tag")
+
+	number, err := strconv.ParseInt(ctx.Params("number"), 10, 64)
+	if err != nil {
+		writeMessage(ctx, http.StatusBadRequest, fmt.Sprintf("Invalid flow number: %s", ctx.Params("number")))
+		return nil
+	}
+
+	f := module.GetRuntime(uri, tag, number)
+	if f == nil || f.GetLogStream() == nil {
+		writeMessage(ctx, http.StatusNotFound, fmt.Sprintf("Flow [%s:%s] number [%d] is not running", uri, tag, number))
+		return nil
+	}
+
+	return f
+}
+
+// webSocketClosed returns a channel closed when the connection is closed. The client never sends message,
+// the read returns when the connection is closed.
+func webSocketClosed(ws *websocket.Conn) <-chan struct{} {
+	closed := make(chan struct{})
+	go func() {
+		var message string
+		for websocket.Message.Receive(ws, &message) == nil {
+		}
+		close(closed)
+	}()
+	return closed
+}
+
+// streamEnd returns the last event when the channel of log stream is closed, it's an error when the
+// subscriber is dropped for receiving too slow.
+func streamEnd(f *module.Flow, follow bool) (string, interface{}) {
+	if !follow || f.GetLogStream().Closed() {
+		return "end", map[string]string{"status": f.Status}
+	}
+	return "error", map[string]string{"message": "The log stream is closed, reconnect to replay the logs"}
+}
+
+// writeMessage writes a JSON message response for the handlers writing the response themselves.
+func writeMessage(ctx *macaron.Context, status int, message string) {
+	result, _ := json.Marshal(map[string]string{"message": message})
+	ctx.Resp.Header().Set("Content-Type", "application/json")
+	ctx.Resp.WriteHeader(status)
+	ctx.Resp.Write(result)
+}
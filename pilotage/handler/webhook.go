@@ -18,9 +18,11 @@ package handler
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"path/filepath"
 
 	"github.com/Huawei/containerops/pilotage/config"
 	"github.com/Huawei/containerops/pilotage/module"
@@ -82,3 +84,93 @@ func WebHook(ctx *macaron.Context) (int, []byte) {
 
 	return res.StatusCode, bs
 }
+
+// GitWebHook receives the webhook of GitHub, GitLab or Gitea, and runs the flows of namespace and repository
+// whose triggers match the event. The flows are the YAML files in `FlowBaseDir/namespace/repository`, and the
+// commit, branch and author of event are the CO_GIT_* environments of flow. The payload is parsed after the
+// secret of a trigger verifies its signature.
+func GitWebHook(ctx *macaron.Context) (int, []byte) {
+	provider, namespace, repository := ctx.Params("provider"), ctx.Params("namespace"), ctx.Params("repository")
+
+	webhook, ok := module.GitWebhooks[provider]
+	if !ok {
+		result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("Unknown git webhook: %s", provider)})
+		return http.StatusNotFound, result
+	}
+
+	if !module.ValidName(namespace) || !module.ValidName(repository) {
+		result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("Invalid namespace or repository: %s/%s", namespace, repository)})
+		return http.StatusBadRequest, result
+	}
+
+	body, err := ctx.Req.Body().Bytes()
+	if err != nil {
+		result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("Read the webhook error: %s", err.Error())})
+		return http.StatusBadRequest, result
+	}
+
+	files, err := filepath.Glob(filepath.Join(config.WebHook.FlowBaseDir, namespace, repository, "*.yml"))
+	if err != nil {
+		result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("List flows error: %s", err.Error())})
+		return http.StatusInternalServerError, result
+	}
+
+	// The payload is parsed after a trigger of the flows verifies its signature.
+	type verifiedFlow struct {
+		flow     *module.Flow
+		triggers []module.Trigger
+	}
+	verified, unauthorized := []verifiedFlow{}, false
+	for _, file := range files {
+		f := &module.Flow{}
+		if err := f.ParseFlowFromFile(file, module.DaemonStart, false, true); err != nil {
+			continue
+		}
+
+		if triggers, err := f.VerifiedTriggers(provider, ctx.Req.Header, body); err == module.ErrInvalidSignature {
+			unauthorized = true
+		} else if len(triggers) > 0 {
+			verified = append(verified, verifiedFlow{flow: f, triggers: triggers})
+		}
+	}
+
+	if len(verified) == 0 && unauthorized {
+		result, _ := json.Marshal(map[string]string{"message": module.ErrInvalidSignature.Error()})
+		return http.StatusUnauthorized, result
+	} else if len(verified) == 0 {
+		result, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("No flow of %s/%s has the %s trigger", namespace, repository, provider)})
+		return http.StatusNotFound, result
+	}
+
+	event, err := webhook.Parse(ctx.Req.Header, body)
+	if err != nil {
+		result, _ := json.Marshal(map[string]string{"message": err.Error()})
+		return http.StatusBadRequest, result
+	} else if event == nil {
+		result, _ := json.Marshal(map[string]string{"message": "The event doesn't trigger flows"})
+		return http.StatusOK, result
+	}
+
+	flows := []string{}
+	for _, v := range verified {
+		matched := false
+		for i := range v.triggers {
+			matched = matched || v.triggers[i].Match(event)
+		}
+		if !matched {
+			continue
+		}
+
+		f := v.flow
+		f.Environments = append(f.Environments, event.Environments())
+		if _, err := module.Submit(f); err != nil {
+			log.Errorf("Queue the flow [%s:%s] error: %s", f.URI, f.Tag, err.Error())
+			continue
+		}
+		flows = append(flows, fmt.Sprintf("%s:%s", f.URI, f.Tag))
+	}
+
+	result, _ := json.Marshal(map[string]interface{}{
+		"message": fmt.Sprintf("%d flows are triggered by the %s event", len(flows), event.Event), "flows": flows})
+	return http.StatusOK, result
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package middleware
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -40,7 +41,7 @@ func SetRunDaemonMiddlewares(m *macaron.Macaron, cfgFile, flowFile string) {
 	}
 
 	go func() {
-		flow.LocalRun(true, true)
+		flow.LocalRun(context.Background(), true, true)
 	}()
 
 	// Init the flow and set into context.
@@ -13,12 +13,13 @@ type ActionV1 struct {
 }
 
 type ActionDataV1 struct {
-	ID       int64     `json:"id" gorm:"primary_key" gorm:"column:id"`
-	ActionID int64     `json:"action_id" sql:"not null;type:bigint(20)" gorm:"column:action_id"`
-	Number   int64     `json:"number" sql:"not null;type:bigint(20)" gorm:"column:number"`
-	Result   string    `json:"result" sql:"type:varchar(255)" gorm:"column:result"`
-	Start    time.Time `json:"start" sql:"" gorm:"column:start"`
-	End      time.Time `json:"end" sql:"" gorm:"column:end"`
+	ID         int64     `json:"id" gorm:"primary_key" gorm:"column:id"`
+	ActionID   int64     `json:"action_id" sql:"not null;type:bigint(20)" gorm:"column:action_id"`
+	Number     int64     `json:"number" sql:"not null;type:bigint(20)" gorm:"column:number"`
+	FlowNumber int64     `json:"flow_number" sql:"type:bigint(20);index" gorm:"column:flow_number"`
+	Result     string    `json:"result" sql:"type:varchar(255)" gorm:"column:result"`
+	Start      time.Time `json:"start" sql:"" gorm:"column:start"`
+	End        time.Time `json:"end" sql:"" gorm:"column:end"`
 }
 
 func (a *ActionV1) TableName() string {
@@ -54,11 +55,11 @@ func (a *ActionV1) Put(stageID int64, name, title string) (actionID int64, err e
 	return actionID, nil
 }
 
-func (ad *ActionDataV1) Put(actionID, number int64, result string, start, end time.Time) error {
+func (ad *ActionDataV1) Put(actionID, number, flowNumber int64, result string, start, end time.Time) error {
 	if DisableDB {
 		return nil
 	}
-	ad.ActionID, ad.Number, ad.Result, ad.Start, ad.End = actionID, number, result, start, end
+	ad.ActionID, ad.Number, ad.FlowNumber, ad.Result, ad.Start, ad.End = actionID, number, flowNumber, result, start, end
 
 	tx := DB.Begin()
 	if err := tx.Create(&ad).Error; err != nil {
@@ -83,3 +84,29 @@ func (ad *ActionDataV1) GetNumbers(actionID int64) (int64, error) {
 	}
 	return tmp.RowsAffected, nil
 }
+
+// List returns the actions of stages.
+func (a *ActionV1) List(stageIDs []int64) ([]ActionV1, error) {
+	actions := []ActionV1{}
+	if DisableDB || len(stageIDs) == 0 {
+		return actions, nil
+	}
+
+	if err := DB.Where("stage_id IN (?)", stageIDs).Order("id").Find(&actions).Error; err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+// ListByFlowNumber returns the data of actions in the run of flow.
+func (ad *ActionDataV1) ListByFlowNumber(actionIDs []int64, flowNumber int64) ([]ActionDataV1, error) {
+	data := []ActionDataV1{}
+	if DisableDB || len(actionIDs) == 0 {
+		return data, nil
+	}
+
+	if err := DB.Where("action_id IN (?) AND flow_number = ?", actionIDs, flowNumber).Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+// AttemptV1 is one attempt of a job data, the job with retry policy may have many attempts in one run.
+type AttemptV1 struct {
+	ID        int64     `json:"id" gorm:"primary_key" gorm:"column:id"`
+	JobDataID int64     `json:"job_data_id" sql:"not null;type:bigint(20)" gorm:"column:job_data_id"`
+	JobID     int64     `json:"job_id" sql:"not null;type:bigint(20)" gorm:"column:job_id"`
+	Number    int64     `json:"number" sql:"not null;type:bigint(20)" gorm:"column:number"`
+	Result    string    `json:"result" sql:"type:varchar(255)" gorm:"column:result"`
+	ExitCode  int64     `json:"exit_code" sql:"type:bigint(20)" gorm:"column:exit_code"`
+	Reason    string    `json:"reason" sql:"type:text" gorm:"column:reason"`
+	Logs      string    `json:"logs" sql:"type:longtext" gorm:"column:logs"`
+	Start     time.Time `json:"start" sql:"" gorm:"column:start"`
+	End       time.Time `json:"end" sql:"" gorm:"column:end"`
+}
+
+func (a *AttemptV1) TableName() string {
+	return "attempt_v1"
+}
+
+func (a *AttemptV1) Put(jobDataID, jobID, number, exitCode int64, result, reason, logs string, start, end time.Time) error {
+	if DisableDB {
+		return nil
+	}
+
+	a.JobDataID, a.JobID, a.Number, a.ExitCode = jobDataID, jobID, number, exitCode
+	a.Result, a.Reason, a.Logs, a.Start, a.End = result, reason, logs, start, end
+
+	tx := DB.Begin()
+	if err := tx.Create(&a).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	tx.Commit()
+
+	return nil
+}
+
+// List returns the attempts of a job data order by the number.
+func (a *AttemptV1) List(jobDataID int64) ([]AttemptV1, error) {
+	attempts := []AttemptV1{}
+	if DisableDB {
+		return attempts, nil
+	}
+
+	if err := DB.Where("job_data_id = ?", jobDataID).Order("number").Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
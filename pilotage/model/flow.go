@@ -19,13 +19,17 @@ type FlowV1 struct {
 	DeletedAt  *time.Time `json:"deleted_at" sql:"index" gorm:"column:deleted_at"`
 }
 
+// FlowDataV1 is a run of flow, the parent is the run triggering it by the downstream of stage or action in the path.
 type FlowDataV1 struct {
-	ID     int64     `json:"id" gorm:"primary_key" gorm:"column:id"`
-	FlowID int64     `json:"flow_id" sql:"not null;type:bigint(20)" gorm:"column:flow_id"`
-	Number int64     `json:"number" sql:"not null;type:bigint(20)" gorm:"column:number"`
-	Result string    `json:"result" sql:"type:varchar(255)" gorm:"column:result"`
-	Start  time.Time `json:"start" sql:"" gorm:"column:start"`
-	End    time.Time `json:"end" sql:"" gorm:"column:end"`
+	ID           int64     `json:"id" gorm:"primary_key" gorm:"column:id"`
+	FlowID       int64     `json:"flow_id" sql:"not null;type:bigint(20);unique_index:flow_data_number" gorm:"column:flow_id"`
+	Number       int64     `json:"number" sql:"not null;type:bigint(20);unique_index:flow_data_number" gorm:"column:number"`
+	Result       string    `json:"result" sql:"type:varchar(255)" gorm:"column:result"`
+	Start        time.Time `json:"start" sql:"" gorm:"column:start"`
+	End          time.Time `json:"end" sql:"" gorm:"column:end"`
+	ParentFlowID int64     `json:"parent_flow_id" sql:"default:0;index" gorm:"column:parent_flow_id"`
+	ParentNumber int64     `json:"parent_number" sql:"default:0" gorm:"column:parent_number"`
+	ParentPath   string    `json:"parent_path" sql:"type:varchar(255)" gorm:"column:parent_path"`
 }
 
 func (f *FlowV1) TableName() string {
@@ -64,17 +68,74 @@ func (f *FlowV1) Put(namespace, repository, name, tag, title, content string, ve
 	return flowID, nil
 }
 
-func (fd *FlowDataV1) Put(flowID, number int64, result string, start, end time.Time) error {
+// GetByID returns the flow by id.
+func (f *FlowV1) GetByID(id int64) error {
 	if DisableDB {
 		return nil
 	}
 
-	fd.FlowID, fd.Number, fd.Result, fd.Start, fd.End = flowID, number, result, start, end
+	return DB.Where("id = ?", id).First(&f).Error
+}
+
+// Get returns the flow by namespace, repository, name and tag.
+func (f *FlowV1) Get(namespace, repository, name, tag string) error {
+	if DisableDB {
+		return nil
+	}
+
+	return DB.Where("namespace = ? AND repository = ? AND name = ? AND tag = ?", namespace, repository, name, tag).First(&f).Error
+}
+
+// Reserve saves a started run of flow with the next number, the unique index of flow and number keeps the
+// concurrent runs from getting the same number. The parent of run is set before.
+func (fd *FlowDataV1) Reserve(flowID int64, result string, start time.Time) (number int64, err error) {
+	if DisableDB {
+		return -1, nil
+	}
+
+	for retry := 0; retry < 3; retry++ {
+		tx := DB.Begin()
+		if err = tx.Model(&FlowDataV1{}).Where("flow_id = ?", flowID).Select("COALESCE(MAX(number), 0)").Row().Scan(&number); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		fd.ID, fd.FlowID, fd.Number, fd.Result, fd.Start = 0, flowID, number+1, result, start
+		if err = tx.Create(fd).Error; err != nil {
+			// The number is reserved by another run at the same time.
+			tx.Rollback()
+			continue
+		}
+		if err = tx.Commit().Error; err != nil {
+			return 0, err
+		}
+		return fd.Number, nil
+	}
+
+	return 0, err
+}
 
+// Put saves the result of run, the run reserved when it started is updated. The parent of run is set before.
+func (fd *FlowDataV1) Put(flowID, number int64, result string, start, end time.Time) error {
+	if DisableDB {
+		return nil
+	}
+
+	run := FlowDataV1{}
 	tx := DB.Begin()
-	if err := tx.Create(&fd).Error; err != nil {
-		tx.Rollback()
-		return err
+	if tx.Where("flow_id = ? AND number = ?", flowID, number).First(&run).RecordNotFound() {
+		fd.ID, fd.FlowID, fd.Number, fd.Result, fd.Start, fd.End = 0, flowID, number, result, start, end
+		if err := tx.Create(fd).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	} else {
+		if err := tx.Model(&run).Updates(map[string]interface{}{"result": result, "start": start, "end": end,
+			"parent_flow_id": fd.ParentFlowID, "parent_number": fd.ParentNumber, "parent_path": fd.ParentPath}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		fd.ID, fd.FlowID, fd.Number, fd.Result, fd.Start, fd.End = run.ID, flowID, number, result, start, end
 	}
 	tx.Commit()
 
@@ -95,3 +156,82 @@ func (fd *FlowDataV1) GetNumbers(flowID int64) (int64, error) {
 	}
 	return tmp.RowsAffected, nil
 }
+
+// RunFilter filters the runs by result and start time, the offset and limit page the runs.
+type RunFilter struct {
+	Result string
+	Since  time.Time
+	Until  time.Time
+	Offset int
+	Limit  int
+}
+
+// List returns the flows of namespace and repository, the empty repository matches all repositories of namespace.
+func (f *FlowV1) List(namespace, repository string, offset, limit int) ([]FlowV1, int64, error) {
+	flows, total := []FlowV1{}, int64(0)
+	if DisableDB {
+		return flows, total, nil
+	}
+
+	query := DB.Model(&FlowV1{}).Where("namespace = ?", namespace)
+	if repository != "" {
+		query = query.Where("repository = ?", repository)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("repository, name, tag").Offset(offset).Limit(limit).Find(&flows).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return flows, total, nil
+}
+
+// List returns the runs of flow matching the filter order by the number descending, and the total of them.
+func (fd *FlowDataV1) List(flowID int64, filter RunFilter) ([]FlowDataV1, int64, error) {
+	runs, total := []FlowDataV1{}, int64(0)
+	if DisableDB {
+		return runs, total, nil
+	}
+
+	query := DB.Model(&FlowDataV1{}).Where("flow_id = ?", flowID)
+	if filter.Result != "" {
+		query = query.Where("result = ?", filter.Result)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("start >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("start <= ?", filter.Until)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("number desc").Offset(filter.Offset).Limit(filter.Limit).Find(&runs).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return runs, total, nil
+}
+
+// ListChildren returns the runs triggered by the run of flow.
+func (fd *FlowDataV1) ListChildren(flowID, number int64) ([]FlowDataV1, error) {
+	runs := []FlowDataV1{}
+	if DisableDB {
+		return runs, nil
+	}
+
+	if err := DB.Where("parent_flow_id = ? AND parent_number = ?", flowID, number).Order("id").Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// Get returns the run of flow by number.
+func (fd *FlowDataV1) Get(flowID, number int64) error {
+	if DisableDB {
+		return nil
+	}
+
+	return DB.Where("flow_id = ? AND number = ?", flowID, number).First(&fd).Error
+}
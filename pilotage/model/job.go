@@ -19,12 +19,16 @@ type JobV1 struct {
 }
 
 type JobDataV1 struct {
-	ID     int64     `json:"id" gorm:"primary_key" gorm:"column:id"`
-	JobID  int64     `json:"job_id" sql:"not null;type:bigint(20)" gorm:"column:job_id"`
-	Number int64     `json:"number" sql:"not null;type:bigint(20)" gorm:"column:number"`
-	Result string    `json:"result" sql:"type:varchar(255)" gorm:"column:result"`
-	Start  time.Time `json:"start" sql:"" gorm:"column:start"`
-	End    time.Time `json:"end" sql:"" gorm:"column:end"`
+	ID         int64     `json:"id" gorm:"primary_key" gorm:"column:id"`
+	JobID      int64     `json:"job_id" sql:"not null;type:bigint(20)" gorm:"column:job_id"`
+	Number     int64     `json:"number" sql:"not null;type:bigint(20)" gorm:"column:number"`
+	FlowNumber int64     `json:"flow_number" sql:"type:bigint(20);index" gorm:"column:flow_number"`
+	Result     string    `json:"result" sql:"type:varchar(255)" gorm:"column:result"`
+	Start      time.Time `json:"start" sql:"" gorm:"column:start"`
+	End        time.Time `json:"end" sql:"" gorm:"column:end"`
+	ExitCode   int64     `json:"exit_code" sql:"type:bigint(20)" gorm:"column:exit_code"`
+	Reason     string    `json:"reason" sql:"type:text" gorm:"column:reason"`
+	Artifacts  string    `json:"artifacts" sql:"type:text" gorm:"column:artifacts"` // The JSON of archived artifact URLs by name.
 }
 
 func (j *JobV1) TableName() string {
@@ -63,12 +67,12 @@ func (j *JobV1) Put(actionID, timeout int64, name, jobType, endpoint, resources,
 	return jobID, nil
 }
 
-func (jd *JobDataV1) Put(jobID, number int64, result string, start, end time.Time) error {
+func (jd *JobDataV1) Put(jobID, number, flowNumber int64, result string, start, end time.Time) error {
 	if DisableDB {
 		return nil
 	}
 
-	jd.JobID, jd.Number, jd.Result, jd.Start, jd.End = jobID, number, result, start, end
+	jd.JobID, jd.Number, jd.FlowNumber, jd.Result, jd.Start, jd.End = jobID, number, flowNumber, result, start, end
 
 	tx := DB.Begin()
 	if err := tx.Create(&jd).Error; err != nil {
@@ -94,3 +98,29 @@ func (jd *JobDataV1) GetNumbers(jobID int64) (int64, error) {
 	}
 	return tmp.RowsAffected, nil
 }
+
+// List returns the jobs of actions.
+func (j *JobV1) List(actionIDs []int64) ([]JobV1, error) {
+	jobs := []JobV1{}
+	if DisableDB || len(actionIDs) == 0 {
+		return jobs, nil
+	}
+
+	if err := DB.Where("action_id IN (?)", actionIDs).Order("id").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// ListByFlowNumber returns the data of jobs in the run of flow.
+func (jd *JobDataV1) ListByFlowNumber(jobIDs []int64, flowNumber int64) ([]JobDataV1, error) {
+	data := []JobDataV1{}
+	if DisableDB || len(jobIDs) == 0 {
+		return data, nil
+	}
+
+	if err := DB.Where("job_id IN (?) AND flow_number = ?", jobIDs, flowNumber).Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -1,8 +1,12 @@
 package model
 
 import (
+	"fmt"
+	"strings"
 	"sync"
 	"time"
+
+	log "github.com/Sirupsen/logrus"
 )
 
 const (
@@ -20,12 +24,32 @@ const (
 	JOB    = "JOB"
 )
 
+// The job output lines are inserted in batches, a batch is inserted when it's full or the interval is passed.
+const (
+	logBatchSize     = 200
+	logFlushInterval = time.Second
+	logQueueSize     = 4096
+)
+
+// logRequest is a log to insert, or a flush request when the flushed isn't nil.
+type logRequest struct {
+	log     LogV1
+	flushed chan struct{}
+}
+
+var (
+	logWriterOnce sync.Once
+	logQueue      chan logRequest
+)
+
+// LogV1 is a log of flow, stage, action or job, the number is the number of flow run.
 type LogV1 struct {
 	ID    int64  `json:"id" gorm:"primary_key" gorm:"column:id"`
 	Level string `json:"level" sql:"not null;type:varchar(255)" gorm:"column:level"`
 	//Phase must be one of 'flow','stage','action' or 'job'
 	Phase     string    `json:"phase" sql:"type:varchar(255)" gorm:"column:phase"`
 	PhaseID   int64     `json:"phase_id" sql:"type:bigint(20)" gorm:"column:phase_id"`
+	Number    int64     `json:"number" sql:"type:bigint(20);index" gorm:"column:number"`
 	Content   string    `json:"content" sql:"type:text" gorm:"column:content"`
 	EventTime time.Time `json:"envent_time" sql:"" gorm:"column:envent_time"`
 }
@@ -34,25 +58,117 @@ func (l *LogV1) TableName() string {
 	return "log_v1"
 }
 
-func (l *LogV1) Create(level, phase string, phaseID int64, content string) error {
+func (l *LogV1) Create(level, phase string, phaseID, number int64, content string) error {
 	if DisableDB {
 		return nil
 	}
 
-	l.Level, l.Phase, l.PhaseID, l.Content = level, phase, phaseID, content
+	l.Level, l.Phase, l.PhaseID, l.Number, l.Content = level, phase, phaseID, number, content
 	l.EventTime = time.Now()
 
-	tx := DB.Begin()
+	return DB.Create(&l).Error
+}
+
+// CreateAsync queues a log inserted in batch, it's for the job output lines which are too many to insert one by one.
+// It blocks when the queue is full, so the logs are never dropped.
+func (l *LogV1) CreateAsync(level, phase string, phaseID, number int64, content string) {
+	if DisableDB {
+		return
+	}
+
+	logWriterOnce.Do(startLogWriter)
+	logQueue <- logRequest{log: LogV1{Level: level, Phase: phase, PhaseID: phaseID, Number: number, Content: content,
+		EventTime: time.Now()}}
+}
+
+// FlushLogs returns after all the logs created asynchronously before are inserted.
+func FlushLogs() {
+	if DisableDB {
+		return
+	}
+
+	logWriterOnce.Do(startLogWriter)
+	flushed := make(chan struct{})
+	logQueue <- logRequest{flushed: flushed}
+	<-flushed
+}
+
+func startLogWriter() {
+	logQueue = make(chan logRequest, logQueueSize)
 
-	mutex := &sync.Mutex{}
-	mutex.Lock()
-	defer mutex.Unlock()
+	go func() {
+		ticker := time.NewTicker(logFlushInterval)
+		defer ticker.Stop()
 
-	if err := tx.Create(&l).Error; err != nil {
-		tx.Rollback()
-		return err
+		batch := []LogV1{}
+		insert := func() {
+			if err := insertLogs(batch); err != nil {
+				log.Errorf("Insert %d logs error: %s", len(batch), err.Error())
+			}
+			batch = batch[:0]
+		}
+
+		for {
+			select {
+			case r := <-logQueue:
+				if r.flushed != nil {
+					insert()
+					close(r.flushed)
+					continue
+				}
+
+				if batch = append(batch, r.log); len(batch) >= logBatchSize {
+					insert()
+				}
+			case <-ticker.C:
+				insert()
+			}
+		}
+	}()
+}
+
+// insertLogs inserts the logs in one statement, gorm v1 doesn't support batch insert.
+func insertLogs(logs []LogV1) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	values, args := make([]string, 0, len(logs)), make([]interface{}, 0, len(logs)*6)
+	for _, l := range logs {
+		values = append(values, "(?, ?, ?, ?, ?, ?)")
+		args = append(args, l.Level, l.Phase, l.PhaseID, l.Number, l.Content, l.EventTime)
+	}
+
+	return DB.Exec(fmt.Sprintf("INSERT INTO %s (level, phase, phase_id, number, content, envent_time) VALUES %s",
+		new(LogV1).TableName(), strings.Join(values, ", ")), args...).Error
+}
+
+// Purge deletes the logs of levels created before the time, and returns the count of deleted logs.
+// The empty levels means all levels.
+func (l *LogV1) Purge(levels []string, before time.Time) (int64, error) {
+	if DisableDB {
+		return 0, nil
+	}
+
+	query := DB.Where("envent_time < ?", before)
+	if len(levels) > 0 {
+		query = query.Where("level IN (?)", levels)
+	}
+
+	result := query.Delete(LogV1{})
+	return result.RowsAffected, result.Error
+}
+
+// List returns the logs of phase in the flow run order by the event time.
+func (l *LogV1) List(phase string, phaseID, number int64, offset, limit int) ([]LogV1, error) {
+	logs := []LogV1{}
+	if DisableDB {
+		return logs, nil
 	}
 
-	tx.Commit()
-	return nil
+	if err := DB.Where("phase = ? AND phase_id = ? AND number = ?", phase, phaseID, number).
+		Order("envent_time, id").Offset(offset).Limit(limit).Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	return logs, nil
 }
@@ -48,5 +48,11 @@ func Migrate() {
 	DB.AutoMigrate(&StageV1{}, &StageDataV1{})
 	DB.AutoMigrate(&ActionV1{}, &ActionDataV1{})
 	DB.AutoMigrate(&JobV1{}, &JobDataV1{})
+	DB.AutoMigrate(&AttemptV1{})
 	DB.AutoMigrate(&LogV1{})
+	DB.AutoMigrate(&PauseV1{})
+	DB.AutoMigrate(&OutputV1{})
+	DB.AutoMigrate(&ScheduleV1{})
+	DB.AutoMigrate(&QueueV1{})
+	DB.AutoMigrate(&SecretV1{})
 }
@@ -0,0 +1,58 @@
+package model
+
+import "time"
+
+// OutputV1 is an output of job in a flow run, the key is `stage.action.job[output]`.
+type OutputV1 struct {
+	ID        int64     `json:"id" gorm:"primary_key" gorm:"column:id"`
+	FlowID    int64     `json:"flow_id" sql:"not null;type:bigint(20)" gorm:"column:flow_id"`
+	Number    int64     `json:"number" sql:"not null;type:bigint(20)" gorm:"column:number"`
+	JobID     int64     `json:"job_id" sql:"type:bigint(20)" gorm:"column:job_id"`
+	Key       string    `json:"key" sql:"not null;type:varchar(255)" gorm:"column:key"`
+	Value     string    `json:"value" sql:"type:text" gorm:"column:value"`
+	CreatedAt time.Time `json:"created_at" sql:"" gorm:"column:created_at"`
+	UpdatedAt time.Time `json:"updated_at" sql:"" gorm:"column:updated_at"`
+}
+
+func (o *OutputV1) TableName() string {
+	return "output_v1"
+}
+
+func (o *OutputV1) Put(flowID, number, jobID int64, key, value string) error {
+	if DisableDB {
+		return nil
+	}
+
+	o.FlowID, o.Number, o.JobID, o.Key, o.Value = flowID, number, jobID, key, value
+
+	tx := DB.Begin()
+	if tx.Where("flow_id = ? AND number = ? AND `key` = ?", flowID, number, key).First(&o).RecordNotFound() {
+		o.CreatedAt = time.Now()
+		if err := tx.Create(&o).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	} else {
+		if err := tx.Model(&o).Updates(OutputV1{JobID: jobID, Value: value}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	tx.Commit()
+
+	return nil
+}
+
+// List returns all outputs of a flow run.
+func (o *OutputV1) List(flowID, number int64) ([]OutputV1, error) {
+	outputs := []OutputV1{}
+	if DisableDB {
+		return outputs, nil
+	}
+
+	if err := DB.Where("flow_id = ? AND number = ?", flowID, number).Find(&outputs).Error; err != nil {
+		return nil, err
+	}
+
+	return outputs, nil
+}
@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// PauseV1 records a pause stage waiting for approval and whom resumes it.
+type PauseV1 struct {
+	ID       int64     `json:"id" gorm:"primary_key" gorm:"column:id"`
+	FlowID   int64     `json:"flow_id" sql:"not null;type:bigint(20)" gorm:"column:flow_id"`
+	StageID  int64     `json:"stage_id" sql:"not null;type:bigint(20)" gorm:"column:stage_id"`
+	Number   int64     `json:"number" sql:"not null;type:bigint(20)" gorm:"column:number"`
+	Result   string    `json:"result" sql:"type:varchar(255)" gorm:"column:result"`
+	Approver string    `json:"approver" sql:"type:varchar(255)" gorm:"column:approver"`
+	Comment  string    `json:"comment" sql:"type:text" gorm:"column:comment"`
+	Start    time.Time `json:"start" sql:"" gorm:"column:start"`
+	End      time.Time `json:"end" sql:"" gorm:"column:end"`
+}
+
+func (p *PauseV1) TableName() string {
+	return "pause_v1"
+}
+
+func (p *PauseV1) Put(flowID, stageID, number int64, result string, start time.Time) error {
+	if DisableDB {
+		return nil
+	}
+
+	p.FlowID, p.StageID, p.Number, p.Result, p.Start = flowID, stageID, number, result, start
+
+	tx := DB.Begin()
+	if err := tx.Create(&p).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	tx.Commit()
+
+	return nil
+}
+
+// Resume records the result of pause stage with the approver and comment.
+func (p *PauseV1) Resume(result, approver, comment string, end time.Time) error {
+	if DisableDB {
+		return nil
+	}
+
+	tx := DB.Begin()
+	if err := tx.Model(&p).Updates(PauseV1{Result: result, Approver: approver, Comment: comment, End: end}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	tx.Commit()
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"time"
+)
+
+// QueueV1 is a queued or running flow run of the daemon, the content is the YAML definition of flow run with
+// its environments. The run is removed when it's finished, so the ones left by a crash of daemon are recovered
+// after it restarts; the containers are the `executor/name` of job containers deleted with the orphaned run.
+type QueueV1 struct {
+	ID         int64     `json:"id" gorm:"primary_key" gorm:"column:id"`
+	Namespace  string    `json:"namespace" sql:"not null;type:varchar(255)" gorm:"column:namespace"`
+	Repository string    `json:"repository" sql:"not null;type:varchar(255)" gorm:"column:repository"`
+	Name       string    `json:"name" sql:"not null;type:varchar(255)" gorm:"column:name"`
+	Tag        string    `json:"tag" sql:"not null;type:varchar(255)" gorm:"column:tag"`
+	Content    string    `json:"content" sql:"type:text" gorm:"column:content"`
+	Status     string    `json:"status" sql:"type:varchar(255)" gorm:"column:status"`
+	FlowID     int64     `json:"flow_id" sql:"default:0" gorm:"column:flow_id"`
+	Number     int64     `json:"number" sql:"default:0" gorm:"column:number"`
+	Containers string    `json:"containers" sql:"type:text" gorm:"column:containers"`
+	CreatedAt  time.Time `json:"created_at" sql:"" gorm:"column:created_at"`
+	StartedAt  time.Time `json:"started_at" sql:"" gorm:"column:started_at"`
+}
+
+func (q *QueueV1) TableName() string {
+	return "queue_v1"
+}
+
+// Create saves a queued run, the id of it is zero when the database is disabled.
+func (q *QueueV1) Create(namespace, repository, name, tag, content, status string) error {
+	if DisableDB {
+		return nil
+	}
+
+	q.Namespace, q.Repository, q.Name, q.Tag, q.Content, q.Status = namespace, repository, name, tag, content, status
+	q.CreatedAt = time.Now()
+
+	tx := DB.Begin()
+	if err := tx.Create(&q).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	tx.Commit()
+
+	return nil
+}
+
+// List returns all the queued and running runs in the order of creation.
+func (q *QueueV1) List() ([]QueueV1, error) {
+	runs := []QueueV1{}
+	if DisableDB {
+		return runs, nil
+	}
+
+	if err := DB.Order("id").Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// Update changes the columns of the run by id.
+func (q *QueueV1) Update(id int64, columns map[string]interface{}) error {
+	if DisableDB || id == 0 {
+		return nil
+	}
+
+	return DB.Model(&QueueV1{}).Where("id = ?", id).Updates(columns).Error
+}
+
+// Delete removes the finished or cancelled run.
+func (q *QueueV1) Delete(id int64) error {
+	if DisableDB || id == 0 {
+		return nil
+	}
+
+	return DB.Where("id = ?", id).Delete(&QueueV1{}).Error
+}
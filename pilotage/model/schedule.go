@@ -0,0 +1,57 @@
+package model
+
+import (
+	"time"
+)
+
+// ScheduleV1 is the fire times of a schedule trigger, the next time decides the missed run after the daemon restarts.
+type ScheduleV1 struct {
+	ID         int64     `json:"id" gorm:"primary_key" gorm:"column:id"`
+	Namespace  string    `json:"namespace" sql:"not null;type:varchar(255)" gorm:"column:namespace"`
+	Repository string    `json:"repository" sql:"not null;type:varchar(255)" gorm:"column:repository"`
+	Name       string    `json:"name" sql:"not null;type:varchar(255)" gorm:"column:name"`
+	Tag        string    `json:"tag" sql:"not null;type:varchar(255)" gorm:"column:tag"`
+	Schedule   string    `json:"schedule" sql:"not null;type:varchar(255)" gorm:"column:schedule"`
+	Last       time.Time `json:"last" sql:"" gorm:"column:last"`
+	Next       time.Time `json:"next" sql:"" gorm:"column:next"`
+	UpdatedAt  time.Time `json:"updated_at" sql:"" gorm:"column:updated_at"`
+}
+
+func (s *ScheduleV1) TableName() string {
+	return "schedule_v1"
+}
+
+// Get returns the fire times of the schedule of flow, it's gorm.ErrRecordNotFound when the schedule never fired.
+func (s *ScheduleV1) Get(namespace, repository, name, tag, schedule string) error {
+	if DisableDB {
+		return nil
+	}
+
+	return DB.Where("namespace = ? AND repository = ? AND name = ? AND tag = ? AND schedule = ?",
+		namespace, repository, name, tag, schedule).First(&s).Error
+}
+
+// Put saves the last and next fire times of the schedule of flow.
+func (s *ScheduleV1) Put(namespace, repository, name, tag, schedule string, last, next time.Time) error {
+	if DisableDB {
+		return nil
+	}
+
+	tx := DB.Begin()
+	if tx.Where("namespace = ? AND repository = ? AND name = ? AND tag = ? AND schedule = ?",
+		namespace, repository, name, tag, schedule).First(&s).RecordNotFound() {
+		s.Namespace, s.Repository, s.Name, s.Tag, s.Schedule, s.Last, s.Next = namespace, repository, name, tag, schedule, last, next
+		if err := tx.Create(&s).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	} else {
+		if err := tx.Model(&s).Updates(map[string]interface{}{"last": last, "next": next}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	tx.Commit()
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"time"
+)
+
+// SecretV1 is a secret of namespace, the value is encrypted by the fernet key of pilotage.
+type SecretV1 struct {
+	ID        int64     `json:"id" gorm:"primary_key" gorm:"column:id"`
+	Namespace string    `json:"namespace" sql:"not null;type:varchar(255)" gorm:"column:namespace"`
+	Name      string    `json:"name" sql:"not null;type:varchar(255)" gorm:"column:name"`
+	Value     string    `json:"-" sql:"type:text" gorm:"column:value"`
+	CreatedAt time.Time `json:"created_at" sql:"" gorm:"column:created_at"`
+	UpdatedAt time.Time `json:"updated_at" sql:"" gorm:"column:updated_at"`
+}
+
+func (s *SecretV1) TableName() string {
+	return "secret_v1"
+}
+
+// Get returns the secret of namespace by name, it's gorm.ErrRecordNotFound when the secret doesn't exist.
+func (s *SecretV1) Get(namespace, name string) error {
+	if DisableDB {
+		return nil
+	}
+
+	return DB.Where("namespace = ? AND name = ?", namespace, name).First(&s).Error
+}
+
+// Put creates or updates the encrypted value of secret.
+func (s *SecretV1) Put(namespace, name, value string) error {
+	if DisableDB {
+		return nil
+	}
+
+	tx := DB.Begin()
+	if tx.Where("namespace = ? AND name = ?", namespace, name).First(&s).RecordNotFound() {
+		s.Namespace, s.Name, s.Value = namespace, name, value
+		if err := tx.Create(&s).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	} else {
+		if err := tx.Model(&s).Updates(map[string]interface{}{"value": value}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	tx.Commit()
+
+	return nil
+}
+
+// List returns the secrets of namespace order by name.
+func (s *SecretV1) List(namespace string) ([]SecretV1, error) {
+	secrets := []SecretV1{}
+	if DisableDB {
+		return secrets, nil
+	}
+
+	if err := DB.Where("namespace = ?", namespace).Order("name").Find(&secrets).Error; err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// Delete removes the secret of namespace by name.
+func (s *SecretV1) Delete(namespace, name string) error {
+	if DisableDB {
+		return nil
+	}
+
+	return DB.Where("namespace = ? AND name = ?", namespace, name).Delete(&SecretV1{}).Error
+}
@@ -15,12 +15,13 @@ type StageV1 struct {
 }
 
 type StageDataV1 struct {
-	ID      int64     `json:"id" gorm:"primary_key" gorm:"column:id"`
-	StageID int64     `json:"stage_id" sql:"not null;type:bigint(20)" gorm:"column:stage_id"`
-	Number  int64     `json:"number" sql:"not null;type:bigint(20)" gorm:"column:number"`
-	Result  string    `json:"result" sql:"type:varchar(255)" gorm:"column:result"`
-	Start   time.Time `json:"start" sql:"" gorm:"column:start"`
-	End     time.Time `json:"end" sql:"" gorm:"column:end"`
+	ID         int64     `json:"id" gorm:"primary_key" gorm:"column:id"`
+	StageID    int64     `json:"stage_id" sql:"not null;type:bigint(20)" gorm:"column:stage_id"`
+	Number     int64     `json:"number" sql:"not null;type:bigint(20)" gorm:"column:number"`
+	FlowNumber int64     `json:"flow_number" sql:"type:bigint(20);index" gorm:"column:flow_number"`
+	Result     string    `json:"result" sql:"type:varchar(255)" gorm:"column:result"`
+	Start      time.Time `json:"start" sql:"" gorm:"column:start"`
+	End        time.Time `json:"end" sql:"" gorm:"column:end"`
 }
 
 func (s *StageV1) TableName() string {
@@ -59,12 +60,12 @@ func (s *StageV1) Put(flowID int64, stageType, name, title, sequencing string) (
 	return stageID, nil
 }
 
-func (sd *StageDataV1) Put(stageID, number int64, result string, start, end time.Time) error {
+func (sd *StageDataV1) Put(stageID, number, flowNumber int64, result string, start, end time.Time) error {
 	if DisableDB {
 		return nil
 	}
 
-	sd.StageID, sd.Number, sd.Result, sd.Start, sd.End = stageID, number, result, start, end
+	sd.StageID, sd.Number, sd.FlowNumber, sd.Result, sd.Start, sd.End = stageID, number, flowNumber, result, start, end
 
 	tx := DB.Begin()
 	if err := tx.Create(&sd).Error; err != nil {
@@ -90,3 +91,29 @@ func (sd *StageDataV1) GetNumbers(stageID int64) (int64, error) {
 	}
 	return tmp.RowsAffected, nil
 }
+
+// List returns the stages of flow.
+func (s *StageV1) List(flowID int64) ([]StageV1, error) {
+	stages := []StageV1{}
+	if DisableDB {
+		return stages, nil
+	}
+
+	if err := DB.Where("flow_id = ?", flowID).Order("id").Find(&stages).Error; err != nil {
+		return nil, err
+	}
+	return stages, nil
+}
+
+// ListByFlowNumber returns the data of stages in the run of flow.
+func (sd *StageDataV1) ListByFlowNumber(stageIDs []int64, flowNumber int64) ([]StageDataV1, error) {
+	data := []StageDataV1{}
+	if DisableDB || len(stageIDs) == 0 {
+		return data, nil
+	}
+
+	if err := DB.Where("stage_id IN (?) AND flow_number = ?", stageIDs, flowNumber).Find(&data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -1,12 +1,11 @@
 package module
 
 import (
+	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/Huawei/containerops/pilotage/model"
-	. "github.com/logrusorgru/aurora"
 )
 
 const (
@@ -17,40 +16,51 @@ const (
 
 // Action is
 type Action struct {
-	ID     int64    `json:"-" yaml:"-"`
-	Name   string   `json:"name" yaml:"name"`
-	Title  string   `json:"title" yaml:"title"`
-	Status string   `json:"status,omitempty" yaml:"status,omitempty"`
-	Jobs   []Job    `json:"jobs,omitempty" yaml:"jobs,omitempty"`
-	Logs   []string `json:"logs,omitempty" yaml:"logs,omitempty"`
+	ID          int64        `json:"-" yaml:"-"`
+	Name        string       `json:"name" yaml:"name"`
+	Title       string       `json:"title" yaml:"title"`
+	DependsOn   []string     `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
+	When        string       `json:"when,omitempty" yaml:"when,omitempty"`
+	Status      string       `json:"status,omitempty" yaml:"status,omitempty"`
+	Jobs        []Job        `json:"jobs,omitempty" yaml:"jobs,omitempty"`
+	Logs        []string     `json:"logs,omitempty" yaml:"logs,omitempty"`
+	Receivers   []Receiver   `json:"receivers,omitempty" yaml:"receivers,omitempty"`
+	Downstreams []Downstream `json:"downstreams,omitempty" yaml:"downstreams,omitempty"`
+
+	flow  *Flow
+	key   string
+	start time.Time
+	end   time.Time
 }
 
-// TODO filter the log print with different color.
+// Log records an INFO log of action.
 func (a *Action) Log(log string, verbose, timestamp bool) {
+	a.LogLevel(model.INFO, log, verbose, timestamp)
+}
+
+// LogLevel records a log of action in the level, the secret values in the log are masked.
+func (a *Action) LogLevel(level, log string, verbose, timestamp bool) {
+	log = a.flow.MaskSecrets(log)
 	a.Logs = append(a.Logs, fmt.Sprintf("[%s] %s", time.Now().String(), log))
 	l := new(model.LogV1)
-	l.Create(model.INFO, model.ACTION, a.ID, log)
+	l.Create(level, model.ACTION, a.ID, a.flow.runNumber(), log)
+	a.flow.sinkLog(level, model.ACTION, a.key, log)
 
-	if verbose == true {
-		if timestamp == true {
-			fmt.Println(Cyan(fmt.Sprintf("[%s] %s", time.Now().String(), strings.TrimSpace(log))))
-		} else {
-			fmt.Println(Cyan(log))
-		}
-	}
+	printLog(level, log, verbose, timestamp)
 }
 
-func (a *Action) Run(verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) (string, error) {
-	a.Status = Running
+func (a *Action) Run(ctx context.Context, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) (string, error) {
+	a.Status, a.flow, a.key = Running, f, fmt.Sprintf("%s.%s", f.Stages[stageIndex].Name, a.Name)
 
 	a.Log(fmt.Sprintf("Action [%s] status change to %s", a.Name, a.Status), false, timestamp)
 	f.Log(fmt.Sprintf("Action [%s] status change to %s", a.Name, a.Status), verbose, timestamp)
+	f.emit(Event{Type: ActionStarted, Path: a.key, Status: a.Status})
 
 	// Save Action into database
 	action := new(model.ActionV1)
 	actionID, err := action.Put(f.Stages[stageIndex].ID, a.Name, a.Title)
 	if err != nil {
-		a.Log(fmt.Sprintf("Save Action [%s] error: %s", a.Name, err.Error()), false, timestamp)
+		a.LogLevel(model.ERROR, fmt.Sprintf("Save Action [%s] error: %s", a.Name, err.Error()), false, timestamp)
 	}
 	a.ID = actionID
 
@@ -58,43 +68,58 @@ func (a *Action) Run(verbose, timestamp bool, f *Flow, stageIndex, actionIndex i
 	actionData := new(model.ActionDataV1)
 	startTime := time.Now()
 
+	result := Success
 	for i, _ := range a.Jobs {
 		job := &a.Jobs[i]
 
-		a.Log(fmt.Sprintf("The Number [%d] job is running: %s", i, a.Title), false, timestamp)
-		f.Log(fmt.Sprintf("The Number [%d] job is running: %s", i, a.Title), verbose, timestamp)
-
-		var status string
-		var err error
-		//If user specific a URL or yaml file in kubectl , excute yaml in kubernetes cluster
-		if job.Kubectl != "" {
-			status, err = job.RunKubectl(a.Name, verbose, timestamp, f, stageIndex, actionIndex)
-
-		} else {
-			status, err = job.Run(a.Name, verbose, timestamp, f, stageIndex, actionIndex)
+		if status := ContextStatus(ctx); status != "" {
+			result = status
+			a.LogLevel(model.WARN, fmt.Sprintf("Action [%s] is %s before the Number [%d] job", a.Name, status, i), false, timestamp)
+			break
 		}
 
-		if err != nil {
-			a.Status = Failure
-			a.Log(fmt.Sprintf("Job [%d] run error: %s", i, err.Error()), false, timestamp)
-			f.Log(fmt.Sprintf("Job [%d] run error: %s", i, err.Error()), verbose, timestamp)
-
+		// The jobs after a failed one still evaluate their when expressions, so on_failure and always jobs could run.
+		if run, err := f.When(job.When, IsStopped(result)); err != nil {
+			job.Status = Failure
+			a.LogLevel(model.ERROR, fmt.Sprintf("Job [%s] when error: %s", job.Name, err.Error()), false, timestamp)
+			f.LogLevel(model.ERROR, fmt.Sprintf("Job [%s] when error: %s", job.Name, err.Error()), verbose, timestamp)
+		} else if !run {
+			job.Status = Skipped
+			a.Log(fmt.Sprintf("Job [%s] is skipped", job.Name), false, timestamp)
+			f.Log(fmt.Sprintf("Job [%s] is skipped", job.Name), verbose, timestamp)
+			continue
 		} else {
-			a.Status = status
+			a.Log(fmt.Sprintf("The Number [%d] job is running: %s", i, a.Title), false, timestamp)
+			f.Log(fmt.Sprintf("The Number [%d] job is running: %s", i, a.Title), verbose, timestamp)
+
+			//If user specific a URL or yaml file in kubectl , excute yaml in kubernetes cluster
+			if len(job.Matrix) > 0 {
+				job.Status, err = job.RunMatrix(ctx, a.Name, verbose, timestamp, f, stageIndex, actionIndex)
+			} else if job.Kubectl != "" {
+				job.Status, err = job.RunKubectl(ctx, a.Name, verbose, timestamp, f, stageIndex, actionIndex)
+			} else {
+				job.Status, err = job.Run(ctx, a.Name, verbose, timestamp, f, stageIndex, actionIndex)
+			}
+
+			if err != nil {
+				job.Status = Failure
+				a.LogLevel(model.ERROR, fmt.Sprintf("Job [%d] run error: %s", i, err.Error()), false, timestamp)
+				f.LogLevel(model.ERROR, fmt.Sprintf("Job [%d] run error: %s", i, err.Error()), verbose, timestamp)
+			}
 		}
 
-		if a.Status == Failure || a.Status == Cancel {
-			break
+		if IsStopped(job.Status) && !IsStopped(result) {
+			result = job.Status
 		}
-
 	}
+	a.Status = result
 
 	currentNumber, err := actionData.GetNumbers(a.ID)
 	if err != nil {
-		f.Log(fmt.Sprintf("Get action Data [%s] Numbers error: %s", a.Name, err.Error()), verbose, timestamp)
+		f.LogLevel(model.ERROR, fmt.Sprintf("Get action Data [%s] Numbers error: %s", a.Name, err.Error()), verbose, timestamp)
 	}
-	if err := actionData.Put(a.ID, currentNumber+1, a.Status, startTime, time.Now()); err != nil {
-		a.Log(fmt.Sprintf("Save Action Data [%s] error: %s", a.Name, err.Error()), false, timestamp)
+	if err := actionData.Put(a.ID, currentNumber+1, f.Number, a.Status, startTime, time.Now()); err != nil {
+		a.LogLevel(model.ERROR, fmt.Sprintf("Save Action Data [%s] error: %s", a.Name, err.Error()), false, timestamp)
 	}
 
 	return a.Status, nil
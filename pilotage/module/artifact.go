@@ -0,0 +1,212 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Huawei/containerops/common/utils"
+	"github.com/Huawei/containerops/pilotage/config"
+)
+
+// DefaultArtifactImage is the image archiving the artifacts in the workspace of the container executors.
+const DefaultArtifactImage = "curlimages/curl:latest"
+
+// Artifact is a file or directory in the workspace archived to the binary repository of Dockyard after the job
+// succeeded, it's uploaded as a tar.gz file and the URL of it is recorded.
+type Artifact struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	Path string `json:"path" yaml:"path"`
+	URL  string `json:"url,omitempty" yaml:"-"`
+}
+
+// ArtifactName returns the name of artifact, it's the base name of the path by default.
+func (a *Artifact) ArtifactName() string {
+	if a.Name == "" {
+		return filepath.Base(a.Path)
+	}
+	return a.Name
+}
+
+// ArtifactURL returns the Dockyard URL of the artifact of job. The artifacts of a run are in the tag
+// `name-tag-number` of the flow repository, and the binary is `stage.action.job.artifact.tar.gz`.
+func (j *Job) ArtifactURL(f *Flow, a *Artifact) (string, error) {
+	dockyard := config.Pilotage.Artifact.Dockyard
+	if dockyard == "" {
+		return "", errors.New("No Dockyard is configured in [pilotage.artifact]")
+	}
+	if !strings.Contains(dockyard, "://") {
+		dockyard = fmt.Sprintf("https://%s", dockyard)
+	}
+
+	namespace, repository, name, err := f.URIs()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/binary/v1/%s/%s/binary/%s-%s-%d/%s.%s.tar.gz", strings.TrimRight(dockyard, "/"),
+		namespace, repository, name, f.Tag, f.Number, j.key, a.ArtifactName()), nil
+}
+
+// ArchiveArtifacts archives the artifacts of job by its executor after the job succeeded, every archiving
+// uses a new container name with the prefix.
+func (j *Job) ArchiveArtifacts(ctx context.Context, prefix string, verbose, timestamp bool, f *Flow) error {
+	executor, err := j.GetExecutor(f)
+	if err != nil {
+		return err
+	}
+	archiver, ok := executor.(ArtifactArchiver)
+	if !ok {
+		return fmt.Errorf("Job executor %s doesn't archive the artifacts", j.executorName(f))
+	}
+
+	for i := range j.Artifacts {
+		a := &j.Artifacts[i]
+		url, err := j.ArtifactURL(f, a)
+		if err != nil {
+			return err
+		}
+
+		containerName := fmt.Sprintf("%s-artifact-%s", prefix, utils.RandomString(10))
+		f.emit(Event{Type: JobCreated, Path: j.key, Status: Pending, Pod: containerName, Executor: j.executorName(f)})
+		if err := archiver.Archive(ctx, containerName, f, j, a, url); err != nil {
+			return fmt.Errorf("Archive artifact [%s] of job [%s] error: %s", a.ArtifactName(), j.Name, err.Error())
+		}
+
+		a.URL = url
+		j.Log(fmt.Sprintf("Artifact [%s] of job [%s] is archived to %s", a.ArtifactName(), j.Name, url), verbose, timestamp)
+	}
+
+	return nil
+}
+
+// ArchivedArtifacts returns the URLs of the archived artifacts of job by name.
+func (j *Job) ArchivedArtifacts() map[string]string {
+	artifacts := map[string]string{}
+	for _, a := range j.Artifacts {
+		if a.URL != "" {
+			artifacts[a.ArtifactName()] = a.URL
+		}
+	}
+	return artifacts
+}
+
+// artifactImage returns the image archiving the artifacts in the container executors.
+func artifactImage() string {
+	if config.Pilotage.Artifact.Image == "" {
+		return DefaultArtifactImage
+	}
+	return config.Pilotage.Artifact.Image
+}
+
+// artifactCommand returns the shell command archiving the artifact in the workspace directory to the URL.
+func artifactCommand(directory string, a *Artifact, url string) string {
+	return fmt.Sprintf("cd %s && tar -czf - %s | curl -fsS -X PUT -H 'Content-Type: text/plain' -H 'Binary-Force: true' -T - %s",
+		shellQuote(directory), shellQuote(a.Path), shellQuote(url))
+}
+
+func shellQuote(s string) string {
+	return fmt.Sprintf("'%s'", strings.Replace(s, "'", `'\''`, -1))
+}
+
+// uploadArtifact archives the artifact in the local workspace directory as a tar.gz file and uploads it to the URL.
+func uploadArtifact(ctx context.Context, directory string, a *Artifact, url string) error {
+	read, write := io.Pipe()
+	go func() {
+		write.CloseWithError(archiveDirectory(write, directory, a.Path))
+	}()
+	defer read.Close()
+
+	req, err := http.NewRequest(http.MethodPut, url, read)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Binary-Force", "true")
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Dockyard responds %s", resp.Status)
+	}
+	return nil
+}
+
+// archiveDirectory writes the path in the directory as a tar.gz file, the names in the archive are relative to the
+// directory like `tar -C directory -czf - path`.
+func archiveDirectory(w io.Writer, directory, path string) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+
+	err := filepath.Walk(filepath.Join(directory, path), func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		if header.Name, err = filepath.Rel(directory, file); err != nil {
+			return err
+		}
+		if header.Name = filepath.ToSlash(header.Name); info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		r, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		_, err = io.Copy(tw, r)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
@@ -0,0 +1,343 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// Status predicates of when expression
+	Always    = "always"
+	OnSuccess = "on_success"
+	OnFailure = "on_failure"
+)
+
+// Condition is a parsed when expression like:
+//
+//	build.compile.go[CHANGED] == "true" && CO_BRANCH != "master"
+//
+// The operands are string literals, flow environments and the outputs of jobs in the format of
+// stage.action.job[KEY], they're compared with == and !=, and combined with &&, || and !.
+// The expression is evaluated only when no step failed unless it uses one of the status
+// predicates always, on_success and on_failure. The steps are the previous stages for a stage,
+// the other actions of the stage for an action and the previous jobs of the action for a job.
+type Condition struct {
+	root       node
+	predicates bool
+}
+
+type node interface {
+	value(f *Flow, failed bool) string
+}
+
+type literal string
+
+type reference string
+
+type predicate string
+
+type not struct {
+	operand node
+}
+
+type binary struct {
+	operator    string
+	left, right node
+}
+
+func (l literal) value(f *Flow, failed bool) string {
+	return string(l)
+}
+
+// The output of job is searched first, then the environment of flow.
+func (r reference) value(f *Flow, failed bool) string {
+	if strings.Contains(string(r), "[") {
+		value, _ := f.GetOutputs().Get(string(r))
+		return value
+	}
+
+	for _, env := range f.Environments {
+		if value, ok := env[string(r)]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+func (p predicate) value(f *Flow, failed bool) string {
+	switch string(p) {
+	case OnSuccess:
+		return fmt.Sprint(!failed)
+	case OnFailure:
+		return fmt.Sprint(failed)
+	}
+	return "true"
+}
+
+func (n not) value(f *Flow, failed bool) string {
+	return fmt.Sprint(!truth(n.operand.value(f, failed)))
+}
+
+func (b binary) value(f *Flow, failed bool) string {
+	switch b.operator {
+	case "==":
+		return fmt.Sprint(b.left.value(f, failed) == b.right.value(f, failed))
+	case "!=":
+		return fmt.Sprint(b.left.value(f, failed) != b.right.value(f, failed))
+	case "&&":
+		return fmt.Sprint(truth(b.left.value(f, failed)) && truth(b.right.value(f, failed)))
+	}
+	return fmt.Sprint(truth(b.left.value(f, failed)) || truth(b.right.value(f, failed)))
+}
+
+func truth(value string) bool {
+	return strings.EqualFold(value, "true")
+}
+
+// ParseCondition parses the when expression, the empty expression is on_success.
+func ParseCondition(expression string) (*Condition, error) {
+	if strings.TrimSpace(expression) == "" {
+		return &Condition{root: predicate(OnSuccess), predicates: true}, nil
+	}
+
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.position < len(p.tokens) {
+		return nil, fmt.Errorf("Unexpected %s in when expression: %s", p.tokens[p.position], expression)
+	}
+
+	return &Condition{root: root, predicates: p.predicates}, nil
+}
+
+// Evaluate returns whether the step should run in the flow, failed is whether any previous step failed.
+func (c *Condition) Evaluate(f *Flow, failed bool) bool {
+	if !c.predicates && failed {
+		return false
+	}
+	return truth(c.root.value(f, failed))
+}
+
+// When parses and evaluates the when expression of a step.
+func (f *Flow) When(expression string, failed bool) (bool, error) {
+	condition, err := ParseCondition(expression)
+	if err != nil {
+		return false, err
+	}
+	return condition.Evaluate(f, failed), nil
+}
+
+// failure records whether any step of the stages or actions running in parallel is failure, cancel or timeout.
+type failure int32
+
+func (fa *failure) Record(status string) {
+	if IsStopped(status) {
+		atomic.StoreInt32((*int32)(fa), 1)
+	}
+}
+
+func (fa *failure) Failed() bool {
+	return atomic.LoadInt32((*int32)(fa)) == 1
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func (t token) String() string {
+	if t.quoted {
+		return fmt.Sprintf("%q", t.text)
+	}
+	return t.text
+}
+
+func tokenize(expression string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		case c == '!' && i+1 < len(expression) && expression[i+1] == '=':
+			tokens = append(tokens, token{text: "!="})
+			i += 2
+		case c == '!':
+			tokens = append(tokens, token{text: "!"})
+			i++
+		case strings.HasPrefix(expression[i:], "=="), strings.HasPrefix(expression[i:], "&&"), strings.HasPrefix(expression[i:], "||"):
+			tokens = append(tokens, token{text: expression[i : i+2]})
+			i += 2
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expression[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("Unterminated string in when expression: %s", expression)
+			}
+			tokens = append(tokens, token{text: expression[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		default:
+			start := i
+			for i < len(expression) && strings.IndexByte(" \t\n\r()!=&|\"'", expression[i]) < 0 {
+				i++
+			}
+			if start == i {
+				return nil, fmt.Errorf("Unexpected %c in when expression: %s", c, expression)
+			}
+			tokens = append(tokens, token{text: expression[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+// parser is a recursive descent parser of the grammar:
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | compare
+//	compare = operand [ ( "==" | "!=" ) operand ]
+//	operand = "(" or ")" | string | name
+type parser struct {
+	tokens     []token
+	position   int
+	predicates bool
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.position < len(p.tokens) {
+		return p.tokens[p.position], true
+	}
+	return token{}, false
+}
+
+func (p *parser) accept(operators ...string) (string, bool) {
+	t, ok := p.peek()
+	if !ok || t.quoted {
+		return "", false
+	}
+	for _, operator := range operators {
+		if t.text == operator {
+			p.position++
+			return operator, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||"); !ok {
+			return left, nil
+		}
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{operator: "||", left: left, right: right}
+	}
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&"); !ok {
+			return left, nil
+		}
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{operator: "&&", left: left, right: right}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	if _, ok := p.accept("!"); ok {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return not{operand: operand}, nil
+	}
+	return p.compare()
+}
+
+func (p *parser) compare() (node, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	if operator, ok := p.accept("==", "!="); ok {
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return binary{operator: operator, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) operand() (node, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("Unexpected end of when expression")
+	}
+	p.position++
+
+	if t.quoted {
+		return literal(t.text), nil
+	}
+
+	switch t.text {
+	case "(":
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, fmt.Errorf("Missing ) in when expression")
+		}
+		return n, nil
+	case ")", "!", "==", "!=", "&&", "||":
+		return nil, fmt.Errorf("Unexpected %s in when expression", t.text)
+	case "true", "false":
+		return literal(t.text), nil
+	case Always, OnSuccess, OnFailure:
+		p.predicates = true
+		return predicate(t.text), nil
+	}
+	return reference(t.text), nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCondition(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow()
+	f.Environments = append(f.Environments, map[string]string{"CO_BRANCH": "master"})
+	f.GetOutputs().Set(0, "build.compile.go[CHANGED]", "true")
+
+	for expression, want := range map[string]bool{
+		"":                                    true,
+		"always":                              true,
+		"on_success":                          true,
+		"on_failure":                          false,
+		"true":                                true,
+		"!false":                              true,
+		`build.compile.go[CHANGED] == "true"`: true,
+		`build.compile.go[CHANGED] != 'true'`: false,
+		`build.compile.go[UNKNOWN] == ""`:     true,
+		`CO_BRANCH == "master" && build.compile.go[CHANGED]`: true,
+		`CO_BRANCH == "dev" || !(CO_FLOW == "flow")`:         false,
+		`on_failure || CO_BRANCH == "master"`:                true,
+	} {
+		condition, err := ParseCondition(expression)
+		if err != nil {
+			t.Errorf("Parse %q error: %s", expression, err.Error())
+			continue
+		}
+		if got := condition.Evaluate(f, false); got != want {
+			t.Errorf("Evaluate %q is %t, want %t", expression, got, want)
+		}
+	}
+
+	for expression, want := range map[string]bool{
+		"":                                    false,
+		"always":                              true,
+		"on_failure":                          true,
+		"on_success":                          false,
+		`build.compile.go[CHANGED] == "true"`: false,
+		`always && CO_BRANCH == "dev"`:        false,
+	} {
+		if got, _ := f.When(expression, true); got != want {
+			t.Errorf("Evaluate %q after failure is %t, want %t", expression, got, want)
+		}
+	}
+}
+
+func TestConditionError(t *testing.T) {
+	for _, expression := range []string{`a ==`, `(a == "b"`, `a = b`, `"open`, `a == b c`, `&& a`} {
+		if _, err := ParseCondition(expression); err == nil {
+			t.Errorf("Parse %q should fail", expression)
+		}
+	}
+}
+
+func TestWhen(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("[COUT] CO_RESULT = skip\n", "fail", "cleanup\n", "notify\n")
+	f.Stages[2].When = `stage0.action0.job0[CO_RESULT] == "true"`
+	f.Stages[3].When = "always"
+	f.Stages[4].When = "on_failure"
+
+	if err := f.LocalRun(context.Background(), false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+
+	if f.Status != Success {
+		t.Errorf("Flow status is %s, want %s", f.Status, Success)
+	}
+	for i, want := range map[int]string{1: Success, 2: Skipped, 3: Success, 4: Skipped} {
+		if f.Stages[i].Status != want {
+			t.Errorf("Stage [%s] status is %s, want %s", f.Stages[i].Name, f.Stages[i].Status, want)
+		}
+	}
+}
+
+func TestWhenOnFailure(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("fail", "next\n", "cleanup\n")
+	f.Stages[2].When = "always"
+	f.Stages[3].When = "on_failure"
+	f.Stages[2].Actions[0].Jobs = append(f.Stages[2].Actions[0].Jobs, Job{Name: "broken", Endpoint: "fail"},
+		Job{Name: "after", Endpoint: "after\n"}, Job{Name: "always", Endpoint: "always\n", When: "always"})
+
+	if err := f.LocalRun(context.Background(), false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+
+	if f.Status != Failure {
+		t.Errorf("Flow status is %s, want %s", f.Status, Failure)
+	}
+	for i, want := range []string{Success, Failure, Skipped, Success} {
+		if job := f.Stages[2].Actions[0].Jobs[i]; job.Status != want {
+			t.Errorf("Job [%s] status is %s, want %s", job.Name, job.Status, want)
+		}
+	}
+	for i, want := range map[int]string{2: Failure, 3: Success, 4: Skipped} {
+		if f.Stages[i].Status != want {
+			t.Errorf("Stage [%s] status is %s, want %s", f.Stages[i].Name, f.Stages[i].Status, want)
+		}
+	}
+}
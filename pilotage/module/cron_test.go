@@ -0,0 +1,68 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// 2017-06-12 is a Monday.
+	from := time.Date(2017, 6, 12, 10, 30, 15, 0, time.UTC)
+
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"0 2 * * *", time.Date(2017, 6, 13, 2, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2017, 6, 12, 10, 45, 0, 0, time.UTC)},
+		{"31 10 * * *", time.Date(2017, 6, 12, 10, 31, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2017, 6, 13, 10, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * mon-fri", time.Date(2017, 6, 12, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2017, 6, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2017, 6, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Either the day of month or the day of week matches when both are restricted.
+		{"0 0 15 * fri", time.Date(2017, 6, 15, 0, 0, 0, 0, time.UTC)},
+		{"5,10 12 * * *", time.Date(2017, 6, 12, 12, 5, 0, 0, time.UTC)},
+		{"@daily", time.Date(2017, 6, 13, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2017, 6, 12, 11, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, c := range cases {
+		cron, err := ParseCron(c.spec)
+		if err != nil {
+			t.Errorf("Parse %q error: %s", c.spec, err.Error())
+			continue
+		}
+		if next := cron.Next(from); !next.Equal(c.want) {
+			t.Errorf("Next of %q is %s, want %s", c.spec, next, c.want)
+		}
+	}
+}
+
+func TestParseCronError(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *",
+		"*/0 * * * *", "5-1 * * * *", "* * * foo *", "@weekday"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("Parse %q should be error", spec)
+		}
+	}
+}
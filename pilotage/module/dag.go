@@ -0,0 +1,186 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Dependencies returns the indexes of nodes which every node depends on. A node without
+// depends_on depends on all nodes before it when chained is true, otherwise it depends on nothing.
+// It returns error when a node depends on an unknown node or the dependencies have a cycle.
+func Dependencies(names []string, dependsOn [][]string, chained bool) ([][]int, error) {
+	indexes := make(map[string]int, len(names))
+	for i, name := range names {
+		if _, ok := indexes[name]; ok {
+			return nil, fmt.Errorf("Duplicate name: %s", name)
+		}
+		indexes[name] = i
+	}
+
+	result := make([][]int, len(names))
+	for i, name := range names {
+		if len(dependsOn[i]) == 0 {
+			if chained {
+				for j := 0; j < i; j++ {
+					result[i] = append(result[i], j)
+				}
+			}
+			continue
+		}
+
+		for _, depend := range dependsOn[i] {
+			index, ok := indexes[depend]
+			if !ok {
+				return nil, fmt.Errorf("%s depends on unknown %s", name, depend)
+			}
+			if index == i {
+				return nil, fmt.Errorf("%s depends on itself", name)
+			}
+			result[i] = append(result[i], index)
+		}
+	}
+
+	// Depth first search for the cycle.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make([]int, len(names))
+	path := []string{}
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch states[i] {
+		case visiting:
+			return fmt.Errorf("Dependency cycle: %s -> %s", strings.Join(path, " -> "), names[i])
+		case visited:
+			return nil
+		}
+
+		states[i] = visiting
+		path = append(path, names[i])
+		for _, depend := range result[i] {
+			if err := visit(depend); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		states[i] = visited
+
+		return nil
+	}
+
+	for i := range names {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// upstreamFailed returns whether a node which the node depends on directly or transitively is failure, cancel or
+// timeout. The `when` of node is decided by the failures of its upstreams, so the independent branches and the
+// parallelism don't change it.
+func upstreamFailed(dependencies [][]int, index int, status func(index int) string) bool {
+	visited := make([]bool, len(dependencies))
+	queue := append([]int{}, dependencies[index]...)
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		if visited[i] {
+			continue
+		}
+		visited[i] = true
+
+		if IsStopped(status(i)) {
+			return true
+		}
+		queue = append(queue, dependencies[i]...)
+	}
+	return false
+}
+
+type dagResult struct {
+	index  int
+	status string
+}
+
+// RunDAG runs the nodes after their dependencies finished, at most parallelism nodes are running at the
+// same time and zero parallelism means no limit. It returns the status of the first failure, cancel or timeout
+// node, or the one of context. The nodes still run after a failure, so the independent branches finish and the
+// others could skip themselves or run on failure. When failFast is true, the failure cancels the running nodes
+// and the nodes not started yet run with the cancelled context, so all the unfinished nodes are cancelled
+// whatever the parallelism is.
+func RunDAG(ctx context.Context, dependencies [][]int, parallelism int, failFast bool, run func(ctx context.Context, index int) string) string {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	started := make([]bool, len(dependencies))
+	finished := make([]bool, len(dependencies))
+	results := make(chan dagResult, len(dependencies))
+	running, status := 0, Success
+
+	for {
+		if s := ContextStatus(ctx); s != "" && !IsStopped(status) {
+			status = s
+		}
+
+		if ContextStatus(ctx) == "" {
+			for i, depends := range dependencies {
+				if started[i] || (parallelism > 0 && running >= parallelism) {
+					continue
+				}
+
+				ready := true
+				for _, depend := range depends {
+					if !finished[depend] {
+						ready = false
+						break
+					}
+				}
+
+				if ready {
+					started[i] = true
+					running++
+					go func(index int) {
+						results <- dagResult{index: index, status: run(runCtx, index)}
+					}(i)
+				}
+			}
+		}
+
+		if running == 0 {
+			return status
+		}
+
+		result := <-results
+		running--
+		finished[result.index] = true
+
+		if IsStopped(result.status) && !IsStopped(status) {
+			status = result.status
+			if failFast {
+				cancel()
+			}
+		}
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDependencies(t *testing.T) {
+	names := []string{"start", "build", "lint", "test", "end"}
+
+	dependencies, err := Dependencies(names, [][]string{nil, nil, {"start"}, {"build"}, nil}, true)
+	if err != nil {
+		t.Fatalf("Dependencies error: %s", err.Error())
+	}
+
+	want := [][]int{nil, {0}, {0}, {1}, {0, 1, 2, 3}}
+	if !reflect.DeepEqual(dependencies, want) {
+		t.Errorf("Dependencies are %v, want %v", dependencies, want)
+	}
+
+	if dependencies, _ := Dependencies(names, make([][]string, len(names)), false); !reflect.DeepEqual(dependencies, make([][]int, len(names))) {
+		t.Errorf("Dependencies without chained are %v", dependencies)
+	}
+}
+
+func TestDependenciesError(t *testing.T) {
+	tests := []struct {
+		dependsOn [][]string
+		err       string
+	}{
+		{[][]string{nil, {"unknown"}, nil}, "unknown"},
+		{[][]string{nil, {"b"}, nil}, "itself"},
+		{[][]string{{"c"}, {"a"}, {"b"}}, "cycle"},
+	}
+
+	for _, test := range tests {
+		if _, err := Dependencies([]string{"a", "b", "c"}, test.dependsOn, false); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Dependencies %v error is %v, want %s", test.dependsOn, err, test.err)
+		}
+	}
+}
+
+func TestParseFlowWithCycle(t *testing.T) {
+	file, err := ioutil.TempFile("", "flow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	file.WriteString(`
+uri: containerops/test/flow
+tag: latest
+stages:
+  - type: normal
+    name: build
+    sequencing: parallel
+    depends_on: [test]
+  - type: normal
+    name: test
+    sequencing: parallel
+    depends_on: [build]
+`)
+	file.Close()
+
+	newFakeExecutor()
+
+	f := new(Flow)
+	if err := f.ParseFlowFromFile(file.Name(), CliRun, false, false); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Parse flow with cycle error is %v", err)
+	}
+}
+
+// barrierExecutor blocks the jobs until all of them are running.
+type barrierExecutor struct {
+	wg      sync.WaitGroup
+	release chan struct{}
+}
+
+func (e *barrierExecutor) Execute(ctx context.Context, j *Job, containerName string, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) error {
+	e.wg.Done()
+
+	select {
+	case <-e.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestRunDAGParallel(t *testing.T) {
+	newFakeExecutor()
+
+	executor := &barrierExecutor{release: make(chan struct{})}
+	executor.wg.Add(2)
+	JobExecutors["barrier"] = executor
+	go func() {
+		executor.wg.Wait()
+		close(executor.release)
+	}()
+
+	f := newTestFlow("first\n", "second\n")
+	f.Executor, f.Timeout = "barrier", 5
+	f.Stages[1].DependsOn = []string{"start"}
+	f.Stages[2].DependsOn = []string{"start"}
+	f.Stages[2].Actions[0].Jobs[0].Subscriptions = nil
+
+	f.LocalRun(context.Background(), false, false)
+
+	if f.Status != Success {
+		t.Errorf("Flow status is %s, want %s", f.Status, Success)
+	}
+}
+
+func TestRunDAGParallelism(t *testing.T) {
+	dependencies := [][]int{nil, nil, nil, nil}
+
+	var lock sync.Mutex
+	running, max := 0, 0
+	status := RunDAG(context.Background(), dependencies, 2, false, func(ctx context.Context, index int) string {
+		lock.Lock()
+		running++
+		if running > max {
+			max = running
+		}
+		lock.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		lock.Lock()
+		running--
+		lock.Unlock()
+
+		return Success
+	})
+
+	if status != Success {
+		t.Errorf("Status is %s, want %s", status, Success)
+	}
+	if max > 2 {
+		t.Errorf("Max running nodes is %d, want at most 2", max)
+	}
+}
+
+func TestRunDAGFailure(t *testing.T) {
+	dependencies := [][]int{nil, {0}, nil}
+
+	// The statuses of nodes after a failure are the same whatever the parallelism is.
+	for _, failFast := range []bool{false, true} {
+		for _, parallelism := range []int{0, 1} {
+			var lock sync.Mutex
+			ran := map[int]string{}
+			status := RunDAG(context.Background(), dependencies, parallelism, failFast, func(ctx context.Context, index int) string {
+				status := Success
+				switch index {
+				case 0:
+					status = Failure
+				case 1:
+					if ctx.Err() != nil {
+						status = ContextStatus(ctx)
+					}
+				case 2:
+					select {
+					case <-ctx.Done():
+						status = ContextStatus(ctx)
+					case <-time.After(100 * time.Millisecond):
+					}
+				}
+
+				lock.Lock()
+				ran[index] = status
+				lock.Unlock()
+
+				return status
+			})
+
+			if status != Failure {
+				t.Errorf("Status of fail fast %v and parallelism %d is %s, want %s", failFast, parallelism, status, Failure)
+			}
+			want := Success
+			if failFast {
+				want = Cancel
+			}
+			for _, index := range []int{1, 2} {
+				if ran[index] != want {
+					t.Errorf("Node %d of fail fast %v and parallelism %d is %s, want %s", index, failFast, parallelism, ran[index], want)
+				}
+			}
+		}
+	}
+}
+
+func TestFlowFailFast(t *testing.T) {
+	newFakeExecutor()
+
+	for _, failFast := range []bool{false, true} {
+		f := newTestFlow("fail", "second\n")
+		f.FailFast, f.Parallelism = failFast, 1
+		f.Stages[1].DependsOn = []string{"start"}
+		f.Stages[2].DependsOn = []string{"start"}
+		f.Stages[2].Actions[0].Jobs[0].Subscriptions = nil
+
+		f.LocalRun(context.Background(), false, false)
+
+		want := Success
+		if failFast {
+			want = Cancel
+		}
+		if f.Status != Failure {
+			t.Errorf("Flow status of fail fast %v is %s, want %s", failFast, f.Status, Failure)
+		}
+		if f.Stages[2].Status != want {
+			t.Errorf("The independent stage of fail fast %v is %s, want %s", failFast, f.Stages[2].Status, want)
+		}
+	}
+}
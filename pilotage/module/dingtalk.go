@@ -0,0 +1,100 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("dingtalk", &DingTalkNotifier{})
+}
+
+// DingTalkNotifier sends the message in markdown to the robot of DingTalk, the address of receiver is the
+// webhook URL with access token. The secret of receiver signs the request when the robot enables the signature.
+type DingTalkNotifier struct {
+}
+
+type dingTalkResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (d *DingTalkNotifier) Notify(flow *Flow, n Notification, receiver Receiver) error {
+	message, err := receiver.Message(n)
+	if err != nil {
+		return err
+	}
+
+	address := receiver.Address
+	if receiver.Secret != "" {
+		if address, err = DingTalkSign(address, receiver.Secret, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	// The line break of DingTalk markdown is two spaces in the end of line.
+	body, _ := json.Marshal(map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": fmt.Sprintf("Flow %s is %s", n.Subject(), n.Status),
+			"text":  strings.Replace(message, "\n", "  \n", -1),
+		},
+	})
+
+	data, err := postHTTP(notifyClient, address, "application/json", body, nil)
+	if err != nil {
+		return err
+	}
+
+	result := dingTalkResponse{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("Unmarshal DingTalk response error: %s", err.Error())
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("DingTalk error %d: %s", result.ErrCode, result.ErrMsg)
+	}
+
+	return nil
+}
+
+// DingTalkSign adds the timestamp and sign to the webhook URL, the sign is the base64 HMAC-SHA256 of
+// `timestamp\nsecret` with the secret.
+func DingTalkSign(address, secret string, now time.Time) (string, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", err
+	}
+
+	timestamp := fmt.Sprintf("%d", now.UnixNano()/int64(time.Millisecond))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%s\n%s", timestamp, secret)))
+
+	query := u.Query()
+	query.Set("timestamp", timestamp)
+	query.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
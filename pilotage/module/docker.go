@@ -0,0 +1,146 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+// DockerJobExecutor runs job as a container of the local Docker engine with the docker command.
+type DockerJobExecutor struct {
+}
+
+func (d *DockerJobExecutor) Execute(ctx context.Context, j *Job, containerName string, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) error {
+	args := []string{"run", "--rm", "--name", containerName}
+
+	// The resources of container are limited by the limits of job, or the requests without limits.
+	cpuQuantity, memoryQuantity := j.Resources.CPU, j.Resources.Memory
+	if limits := j.Resources.Limits; limits != nil {
+		if limits.CPU != "" {
+			cpuQuantity = limits.CPU
+		}
+		if limits.Memory != "" {
+			memoryQuantity = limits.Memory
+		}
+	}
+
+	if cpuQuantity != "" {
+		cpu, err := resource.ParseQuantity(cpuQuantity)
+		if err != nil {
+			return err
+		}
+		args = append(args, "--cpus", fmt.Sprintf("%.3f", float64(cpu.MilliValue())/1000))
+	}
+
+	if memoryQuantity != "" {
+		memory, err := resource.ParseQuantity(memoryQuantity)
+		if err != nil {
+			return err
+		}
+		args = append(args, "--memory", fmt.Sprintf("%d", memory.Value()))
+	}
+
+	for _, env := range j.EnvVars(f) {
+		args = append(args, "--env", fmt.Sprintf("%s=%s", env.Name, env.Value))
+	}
+
+	if f.Workspace != nil && f.workspace != "" {
+		args = append(args, "--volume", fmt.Sprintf("%s:%s", f.workspace, f.Workspace.MountPath()))
+	}
+
+	// The values of secrets are passed by the environments of docker command, so they're not in its arguments.
+	secrets := os.Environ()
+	for _, env := range j.SecretEnvs(f) {
+		args = append(args, "--env", env.Name)
+		secrets = append(secrets, fmt.Sprintf("%s=%s", env.Name, env.Value))
+	}
+
+	// The command replaces the entrypoint of image like the command of Kubernetes container. The options end
+	// before the image, so an endpoint like `--privileged` isn't an option of docker.
+	if len(j.Command) > 0 {
+		args = append(args, "--entrypoint", j.Command[0], "--", j.Endpoint)
+		args = append(args, j.Command[1:]...)
+	} else {
+		args = append(args, "--", j.Endpoint)
+	}
+	args = append(args, j.Args...)
+
+	// Killing the docker command doesn't stop the container, remove it when the job timeout or cancelled.
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			if IsClosed(finished) {
+				return
+			}
+			if err := exec.Command("docker", "rm", "--force", containerName).Run(); err != nil {
+				j.LogLevel(model.ERROR, fmt.Sprintf("Remove container %s error: %s", containerName, err.Error()), verbose, timestamp)
+			}
+		case <-finished:
+		}
+	}()
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Env = secrets
+	return j.RunCommand(ctx, cmd, verbose, timestamp, f, stageIndex, actionIndex)
+}
+
+// Clean removes the container of job or the volume of workspace, the ones already removed are ignored.
+func (d *DockerJobExecutor) Clean(f *Flow, containerName string) error {
+	if output, err := exec.Command("docker", "rm", "--force", containerName).CombinedOutput(); err != nil &&
+		!strings.Contains(string(output), "No such container") {
+		return fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(string(output)))
+	}
+	return d.DeleteWorkspace(f, containerName)
+}
+
+// CreateWorkspace creates the volume of workspace in the local Docker engine, the size and storage class
+// of workspace are ignored.
+func (d *DockerJobExecutor) CreateWorkspace(f *Flow, name string) error {
+	if output, err := exec.Command("docker", "volume", "create", name).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// DeleteWorkspace removes the volume of workspace, the volume already removed is ignored.
+func (d *DockerJobExecutor) DeleteWorkspace(f *Flow, name string) error {
+	if output, err := exec.Command("docker", "volume", "rm", "--force", name).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Archive runs a container mounting the workspace volume to upload the artifact.
+func (d *DockerJobExecutor) Archive(ctx context.Context, containerName string, f *Flow, j *Job, a *Artifact, url string) error {
+	cmd := exec.CommandContext(ctx, "docker", "run", "--rm", "--name", containerName,
+		"--volume", fmt.Sprintf("%s:%s", f.workspace, f.Workspace.MountPath()),
+		"--entrypoint", "sh", artifactImage(), "-c", artifactCommand(f.Workspace.MountPath(), a, url))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Huawei/containerops/pilotage/config"
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+// Downstream is a flow triggered after the stage or action finished and the when expression is true, the
+// outputs of jobs subscribed in `stage.action.job[KEY]: ENV` format are passed as the environments of it.
+// The flow is the file relative to the one of current flow, or found by the URI and tag in the flow base
+// directory `FlowBaseDir/namespace/repository/*.yml`.
+type Downstream struct {
+	URI          string              `json:"uri" yaml:"uri"`
+	Tag          string              `json:"tag" yaml:"tag"`
+	File         string              `json:"file,omitempty" yaml:"file,omitempty"`
+	When         string              `json:"when,omitempty" yaml:"when,omitempty"`
+	Environments []map[string]string `json:"environments,omitempty" yaml:"environments,omitempty"`
+	Outputs      []map[string]string `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+}
+
+// Upstream is the run of flow triggering the flow by the downstream of stage or action in the path.
+type Upstream struct {
+	URI    string `json:"uri"`
+	Tag    string `json:"tag"`
+	Number int64  `json:"number"`
+	Path   string `json:"path"`
+
+	flowID int64
+	chain  []string
+}
+
+// GetUpstream returns the run triggering the flow, it's nil when the flow isn't triggered by a downstream.
+func (f *Flow) GetUpstream() *Upstream {
+	return f.upstream
+}
+
+// downstreamTrigger returns the function running the downstream flows of the stage or action finished in the
+// event, the failure deciding the `when` of downstreams is the one when the event is published.
+func (f *Flow) downstreamTrigger(e Event, verbose, timestamp bool) func() {
+	for i := range f.Stages {
+		stage := &f.Stages[i]
+		if e.Type == StageFinished && e.Path == stage.Name {
+			failed := f.failed.Failed()
+			return func() { f.runDownstreams(stage.Downstreams, e.Path, failed, verbose, timestamp) }
+		}
+
+		for j := range stage.Actions {
+			action := &stage.Actions[j]
+			if e.Type == ActionFinished && e.Path == fmt.Sprintf("%s.%s", stage.Name, action.Name) {
+				failed := stage.failed.Failed()
+				return func() { f.runDownstreams(action.Downstreams, e.Path, failed, verbose, timestamp) }
+			}
+		}
+	}
+	return func() {}
+}
+
+func (f *Flow) runDownstreams(downstreams []Downstream, path string, failed, verbose, timestamp bool) {
+	for i := range downstreams {
+		downstream := &downstreams[i]
+
+		if run, err := f.When(downstream.When, failed); err != nil {
+			f.LogLevel(model.ERROR, fmt.Sprintf("Downstream [%s:%s] of %s when error: %s", downstream.URI, downstream.Tag, path, err.Error()), verbose, timestamp)
+			continue
+		} else if !run {
+			f.LogLevel(model.DEBUG, fmt.Sprintf("Downstream [%s:%s] of %s is skipped", downstream.URI, downstream.Tag, path), verbose, timestamp)
+			continue
+		}
+
+		child, err := f.Downstream(downstream, path)
+		if err != nil {
+			f.LogLevel(model.ERROR, fmt.Sprintf("Trigger downstream [%s:%s] of %s error: %s", downstream.URI, downstream.Tag, path, err.Error()), verbose, timestamp)
+			continue
+		}
+
+		f.Log(fmt.Sprintf("Flow [%s] triggers downstream [%s:%s] of %s", f.URI, child.URI, child.Tag, path), verbose, timestamp)
+		if q := GetRunQueue(); q != nil {
+			if _, err := q.Enqueue(child); err != nil {
+				f.LogLevel(model.ERROR, fmt.Sprintf("Queue downstream [%s:%s] error: %s", child.URI, child.Tag, err.Error()), verbose, timestamp)
+			}
+			continue
+		}
+		f.downstreams.Add(1)
+		go func() {
+			defer f.downstreams.Done()
+			child.LocalRun(context.Background(), verbose, timestamp)
+			child.WaitDownstreams()
+		}()
+	}
+}
+
+// WaitDownstreams waits until the downstream flows run without the queue are finished, including their downstream
+// flows. The cli exits after them, otherwise they're killed with the process.
+func (f *Flow) WaitDownstreams() {
+	f.downstreams.Wait()
+}
+
+// Downstream loads the downstream flow triggered by the stage or action in the path, the environments and
+// subscribed outputs of the downstream and the CO_UPSTREAM_* environments are appended to the flow.
+// The flow already in the chain of upstreams isn't triggered again.
+func (f *Flow) Downstream(downstream *Downstream, path string) (*Flow, error) {
+	chain := []string{fmt.Sprintf("%s:%s", f.URI, f.Tag)}
+	if f.upstream != nil {
+		chain = append(f.upstream.chain, chain...)
+	}
+	for _, uri := range chain {
+		if uri == fmt.Sprintf("%s:%s", downstream.URI, downstream.Tag) {
+			return nil, fmt.Errorf("Flow [%s] is already triggered in the upstreams", uri)
+		}
+	}
+
+	child, err := f.loadDownstream(downstream)
+	if err != nil {
+		return nil, err
+	}
+
+	child.Environments = append(child.Environments, downstream.Environments...)
+	for _, subscription := range downstream.Outputs {
+		for key, env := range subscription {
+			if value, ok := f.GetOutputs().Get(key); ok {
+				child.Environments = append(child.Environments, map[string]string{env: value})
+			}
+		}
+	}
+	child.Environments = append(child.Environments, map[string]string{
+		"CO_UPSTREAM_URI":    f.URI,
+		"CO_UPSTREAM_TAG":    f.Tag,
+		"CO_UPSTREAM_NUMBER": strconv.FormatInt(f.Number, 10),
+		"CO_UPSTREAM_PATH":   path,
+	})
+
+	child.upstream = &Upstream{URI: f.URI, Tag: f.Tag, Number: f.Number, Path: path, flowID: f.ID, chain: chain}
+	return child, nil
+}
+
+// loadDownstream parses the flow file of downstream and checks its URI and tag.
+func (f *Flow) loadDownstream(downstream *Downstream) (*Flow, error) {
+	files := []string{}
+	if downstream.File != "" {
+		file := downstream.File
+		if !filepath.IsAbs(file) && f.file != "" {
+			file = filepath.Join(filepath.Dir(f.file), file)
+		}
+		files = append(files, file)
+	} else if config.WebHook.FlowBaseDir != "" {
+		namespace, repository, _, err := (&Flow{URI: downstream.URI}).URIs()
+		if err != nil {
+			return nil, err
+		}
+		if files, err = filepath.Glob(filepath.Join(config.WebHook.FlowBaseDir, namespace, repository, "*.yml")); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, file := range files {
+		child := &Flow{}
+		if data, err := ioutil.ReadFile(file); err != nil {
+			return nil, err
+		} else if err := child.ParseFlow(data); err != nil {
+			return nil, fmt.Errorf("Unmarshal the flow file %s error: %s", file, err.Error())
+		}
+		if child.URI != downstream.URI || child.Tag != downstream.Tag {
+			continue
+		}
+
+		if errs := child.Validate(); len(errs) > 0 {
+			return nil, fmt.Errorf("Validate the flow file %s error:\n%s", file, errs.Error())
+		}
+		child.Model, child.Number, child.Status, child.file = f.Model, 1, Pending, file
+		return child, nil
+	}
+
+	return nil, fmt.Errorf("Flow [%s:%s] is not found", downstream.URI, downstream.Tag)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Huawei/containerops/pilotage/config"
+)
+
+const downstreamFlow = `uri: containerops/test/deploy
+tag: latest
+executor: fake
+stages:
+  - type: start
+    name: start
+  - type: normal
+    name: deploy
+    sequencing: sequence
+    actions:
+      - name: deploy
+        jobs:
+          - name: deploy
+            endpoint: "deployed\n"
+  - type: end
+    name: end
+`
+
+func newDownstreamDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "downstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(dir, "containerops", "test"), 0755)
+	if err := ioutil.WriteFile(filepath.Join(dir, "containerops", "test", "deploy.yml"), []byte(downstreamFlow), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestDownstreams(t *testing.T) {
+	executor := newFakeExecutor()
+	dir := newDownstreamDir(t)
+	defer os.RemoveAll(dir)
+
+	f := newTestFlow("[COUT] CO_RESULT = first\n")
+	f.file = filepath.Join(dir, "containerops", "build.yml")
+	f.Stages[1].Actions[0].Downstreams = []Downstream{{URI: "containerops/test/deploy", Tag: "latest", When: OnFailure}}
+	f.Stages[2].Downstreams = []Downstream{{URI: "containerops/test/deploy", Tag: "latest", File: "test/deploy.yml",
+		Environments: []map[string]string{{"CO_TARGET": "production"}},
+		Outputs:      []map[string]string{{"stage0.action0.job0[CO_RESULT]": "CO_VERSION"}}}}
+
+	finished := make(chan Event, 2)
+	id := Events.Subscribe(func(e Event) {
+		if e.Type == FlowFinished && e.URI == "containerops/test/deploy" {
+			finished <- e
+		}
+	})
+	defer Events.Unsubscribe(id)
+
+	if err := f.LocalRun(context.Background(), false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+
+	// The cli waits for the downstream flows before exit.
+	waited := make(chan struct{})
+	go func() {
+		f.WaitDownstreams()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The downstream flow isn't finished")
+	}
+
+	select {
+	case e := <-finished:
+		if e.Status != Success {
+			t.Errorf("The status of downstream flow is %s", e.Status)
+		}
+	default:
+		t.Fatal("The downstream flow isn't finished when the waiting returns")
+	}
+
+	executor.lock.Lock()
+	envs, runs := executor.envs["deploy"], executor.runs["deploy"]
+	executor.lock.Unlock()
+	if runs != 1 {
+		t.Errorf("The downstream flow runs %d times", runs)
+	}
+	for key, value := range map[string]string{"CO_VERSION": "first", "CO_TARGET": "production",
+		"CO_UPSTREAM_URI": "containerops/test/flow", "CO_UPSTREAM_PATH": "end"} {
+		if envs[key] != value {
+			t.Errorf("The environment %s of downstream job is %q, want %q", key, envs[key], value)
+		}
+	}
+}
+
+func TestDownstreamFlowBaseDir(t *testing.T) {
+	dir := newDownstreamDir(t)
+	defer os.RemoveAll(dir)
+
+	base := config.WebHook.FlowBaseDir
+	config.WebHook.FlowBaseDir = dir
+	defer func() { config.WebHook.FlowBaseDir = base }()
+
+	f := newTestFlow()
+	f.Number = 3
+	child, err := f.Downstream(&Downstream{URI: "containerops/test/deploy", Tag: "latest"}, "end")
+	if err != nil {
+		t.Fatalf("Load downstream flow error: %s", err.Error())
+	}
+	if upstream := child.GetUpstream(); upstream == nil || upstream.URI != f.URI || upstream.Number != 3 || upstream.Path != "end" {
+		t.Errorf("The upstream of downstream flow is %+v", upstream)
+	}
+
+	if _, err := f.Downstream(&Downstream{URI: "containerops/test/deploy", Tag: "v1"}, "end"); err == nil ||
+		!strings.Contains(err.Error(), "not found") {
+		t.Errorf("The downstream flow of unknown tag should not be found: %v", err)
+	}
+
+	// The flow triggered in the upstreams isn't triggered again.
+	if _, err := child.Downstream(&Downstream{URI: f.URI, Tag: f.Tag}, "end"); err == nil {
+		t.Error("The upstream flow should not be triggered by the downstream")
+	}
+	if _, err := child.Downstream(&Downstream{URI: child.URI, Tag: child.Tag}, "end"); err == nil {
+		t.Error("The flow should not trigger itself")
+	}
+}
+
+func TestValidateDownstreams(t *testing.T) {
+	f := newTestFlow("first\n")
+	f.Stages[2].Downstreams = []Downstream{
+		{URI: "containerops/test/deploy", Tag: "latest", Outputs: []map[string]string{{"stage0.action0.job0[CO_RESULT]": "CO_VERSION"}}},
+		{URI: "deploy", When: "(", Outputs: []map[string]string{{"stage0.action0.job0[UNKNOWN]": "1ENV"}}},
+	}
+
+	paths := map[string]bool{}
+	for _, e := range f.Validate() {
+		paths[e.Path] = true
+	}
+	for _, path := range []string{"stages[2].downstreams[1].uri", "stages[2].downstreams[1].tag",
+		"stages[2].downstreams[1].when", "stages[2].downstreams[1].outputs[0]"} {
+		if !paths[path] {
+			t.Errorf("Validation errors %v should contain %s", paths, path)
+		}
+	}
+	for path := range paths {
+		if strings.HasPrefix(path, "stages[2].downstreams[0]") {
+			t.Errorf("The downstream should be valid, but errors are %v", paths)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Huawei/containerops/pilotage/config"
+)
+
+const defaultElasticsearchIndex = "pilotage"
+
+func init() {
+	RegisterLogSink(ElasticsearchSink, NewElasticsearchLogSink)
+}
+
+// ElasticsearchLogSink indexes the logs with the `_bulk` API, which is also supported by OpenSearch.
+type ElasticsearchLogSink struct {
+	config config.SinkConfig
+	client *http.Client
+}
+
+func NewElasticsearchLogSink(c config.SinkConfig) (LogSink, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("The url of elasticsearch log sink is required")
+	}
+	if c.Index == "" {
+		c.Index = defaultElasticsearchIndex
+	}
+
+	return &ElasticsearchLogSink{config: c, client: &http.Client{Timeout: sinkRequestTimeout}}, nil
+}
+
+// bulkResponse is the result of `_bulk` API, the errors is true when any log fails.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+func (s *ElasticsearchLogSink) Write(entries []LogEntry) error {
+	action, _ := json.Marshal(map[string]map[string]string{"index": {"_index": s.config.Index}})
+
+	body := bytes.NewBuffer(nil)
+	for _, entry := range entries {
+		doc, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(doc)
+		body.WriteByte('\n')
+	}
+
+	url := fmt.Sprintf("%s/_bulk", strings.TrimSuffix(s.config.URL, "/"))
+	data, err := postSink(s.client, s.config, url, "application/x-ndjson", body.Bytes(), nil)
+	if err != nil {
+		return err
+	}
+
+	result := bulkResponse{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("Unmarshal bulk response error: %s", err.Error())
+	}
+	if result.Errors {
+		failed, reason := 0, ""
+		for _, item := range result.Items {
+			for _, r := range item {
+				if r.Status >= http.StatusBadRequest {
+					if failed++; reason == "" {
+						reason = string(r.Error)
+					}
+				}
+			}
+		}
+		return fmt.Errorf("Index %d of %d logs failed: %s", failed, len(entries), reason)
+	}
+
+	return nil
+}
+
+func (s *ElasticsearchLogSink) Close() error {
+	return nil
+}
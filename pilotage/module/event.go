@@ -0,0 +1,212 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+const (
+	// Event Type
+	FlowStarted    = "flow.started"
+	FlowFinished   = "flow.finished"
+	StageStarted   = "stage.started"
+	StageFinished  = "stage.finished"
+	ActionStarted  = "action.started"
+	ActionFinished = "action.finished"
+	JobCreated     = "job.created"
+	JobScheduled   = "job.scheduled"
+	JobOutput      = "job.output"
+
+	WorkspaceCreated = "workspace.created"
+)
+
+// Event is a change of flow run. The path is `stage`, `stage.action` or `stage.action.job` of the stage, action
+// or job events, the executor and pod are the container of job.created or the volume of workspace.created,
+// the pod and node are the ones of job.scheduled and the line is the one of job.output.
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	URI      string    `json:"uri"`
+	Tag      string    `json:"tag"`
+	Number   int64     `json:"number"`
+	Path     string    `json:"path,omitempty"`
+	Status   string    `json:"status,omitempty"`
+	Pod      string    `json:"pod,omitempty"`
+	Node     string    `json:"node,omitempty"`
+	Line     string    `json:"line,omitempty"`
+	Executor string    `json:"executor,omitempty"`
+
+	flow *Flow
+}
+
+// EventHandler handles the events of bus. It's called in the goroutine publishing the event, so it should
+// return quickly and be safe for concurrent use, the slow work is handed to a goroutine like the eventWorker.
+type EventHandler func(e Event)
+
+type eventSubscriber struct {
+	id      int64
+	handler EventHandler
+}
+
+// EventBus delivers the events of flow runs to the subscribers in the order of subscription.
+type EventBus struct {
+	lock        sync.RWMutex
+	subscribers []eventSubscriber
+	next        int64
+}
+
+// Events is the event bus of all the flow runs.
+var Events = NewEventBus()
+
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe adds a handler of all events, and returns the id to unsubscribe.
+func (b *EventBus) Subscribe(handler EventHandler) int64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.next++
+	b.subscribers = append(b.subscribers, eventSubscriber{id: b.next, handler: handler})
+	return b.next
+}
+
+// Unsubscribe removes the handler of id.
+func (b *EventBus) Unsubscribe(id int64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for i, s := range b.subscribers {
+		if s.id == id {
+			b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Publish calls the handlers with the event, a handler panics doesn't stop the others and the flow run.
+func (b *EventBus) Publish(e Event) {
+	b.lock.RLock()
+	subscribers := b.subscribers
+	b.lock.RUnlock()
+
+	for _, s := range subscribers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					printLog(model.ERROR, fmt.Sprintf("Handle event %s of [%s] error: %v", e.Type, e.URI, r), true, true)
+				}
+			}()
+			s.handler(e)
+		}()
+	}
+}
+
+// eventWorker calls the functions handling the events of a flow run in order in its own goroutine, so the slow
+// handlers like the notifications don't block the stages and actions publishing the events.
+type eventWorker struct {
+	lock   sync.Mutex
+	queue  []func()
+	closed bool
+	signal chan struct{}
+	done   chan struct{}
+}
+
+func newEventWorker() *eventWorker {
+	w := &eventWorker{signal: make(chan struct{}, 1), done: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+// Add queues the function without waiting for the previous ones.
+func (w *eventWorker) Add(fn func()) {
+	w.lock.Lock()
+	w.queue = append(w.queue, fn)
+	w.lock.Unlock()
+
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Close waits until the queued functions are called.
+func (w *eventWorker) Close() {
+	w.lock.Lock()
+	w.closed = true
+	w.lock.Unlock()
+
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+	<-w.done
+}
+
+func (w *eventWorker) run() {
+	defer close(w.done)
+
+	for {
+		w.lock.Lock()
+		queue, closed := w.queue, w.closed
+		w.queue = nil
+		w.lock.Unlock()
+
+		for _, fn := range queue {
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						printLog(model.ERROR, fmt.Sprintf("Handle event error: %v", r), true, true)
+					}
+				}()
+				fn()
+			}()
+		}
+
+		if len(queue) == 0 {
+			if closed {
+				return
+			}
+			<-w.signal
+		}
+	}
+}
+
+// SubscribeEvents subscribes the events of the flow run, and returns the function to unsubscribe.
+func (f *Flow) SubscribeEvents(handler EventHandler) func() {
+	id := Events.Subscribe(func(e Event) {
+		if e.flow == f {
+			handler(e)
+		}
+	})
+
+	return func() {
+		Events.Unsubscribe(id)
+	}
+}
+
+// emit publishes an event of the flow run.
+func (f *Flow) emit(e Event) {
+	e.Time, e.URI, e.Tag, e.Number, e.flow = time.Now(), f.URI, f.Tag, f.Number, f
+	Events.Publish(e)
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus()
+
+	received := []string{}
+	bus.Subscribe(func(e Event) { received = append(received, "first:"+e.Type) })
+	bus.Subscribe(func(e Event) { panic("broken handler") })
+	id := bus.Subscribe(func(e Event) { received = append(received, "third:"+e.Type) })
+
+	bus.Publish(Event{Type: FlowStarted})
+	bus.Unsubscribe(id)
+	bus.Publish(Event{Type: FlowFinished})
+
+	want := []string{"first:" + FlowStarted, "third:" + FlowStarted, "first:" + FlowFinished}
+	if len(received) != len(want) {
+		t.Fatalf("Received events are %v, want %v", received, want)
+	}
+	for i := range want {
+		if received[i] != want[i] {
+			t.Errorf("Received events are %v, want %v", received, want)
+		}
+	}
+}
+
+func TestFlowEvents(t *testing.T) {
+	newFakeExecutor()
+	f := newTestFlow("first\n", "fail")
+
+	var lock sync.Mutex
+	events := []Event{}
+	unsubscribe := f.SubscribeEvents(func(e Event) {
+		lock.Lock()
+		defer lock.Unlock()
+		events = append(events, e)
+	})
+	defer unsubscribe()
+
+	// The events of other flows aren't received.
+	other := newTestFlow("other\n")
+	other.LocalRun(context.Background(), false, false)
+	f.LocalRun(context.Background(), false, false)
+
+	find := func(t, path string) *Event {
+		for i := range events {
+			if events[i].Type == t && events[i].Path == path {
+				return &events[i]
+			}
+		}
+		return nil
+	}
+
+	if len(events) == 0 || events[0].Type != FlowStarted || events[len(events)-1].Type != FlowFinished {
+		t.Fatalf("Events are %v", events)
+	}
+	if e := events[len(events)-1]; e.Status != Failure || e.URI != f.URI || e.Number != f.Number {
+		t.Errorf("Flow finished event is %+v", e)
+	}
+	if e := find(JobOutput, "stage0.action0.job0"); e == nil || e.Line != "first" {
+		t.Errorf("Job output event is %+v", e)
+	}
+	if e := find(StageStarted, "stage1"); e == nil {
+		t.Errorf("No stage started event of stage1")
+	}
+	if e := find(StageFinished, "stage1"); e == nil || e.Status != Failure {
+		t.Errorf("Stage finished event is %+v", e)
+	}
+	if e := find(ActionFinished, "stage0.action0"); e == nil || e.Status != Success {
+		t.Errorf("Action finished event is %+v", e)
+	}
+	for _, e := range events {
+		if e.Line == "other" {
+			t.Errorf("Received the event of other flow: %+v", e)
+		}
+	}
+}
+
+type fakeNotifier struct {
+	lock          sync.Mutex
+	delay         time.Duration
+	notifications []Notification
+}
+
+func (n *fakeNotifier) Notify(flow *Flow, notification Notification, receiver Receiver) error {
+	time.Sleep(n.delay)
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+func TestStageActionReceivers(t *testing.T) {
+	newFakeExecutor()
+	notifier := &fakeNotifier{}
+	Notifiers["fake"] = notifier
+	defer delete(Notifiers, "fake")
+
+	f := newTestFlow("first\n", "fail")
+	f.Stages[1].Receivers = []Receiver{{Type: "fake", On: []string{Failure}}}
+	f.Stages[1].Actions[0].Receivers = []Receiver{{Type: "fake"}}
+	f.Stages[2].Receivers = []Receiver{{Type: "fake", On: []string{Failure}}}
+	f.Stages[2].Actions[0].Receivers = []Receiver{{Type: "fake", On: []string{Success}}}
+	f.LocalRun(context.Background(), false, false)
+
+	paths := map[string]Notification{}
+	for _, n := range notifier.notifications {
+		paths[n.Path] = n
+	}
+
+	if len(notifier.notifications) != 2 {
+		t.Fatalf("Notifications are %+v", notifier.notifications)
+	}
+	if n, ok := paths["stage0.action0"]; !ok || n.Event != ActionFinished || n.Status != Success ||
+		len(n.Stages) != 1 || len(n.Stages[0].Actions) != 1 {
+		t.Errorf("Action notification is %+v", n)
+	}
+	if n, ok := paths["stage1"]; !ok || n.Event != StageFinished || n.Status != Failure || n.Stages[0].Name != "stage1" {
+		t.Errorf("Stage notification is %+v", n)
+	}
+}
+
+func TestSlowReceivers(t *testing.T) {
+	newFakeExecutor()
+	notifier := &fakeNotifier{delay: 500 * time.Millisecond}
+	Notifiers["fake"] = notifier
+	defer delete(Notifiers, "fake")
+
+	f := newTestFlow("first\n", "second\n")
+	f.Stages[1].Receivers = []Receiver{{Type: "fake"}}
+	f.Stages[1].Actions[0].Receivers = []Receiver{{Type: "fake"}}
+	f.Receivers = []Receiver{{Type: "fake"}}
+	f.LocalRun(context.Background(), false, false)
+
+	// The next stage starts without waiting for the receivers of the finished stage and action.
+	if gap := f.Stages[2].start.Sub(f.Stages[1].end); gap >= notifier.delay {
+		t.Errorf("Stage stage1 starts %s after stage0 finished, the receivers block the run", gap)
+	}
+
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
+	if len(notifier.notifications) != 3 {
+		t.Fatalf("Notifications are %+v, want the ones of stage, action and flow", notifier.notifications)
+	}
+	if last := notifier.notifications[2]; last.Event != FlowFinished {
+		t.Errorf("The last notification is %s, want %s", last.Event, FlowFinished)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"syscall"
+)
+
+const (
+	// Job Executor Type
+	KubernetesExecutor = "kubernetes"
+	DockerExecutor     = "docker"
+	LocalExecutor      = "local"
+)
+
+var JobExecutors = make(map[string]JobExecutor)
+
+// JobExecutor runs the container of a job and hands every line of its output to Job.ParseLog.
+// The executor should stop the container and return when the context is done.
+type JobExecutor interface {
+	Execute(ctx context.Context, j *Job, containerName string, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) error
+}
+
+// JobCleaner is implemented by the executors whose containers outlive the daemon, the containers and workspaces
+// of the runs orphaned by a crash of daemon are removed by it after the daemon restarts. The flow is the
+// definition of the orphaned run.
+type JobCleaner interface {
+	Clean(f *Flow, containerName string) error
+}
+
+// WorkspaceProvider is implemented by the executors supporting the workspace of flow, the workspace volume of the
+// name is created before the stages run and deleted after the run finished.
+type WorkspaceProvider interface {
+	CreateWorkspace(f *Flow, name string) error
+	DeleteWorkspace(f *Flow, name string) error
+}
+
+// ArtifactArchiver is implemented by the executors archiving the artifacts of jobs, it uploads the artifact of job
+// in the workspace of flow to the Dockyard URL.
+type ArtifactArchiver interface {
+	Archive(ctx context.Context, containerName string, f *Flow, j *Job, a *Artifact, url string) error
+}
+
+// RegisterHostExecutors registers the local and docker executors running the jobs on the host. The CLI running
+// the flow files registers both of them, and the daemon registers the ones enabled in `[pilotage.daemon]`, so
+// the flows posted to the daemon or triggered by webhooks don't run commands on its host by default.
+func RegisterHostExecutors(local, docker bool) {
+	if local {
+		JobExecutors[LocalExecutor] = &LocalJobExecutor{}
+	}
+	if docker {
+		JobExecutors[DockerExecutor] = &DockerJobExecutor{}
+	}
+}
+
+func RegisterExecutor(name string, executor JobExecutor) error {
+	if _, ok := JobExecutors[name]; ok {
+		return fmt.Errorf("Job executor %s already exist", name)
+	}
+	JobExecutors[name] = executor
+	return nil
+}
+
+// GetExecutor returns the executor of job. The executor of job overrides the one of flow,
+// and Kubernetes is the default.
+func (j *Job) GetExecutor(f *Flow) (JobExecutor, error) {
+	name := j.executorName(f)
+	if executor, ok := JobExecutors[name]; ok {
+		return executor, nil
+	}
+
+	return nil, fmt.Errorf("Unknown job executor: %s", name)
+}
+
+func (j *Job) executorName(f *Flow) string {
+	if j.Executor != "" {
+		return j.Executor
+	} else if f.Executor != "" {
+		return f.Executor
+	}
+	return KubernetesExecutor
+}
+
+// ReadLogs reads the output of job line by line until EOF.
+func (j *Job) ReadLogs(read io.Reader, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) error {
+	reader := bufio.NewReader(read)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			j.ParseLog(line, verbose, timestamp, f, stageIndex, actionIndex)
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// RunCommand runs a local command, the stdout and stderr of command are the logs of job.
+// A non-zero exit of command is returned as ExitError.
+// The command should be created by exec.CommandContext, so it's killed when the context is done.
+func (j *Job) RunCommand(ctx context.Context, cmd *exec.Cmd, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) error {
+	read, write := io.Pipe()
+	cmd.Stdout, cmd.Stderr = write, write
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	f.emit(Event{Type: JobScheduled, Path: j.key, Status: Running})
+
+	go func() {
+		err := cmd.Wait()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode := -1
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+				exitCode = status.ExitStatus()
+			}
+			err = &ExitError{ExitCode: exitCode, Reason: "Error", Message: exitErr.Error()}
+		}
+		write.CloseWithError(err)
+	}()
+
+	// The children of command may hold the output after it's killed, stop reading when the context is done.
+	go func() {
+		<-ctx.Done()
+		read.CloseWithError(ctx.Err())
+	}()
+
+	if err := j.ReadLogs(read, verbose, timestamp, f, stageIndex, actionIndex); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+
+	return nil
+}
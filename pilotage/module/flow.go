@@ -17,13 +17,14 @@ limitations under the License.
 package module
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"strings"
+	"sync"
 	"time"
 
-	. "github.com/logrusorgru/aurora"
 	"gopkg.in/yaml.v2"
 
 	"github.com/Huawei/containerops/pilotage/model"
@@ -47,17 +48,44 @@ type Flow struct {
 	Tag          string              `json:"tag" yaml:"tag"`
 	Timeout      int64               `json:"timeout" yaml:"timeout"`
 	Namespace    string              `json:"namespace" yaml:"namespace"`
+	Executor     string              `json:"executor,omitempty" yaml:"executor,omitempty"`
+	Parallelism  int                 `json:"parallelism,omitempty" yaml:"parallelism,omitempty"`
+	FailFast     bool                `json:"fail_fast,omitempty" yaml:"fail_fast,omitempty"`
+	Concurrency  int                 `json:"concurrency,omitempty" yaml:"concurrency,omitempty"`
 	Environments []map[string]string `json:"environments" yaml:"environments"`
+	Secrets      []map[string]string `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+	Workspace    *Workspace          `json:"workspace,omitempty" yaml:"workspace,omitempty"`
 	Status       string              `json:"status,omitempty" yaml:"status,omitempty"`
 	Logs         []string            `json:"logs,omitempty" yaml:"logs,omitempty"`
 	Stages       []Stage             `json:"stages,omitempty" yaml:"stages,omitempty"`
 	Receivers    []Receiver          `json:"receivers,omitempty" yaml:"receivers,omitempty"`
-}
+	Triggers     []Trigger           `json:"triggers,omitempty" yaml:"triggers,omitempty"`
+
+	cancel  context.CancelFunc
+	outputs *RunOutputs
+	failed  failure
+	lines   map[string]int
+	stream  *LogStream
+
+	start    time.Time
+	end      time.Time
+	previous string
+	file     string
+	upstream *Upstream
+
+	// The dependencies of stages in the run.
+	dependencies [][]int
+
+	// The downstream flows run without the queue of daemon.
+	downstreams sync.WaitGroup
 
-// Receiver receives the flow execution result
-type Receiver struct {
-	Type    string `json:"type" yaml:"type"`
-	Address string `json:"address" yaml:"address"`
+	// The secret values by name and the lines of them masked in logs, they're loaded before the stages run.
+	secrets map[string]string
+	masks   []string
+
+	// The name of workspace volume and the executors created it.
+	workspace  string
+	workspaces []string
 }
 
 // JSON export flow data without
@@ -81,42 +109,186 @@ func (f *Flow) URIs() (namespace, repository, name string, err error) {
 	return namespace, repository, name, nil
 }
 
-// TODO filter the log print with different color.
+// Log records an INFO log of flow.
 func (f *Flow) Log(log string, verbose, timestamp bool) {
+	f.LogLevel(model.INFO, log, verbose, timestamp)
+}
+
+// LogLevel records a log of flow in the level and publishes it to the log stream, the output lines of jobs are
+// recorded by the jobs. The secret values in the log are masked.
+func (f *Flow) LogLevel(level, log string, verbose, timestamp bool) {
+	log = f.MaskSecrets(log)
+	f.stream.Publish("", log)
+
+	logsLock.Lock()
 	f.Logs = append(f.Logs, fmt.Sprintf("[%s] %s", time.Now().String(), log))
+	logsLock.Unlock()
 	l := new(model.LogV1)
-	l.Create(model.INFO, model.FLOW, f.ID, log)
+	l.Create(level, model.FLOW, f.ID, f.Number, log)
+	f.sinkLog(level, model.FLOW, "", log)
 
-	if verbose == true {
-		if timestamp == true {
-			fmt.Println(Cyan(fmt.Sprintf("[%s] %s", time.Now().String(), strings.TrimSpace(log))))
-		} else {
-			fmt.Println(Cyan(log))
+	printLog(level, log, verbose, timestamp)
+}
+
+// StageDependencies returns the indexes of stages which every stage depends on.
+// A stage without depends_on waits for all stages before it.
+func (f *Flow) StageDependencies() ([][]int, error) {
+	names, dependsOn := make([]string, len(f.Stages)), make([][]string, len(f.Stages))
+	for i, stage := range f.Stages {
+		names[i], dependsOn[i] = stage.Name, stage.DependsOn
+	}
+
+	return Dependencies(names, dependsOn, true)
+}
+
+// RunStage runs the stage by its type, and returns the status of stage.
+func (f *Flow) RunStage(ctx context.Context, verbose, timestamp bool, stageIndex int) (status string) {
+	stage := &f.Stages[stageIndex]
+	stage.start = time.Now()
+	defer func() {
+		stage.Status, stage.end = status, time.Now()
+		f.emit(Event{Type: StageFinished, Path: stage.Name, Status: status})
+	}()
+
+	// The stage not started before a failure of fail_fast flow is cancelled.
+	if s := ContextStatus(ctx); s != "" {
+		f.failed.Record(s)
+		return s
+	}
+
+	if run, err := f.When(stage.When, f.stageFailed(stageIndex)); err != nil {
+		f.LogLevel(model.ERROR, fmt.Sprintf("Stage [%s] when error: %s", stage.Name, err.Error()), verbose, timestamp)
+		f.failed.Record(Failure)
+		return Failure
+	} else if !run {
+		stage.Status = Skipped
+		f.Log(fmt.Sprintf("Stage [%s] is skipped", stage.Name), verbose, timestamp)
+		return Skipped
+	}
+
+	f.Log(fmt.Sprintf("The Number [%d] stage is running: %s", stageIndex, stage.Title), verbose, timestamp)
+	f.emit(Event{Type: StageStarted, Path: stage.Name, Status: Running})
+
+	var err error
+	switch stage.T {
+	case StartStage:
+		f.LogLevel(model.DEBUG, "Start stage don't need any trigger in cli or daemon run mode.", verbose, timestamp)
+		status = Success
+	case NormalStage:
+		status, err = stage.Run(ctx, verbose, timestamp, f, stageIndex)
+	case PauseStage:
+		status, err = stage.PauseRun(ctx, verbose, timestamp, f, stageIndex)
+	case EndStage:
+		f.LogLevel(model.DEBUG, fmt.Sprintf("End stage triggers %d downstream flows when it's finished.", len(stage.Downstreams)), verbose, timestamp)
+		status = Success
+	default:
+		err = fmt.Errorf("unknown stage type: %s", stage.T)
+	}
+
+	if err != nil {
+		f.LogLevel(model.ERROR, fmt.Sprintf("Stage [%s] run error: %s", stage.Name, err.Error()), verbose, timestamp)
+		status = Failure
+	}
+
+	f.failed.Record(status)
+	return status
+}
+
+// stageFailed returns whether the stages which the stage depends on are failed, or any stage before it is failed
+// when the dependencies aren't known.
+func (f *Flow) stageFailed(stageIndex int) bool {
+	if len(f.dependencies) != len(f.Stages) {
+		return f.failed.Failed()
+	}
+	return upstreamFailed(f.dependencies, stageIndex, func(index int) string { return f.Stages[index].Status })
+}
+
+// Resume resumes the paused stage of flow.
+func (f *Flow) Resume(approval Approval) error {
+	for i, _ := range f.Stages {
+		if stage := &f.Stages[i]; stage.T == PauseStage {
+			if err := stage.Resume(approval); err == nil {
+				return nil
+			}
 		}
 	}
+
+	return fmt.Errorf("Flow [%s] doesn't have paused stage", f.URI)
+}
+
+// GetOutputs returns the job outputs of the flow run.
+func (f *Flow) GetOutputs() *RunOutputs {
+	if f.outputs == nil {
+		f.outputs = NewRunOutputs(f.ID, f.Number)
+	}
+	return f.outputs
+}
+
+// GetLogStream returns the log stream of the flow run, it's nil before the flow runs.
+func (f *Flow) GetLogStream() *LogStream {
+	return f.stream
+}
+
+// Cancel stops a running flow, the running jobs are deleted and the flow status is cancel.
+func (f *Flow) Cancel() error {
+	if f.cancel == nil {
+		return fmt.Errorf("Flow [%s] is not running", f.URI)
+	}
+
+	f.LogLevel(model.WARN, fmt.Sprintf("Flow [%s] is cancelled", f.URI), false, false)
+	f.cancel()
+
+	return nil
 }
 
 // ParseFlowFromFile is init flow definition from a file.
 // It's only used in CliRun or DaemonRun, and run with local kubectl.
 func (f *Flow) ParseFlowFromFile(flowFile, runMode string, verbose, timestamp bool) error {
 	// Init flow properties
-	f.Model, f.Number, f.Status = runMode, 1, Pending
+	f.Model, f.Number, f.Status, f.file = runMode, 1, Pending, flowFile
 
 	if data, err := ioutil.ReadFile(flowFile); err != nil {
-		f.Log(fmt.Sprintf("Read orchestration flow file %s error: %s", flowFile, err.Error()), verbose, timestamp)
+		f.LogLevel(model.ERROR, fmt.Sprintf("Read orchestration flow file %s error: %s", flowFile, err.Error()), verbose, timestamp)
 		return err
 	} else {
-		if err := yaml.Unmarshal(data, &f); err != nil {
-			f.Log(fmt.Sprintf("Unmarshal the flow file error: %s", err.Error()), verbose, timestamp)
+		if err := f.ParseFlow(data); err != nil {
+			f.LogLevel(model.ERROR, fmt.Sprintf("Unmarshal the flow file error: %s", err.Error()), verbose, timestamp)
 			return err
 		}
 	}
 
+	if errs := f.Validate(); len(errs) > 0 {
+		f.LogLevel(model.ERROR, fmt.Sprintf("Validate the flow file error:\n%s", errs.Error()), verbose, timestamp)
+		return errs
+	}
+
 	return nil
 }
 
-// LocalRun is run flow using Kubectl in the local.
-func (f *Flow) LocalRun(verbose, timestamp bool) error {
+// ParseFlow unmarshals the flow definition from YAML, and keeps the lines of fields for the validation.
+func (f *Flow) ParseFlow(data []byte) error {
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return err
+	}
+	f.lines = yamlLines(data)
+
+	return nil
+}
+
+// LocalRun is run flow using Kubectl in the local. The flow is stopped when the context is done
+// or the timeout seconds of flow exceeded.
+func (f *Flow) LocalRun(ctx context.Context, verbose, timestamp bool) error {
+	f.stream = NewLogStream()
+	defer f.stream.Close()
+
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(f.Timeout)*time.Second)
+		defer cancel()
+	}
+	ctx, f.cancel = context.WithCancel(ctx)
+	defer f.cancel()
+
 	f.Status = Running
 	f.Log(fmt.Sprintf("Flow [%s] status change to %s", f.URI, f.Status), verbose, timestamp)
 
@@ -124,78 +296,91 @@ func (f *Flow) LocalRun(verbose, timestamp bool) error {
 	flow := new(model.FlowV1)
 	namespace, repository, name, err := f.URIs()
 	if err != nil {
-		f.Log(fmt.Sprintf("Parse Flow [%s] error: %s", f.URI, err.Error()), verbose, timestamp)
+		f.LogLevel(model.ERROR, fmt.Sprintf("Parse Flow [%s] error: %s", f.URI, err.Error()), verbose, timestamp)
 	}
 	content, _ := f.JSON()
 	flowID, err := flow.Put(namespace, repository, name, f.Tag, f.Title, string(content), f.Version, f.Timeout)
 	if err != nil {
-		f.Log(fmt.Sprintf("Save Flow [%s] error: %s", f.URI, err.Error()), verbose, timestamp)
+		f.LogLevel(model.ERROR, fmt.Sprintf("Save Flow [%s] error: %s", f.URI, err.Error()), verbose, timestamp)
 	}
 	f.ID = flowID
 
-	// Record flow data
+	// Record flow data, the number of run is reserved when it starts, so the concurrent runs of flow have
+	// their own numbers.
 	flowData := new(model.FlowDataV1)
 	startTime := time.Now()
-
-	for i, _ := range f.Stages {
-		stage := &f.Stages[i]
-
-		f.Log(fmt.Sprintf("The Number [%d] stage is running: %s", i, stage.Title), verbose, timestamp)
-
-		switch stage.T {
-		case StartStage:
-			f.Log("Start stage don't need any trigger in cli or daemon run mode.", verbose, timestamp)
-		case NormalStage:
-			switch stage.Sequencing {
-			case Parallel:
-				if status, err := stage.ParallelRun(verbose, timestamp, f, i); err != nil {
-					f.Status = Failure
-					f.Log(fmt.Sprintf("Stage [%s] run error: %s", stage.Name, err.Error()), verbose, timestamp)
-				} else {
-					f.Status = status
-				}
-			case Sequencing:
-				if status, err := stage.SequencingRun(verbose, timestamp, f, i); err != nil {
-					f.Status = Failure
-					f.Log(fmt.Sprintf("Stage [%s] run error: %s", stage.Name, err.Error()), verbose, timestamp)
-				} else {
-					f.Status = status
-				}
-			default:
-				f.Status = Failure
-				f.Log(fmt.Sprintf("Stage [%s] has unknown sequencing type: %s", stage.Name, stage.T), verbose, timestamp)
-			}
-		case PauseStage:
-			// TODO Pause running
-		case EndStage:
-			f.Log("End stage don't trigger any other flow.", verbose, timestamp)
-		}
-
-		// if status is failure or cancel, break the for loop.
-		if f.Status == Failure || f.Status == Cancel {
-			break
-		}
+	if f.upstream != nil {
+		flowData.ParentFlowID, flowData.ParentNumber, flowData.ParentPath = f.upstream.flowID, f.upstream.Number, f.upstream.Path
 	}
 
-	currentNumber, err := flowData.GetNumbers(flowID)
+	number, err := flowData.Reserve(flowID, Running, startTime)
 	if err != nil {
-		f.Log(fmt.Sprintf("Get Flow Data [%s] Numbers error: %s", f.URI, err.Error()), verbose, timestamp)
+		f.LogLevel(model.ERROR, fmt.Sprintf("Reserve Flow Data [%s] Number error: %s", f.URI, err.Error()), verbose, timestamp)
+	} else if number > 0 {
+		f.Number = number
 	}
-	if err := flowData.Put(f.ID, currentNumber+1, f.Status, startTime, time.Now()); err != nil {
-		f.Log(fmt.Sprintf("Save Flow Data [%s] error: %s", f.URI, err.Error()), verbose, timestamp)
+
+	AddRuntime(f)
+	defer RemoveRuntime(f)
+
+	f.outputs, f.failed = NewRunOutputs(f.ID, f.Number), 0
+	if err := f.outputs.Load(); err != nil {
+		f.LogLevel(model.ERROR, fmt.Sprintf("Load Flow [%s] outputs error: %s", f.URI, err.Error()), verbose, timestamp)
 	}
 
-	// Notify result to receivers
-	if len(f.Receivers) > 0 {
-		for _, receiver := range f.Receivers {
-			n := Notifiers[receiver.Type]
-			if err := n.Notify(f, []string{receiver.Address}); err != nil {
-				f.Log(fmt.Sprintf("Notify User Error: %s", err.Error()), verbose, timestamp)
-			} else {
-				f.Log(fmt.Sprintf("Notify User %s Success", receiver.Address), verbose, timestamp)
-			}
+	// The receivers of flow, stages and actions are notified by the events of run, and the downstream flows
+	// of stages and actions are triggered. They're handled by the worker of run in order, so the slow receivers
+	// don't hold the stages, and the run returns after they're handled.
+	worker := newEventWorker()
+	unsubscribe := f.SubscribeEvents(func(e Event) {
+		switch e.Type {
+		case StageFinished, ActionFinished:
+			notify, trigger := f.eventNotifier(e, verbose, timestamp), f.downstreamTrigger(e, verbose, timestamp)
+			worker.Add(func() {
+				notify()
+				trigger()
+			})
+		case FlowFinished:
+			worker.Add(func() { f.Notify(verbose, timestamp) })
 		}
+	})
+	defer unsubscribe()
+	defer worker.Close()
+
+	f.emit(Event{Type: FlowStarted, Status: f.Status})
+
+	if err := f.LoadSecrets(); err != nil {
+		f.Status = Failure
+		f.LogLevel(model.ERROR, fmt.Sprintf("Load Flow [%s] secrets error: %s", f.URI, err.Error()), verbose, timestamp)
+	} else if dependencies, err := f.StageDependencies(); err != nil {
+		f.Status = Failure
+		f.LogLevel(model.ERROR, fmt.Sprintf("Flow [%s] dependencies error: %s", f.URI, err.Error()), verbose, timestamp)
+	} else if err := f.CreateWorkspace(verbose, timestamp); err != nil {
+		f.Status = Failure
+		f.LogLevel(model.ERROR, fmt.Sprintf("Flow [%s] workspace error: %s", f.URI, err.Error()), verbose, timestamp)
+	} else {
+		f.dependencies = dependencies
+		f.Status = RunDAG(ctx, dependencies, f.Parallelism, f.FailFast, func(ctx context.Context, index int) string {
+			return f.RunStage(ctx, verbose, timestamp, index)
+		})
 	}
+	f.DeleteWorkspace(verbose, timestamp)
+
+	// The output lines of jobs are saved in batches, make sure they are saved before the run is finished.
+	model.FlushLogs()
+
+	f.start, f.end = startTime, time.Now()
+	if err := flowData.Put(f.ID, f.Number, f.Status, f.start, f.end); err != nil {
+		f.LogLevel(model.ERROR, fmt.Sprintf("Save Flow Data [%s] error: %s", f.URI, err.Error()), verbose, timestamp)
+	}
+
+	// The result of last run decides the `changed` trigger of receivers.
+	previous := new(model.FlowDataV1)
+	if f.Number > 1 && previous.Get(f.ID, f.Number-1) == nil && previous.Result != Running {
+		f.previous = previous.Result
+	}
+
+	f.emit(Event{Type: FlowFinished, Status: f.Status})
 
 	return nil
 }
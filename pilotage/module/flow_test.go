@@ -0,0 +1,407 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+// fakeJobExecutor prints the endpoint of job as the job logs, fails when the endpoint is "fail",
+// fails the first two runs when the endpoint is "flaky" and blocks until the context done when the endpoint is "block".
+type fakeJobExecutor struct {
+	lock sync.Mutex
+	envs map[string]map[string]string
+	runs map[string]int
+}
+
+func (e *fakeJobExecutor) Execute(ctx context.Context, j *Job, containerName string, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) error {
+	envs := map[string]string{}
+	for _, env := range j.EnvVars(f) {
+		envs[env.Name] = env.Value
+	}
+	e.lock.Lock()
+	e.envs[j.Name] = envs
+	e.runs[j.Name]++
+	runs := e.runs[j.Name]
+	e.lock.Unlock()
+
+	switch j.Endpoint {
+	case "fail":
+		return errors.New("job failed")
+	case "flaky":
+		if runs < 3 {
+			return &ExitError{ExitCode: 1, Reason: "Error"}
+		}
+	case "block":
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	return j.ReadLogs(strings.NewReader(j.Endpoint), verbose, timestamp, f, stageIndex, actionIndex)
+}
+
+func newFakeExecutor() *fakeJobExecutor {
+	model.DisableDB = true
+	RegisterHostExecutors(true, true)
+	HostPathVolumes = true
+
+	executor := &fakeJobExecutor{envs: map[string]map[string]string{}, runs: map[string]int{}}
+	JobExecutors["fake"] = executor
+
+	return executor
+}
+
+func newTestFlow(endpoints ...string) *Flow {
+	f := &Flow{URI: "containerops/test/flow", Tag: "latest", Executor: "fake", Status: Pending,
+		Environments: []map[string]string{{"CO_FLOW": "flow"}}}
+
+	f.Stages = append(f.Stages, Stage{T: StartStage, Name: "start"})
+	for i, endpoint := range endpoints {
+		job := Job{Name: fmt.Sprintf("job%d", i), Endpoint: endpoint, Outputs: []string{"CO_RESULT"}}
+		if i > 0 {
+			job.Subscriptions = []map[string]string{
+				{fmt.Sprintf("stage%d.action%d.job%d[CO_RESULT]", i-1, i-1, i-1): "CO_PREVIOUS"},
+			}
+		}
+
+		f.Stages = append(f.Stages, Stage{T: NormalStage, Name: fmt.Sprintf("stage%d", i), Sequencing: Sequencing,
+			Actions: []Action{{Name: fmt.Sprintf("action%d", i), Jobs: []Job{job}}}})
+	}
+	f.Stages = append(f.Stages, Stage{T: EndStage, Name: "end"})
+
+	return f
+}
+
+func TestLocalRun(t *testing.T) {
+	executor := newFakeExecutor()
+
+	f := newTestFlow("[COUT] CO_RESULT = first\n", "second\n")
+	if err := f.LocalRun(context.Background(), false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+
+	if f.Status != Success {
+		t.Errorf("Flow status is %s, want %s", f.Status, Success)
+	}
+
+	if env := executor.envs["job0"]["CO_FLOW"]; env != "flow" {
+		t.Errorf("Flow environment is %q, want %q", env, "flow")
+	}
+
+	if env := executor.envs["job1"]["CO_PREVIOUS"]; env != "first" {
+		t.Errorf("Subscription environment is %q, want %q", env, "first")
+	}
+
+	if logs := f.Stages[2].Actions[0].Jobs[0].Logs; len(logs) != 1 || !strings.Contains(logs[0], "second") {
+		t.Errorf("Job logs are %v", logs)
+	}
+}
+
+func TestLocalRunFailure(t *testing.T) {
+	executor := newFakeExecutor()
+
+	f := newTestFlow("fail", "never")
+	f.LocalRun(context.Background(), false, false)
+
+	if f.Status != Failure {
+		t.Errorf("Flow status is %s, want %s", f.Status, Failure)
+	}
+
+	if _, ok := executor.envs["job1"]; ok {
+		t.Errorf("The job after a failure job should not run")
+	}
+}
+
+func TestJobResult(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("[COUT] CO_RESULT = true\n", "[COUT] CO_RESULT = false\n", "never")
+	f.LocalRun(context.Background(), false, false)
+
+	if f.Status != Failure {
+		t.Errorf("Flow status is %s, want %s", f.Status, Failure)
+	}
+	for i, want := range []string{Success, Failure, ""} {
+		if job := f.Stages[i+1].Actions[0].Jobs[0]; job.Status != want {
+			t.Errorf("Job [%s] status is %s, want %q", job.Name, job.Status, want)
+		}
+	}
+	if job := f.Stages[2].Actions[0].Jobs[0]; job.ExitCode != 0 || job.Reason != ResultFalse {
+		t.Errorf("Job [%s] exits with %d: %s, want 0: %s", job.Name, job.ExitCode, job.Reason, ResultFalse)
+	}
+}
+
+func TestLocalExitCode(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("exit 3")
+	f.Executor = LocalExecutor
+	f.LocalRun(context.Background(), false, false)
+
+	if job := f.Stages[1].Actions[0].Jobs[0]; job.Status != Failure || job.ExitCode != 3 || job.Reason != "Error" {
+		t.Errorf("Job [%s] is %s and exits with %d: %s, want %s and 3: Error", job.Name, job.Status, job.ExitCode, job.Reason, Failure)
+	}
+}
+
+func TestUnknownExecutor(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("[COUT] CO_RESULT = first\n")
+	f.Executor = "unknown"
+	f.LocalRun(context.Background(), false, false)
+
+	if f.Status != Failure {
+		t.Errorf("Flow status is %s, want %s", f.Status, Failure)
+	}
+}
+
+func TestJobTimeout(t *testing.T) {
+	executor := newFakeExecutor()
+
+	f := newTestFlow("block", "never")
+	f.Stages[1].Actions[0].Jobs[0].Timeout = 1
+	f.LocalRun(context.Background(), false, false)
+
+	if f.Status != Timeout {
+		t.Errorf("Flow status is %s, want %s", f.Status, Timeout)
+	}
+
+	if status := f.Stages[1].Actions[0].Jobs[0].Status; status != Timeout {
+		t.Errorf("Job status is %s, want %s", status, Timeout)
+	}
+
+	if _, ok := executor.envs["job1"]; ok {
+		t.Errorf("The job after a timeout job should not run")
+	}
+}
+
+func TestFlowCancel(t *testing.T) {
+	newFakeExecutor()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	f := newTestFlow("block")
+	f.LocalRun(ctx, false, false)
+
+	if f.Status != Cancel {
+		t.Errorf("Flow status is %s, want %s", f.Status, Cancel)
+	}
+}
+
+func newPauseFlow(timeout int64, policy string) *Flow {
+	f := newTestFlow("[COUT] CO_RESULT = first\n", "second\n")
+	pause := Stage{T: PauseStage, Name: "approve", Timeout: timeout, TimeoutPolicy: policy}
+	f.Stages = append(f.Stages[:2], append([]Stage{pause}, f.Stages[2:]...)...)
+
+	return f
+}
+
+func TestPauseResume(t *testing.T) {
+	executor := newFakeExecutor()
+
+	f := newPauseFlow(0, "")
+	f.Number = 10
+	go func() {
+		for {
+			time.Sleep(10 * time.Millisecond)
+			if r := GetRuntime(f.URI, f.Tag, f.Number); r != nil {
+				if err := r.Resume(Approval{Approver: "admin", Comment: "lgtm"}); err == nil {
+					return
+				}
+			}
+		}
+	}()
+	f.LocalRun(context.Background(), false, false)
+
+	if f.Status != Success {
+		t.Errorf("Flow status is %s, want %s", f.Status, Success)
+	}
+
+	if approval := f.Stages[2].Approval; approval == nil || approval.Approver != "admin" || approval.Comment != "lgtm" {
+		t.Errorf("Approval of pause stage is %v", approval)
+	}
+
+	if _, ok := executor.envs["job1"]; !ok {
+		t.Errorf("The job after a resumed pause stage should run")
+	}
+
+	if GetRuntime(f.URI, f.Tag, f.Number) != nil {
+		t.Errorf("Flow runtime should be removed after finished")
+	}
+}
+
+func TestPauseTimeout(t *testing.T) {
+	for _, policy := range []string{"", Cancel} {
+		executor := newFakeExecutor()
+
+		f := newPauseFlow(1, policy)
+		f.LocalRun(context.Background(), false, false)
+
+		want := Failure
+		if policy == Cancel {
+			want = Cancel
+		}
+		if f.Status != want {
+			t.Errorf("Flow status is %s with policy %q, want %s", f.Status, policy, want)
+		}
+
+		if _, ok := executor.envs["job1"]; ok {
+			t.Errorf("The job after a timeout pause stage should not run")
+		}
+	}
+}
+
+func TestResumeFromTerminal(t *testing.T) {
+	s := &Stage{Name: "pause", T: PauseStage, Status: Paused, resume: make(chan Approval, 1)}
+	f := &Flow{}
+
+	lines := make(chan string)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.resumeFromTerminal(ctx, lines, false, false, f)
+		close(done)
+	}()
+	lines <- "lgtm\n"
+	<-done
+
+	if approval := <-s.resume; approval.Comment != "lgtm" {
+		t.Errorf("Comment of the terminal resume is %q, want %q", approval.Comment, "lgtm")
+	}
+
+	done = make(chan struct{})
+	go func() {
+		s.resumeFromTerminal(ctx, lines, false, false, f)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("The terminal resume should stop when the pause stage ends")
+	}
+
+	select {
+	case lines <- "late\n":
+		t.Errorf("The line input after the pause stage ends should not be read")
+	default:
+	}
+}
+
+func TestRuntimeCancel(t *testing.T) {
+	executor := newFakeExecutor()
+
+	f := newTestFlow("block", "never")
+	f.Number = 20
+	go func() {
+		for {
+			time.Sleep(10 * time.Millisecond)
+			if r := GetRuntime(f.URI, f.Tag, f.Number); r != nil {
+				r.Cancel()
+				return
+			}
+		}
+	}()
+	f.LocalRun(context.Background(), false, false)
+
+	if f.Status != Cancel {
+		t.Errorf("Flow status is %s, want %s", f.Status, Cancel)
+	}
+
+	if status := f.Stages[1].Status; status != Cancel {
+		t.Errorf("Stage status is %s, want %s", status, Cancel)
+	}
+
+	if _, ok := executor.envs["job1"]; ok {
+		t.Errorf("The job after a cancelled job should not run")
+	}
+}
+
+func TestConcurrentRunOutputs(t *testing.T) {
+	newFakeExecutor()
+
+	flows := []*Flow{newTestFlow("[COUT] CO_RESULT = a\n", "second\n"), newTestFlow("[COUT] CO_RESULT = b\n", "second\n")}
+
+	var wg sync.WaitGroup
+	for i, f := range flows {
+		wg.Add(1)
+		f.Number = int64(30 + i)
+		go func(f *Flow) {
+			defer wg.Done()
+			f.LocalRun(context.Background(), false, false)
+		}(f)
+	}
+	wg.Wait()
+
+	for i, want := range []string{"a", "b"} {
+		if value, _ := flows[i].GetOutputs().Get("stage0.action0.job0[CO_RESULT]"); value != want {
+			t.Errorf("Output of run %d is %q, want %q", flows[i].Number, value, want)
+		}
+	}
+}
+
+func TestConcurrentRunNumbers(t *testing.T) {
+	newFakeExecutor()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	flows := []*Flow{newTestFlow("block"), newTestFlow("block")}
+	var wg sync.WaitGroup
+	for _, f := range flows {
+		wg.Add(1)
+		f.Tag, f.Number = "concurrent", 40
+		go func(f *Flow) {
+			defer wg.Done()
+			f.LocalRun(ctx, false, false)
+		}(f)
+	}
+
+	runs := []*Flow{}
+	for deadline := time.Now().Add(5 * time.Second); len(runs) < 2 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		runs = GetRuntimes("containerops/test/flow", "concurrent")
+	}
+	if len(runs) != 2 {
+		t.Fatalf("The concurrent runs are %d, want 2", len(runs))
+	}
+	if runs[0].Number == runs[1].Number {
+		t.Errorf("The concurrent runs have the same number %d", runs[0].Number)
+	}
+	for _, r := range runs {
+		if GetRuntime(r.URI, r.Tag, r.Number) != r {
+			t.Errorf("The runtime of run %d is another run", r.Number)
+		}
+	}
+
+	cancel()
+	wg.Wait()
+	if runs := GetRuntimes("containerops/test/flow", "concurrent"); len(runs) != 0 {
+		t.Errorf("The runtimes of finished runs are %d, want 0", len(runs))
+	}
+}
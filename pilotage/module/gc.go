@@ -0,0 +1,143 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+// StartPodCollector collects the job pods every interval until the context is done.
+func StartPodCollector(ctx context.Context, ttl, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := CollectPods(ttl); err != nil {
+				printLog(model.ERROR, fmt.Sprintf("Collect job pods error: %s", err.Error()), true, true)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// CollectPods deletes the job pods labelled by pilotage in all the namespaces, the pods finished before the ttl
+// and the pods orphaned by the finished runs are deleted with their secrets. It returns the count of deleted pods.
+func CollectPods(ttl time.Duration) (int, error) {
+	clientSet, err := kubernetesClient()
+	if err != nil {
+		return 0, err
+	}
+
+	pods, err := clientSet.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", LabelManagedBy, ManagedByPilotage)})
+	if err != nil {
+		return 0, err
+	}
+
+	count, now := 0, time.Now()
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		garbage, reason := PodGarbage(pod, ttl, now, RunFinished)
+		if !garbage {
+			continue
+		}
+
+		if err := clientSet.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			printLog(model.ERROR, fmt.Sprintf("Delete pod %s/%s error: %s", pod.Namespace, pod.Name, err.Error()), true, true)
+			continue
+		}
+		if err := clientSet.CoreV1().Secrets(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			printLog(model.ERROR, fmt.Sprintf("Delete secret %s/%s error: %s", pod.Namespace, pod.Name, err.Error()), true, true)
+		}
+
+		count++
+		printLog(model.INFO, fmt.Sprintf("Delete pod %s/%s %s", pod.Namespace, pod.Name, reason), true, true)
+	}
+
+	return count, nil
+}
+
+// PodGarbage returns whether the job pod should be deleted and the reason. The pod finished before the ttl is
+// garbage, and the pod still running is orphaned when its run is finished.
+func PodGarbage(pod *apiv1.Pod, ttl time.Duration, now time.Time, finished func(uri, tag string, number int64) bool) (bool, string) {
+	if pod.DeletionTimestamp != nil {
+		return false, ""
+	}
+
+	switch pod.Status.Phase {
+	case apiv1.PodSucceeded, apiv1.PodFailed:
+		end := pod.CreationTimestamp.Time
+		for _, status := range pod.Status.ContainerStatuses {
+			if terminated := status.State.Terminated; terminated != nil && terminated.FinishedAt.Time.After(end) {
+				end = terminated.FinishedAt.Time
+			}
+		}
+		if now.Sub(end) >= ttl {
+			return true, fmt.Sprintf("finished %s ago", now.Sub(end)/time.Second*time.Second)
+		}
+		return false, ""
+	}
+
+	uri, tag := pod.Annotations[AnnotationURI], pod.Annotations[AnnotationTag]
+	number, err := strconv.ParseInt(pod.Labels[LabelNumber], 10, 64)
+	if uri == "" || err != nil {
+		return false, ""
+	}
+	if finished(uri, tag, number) {
+		return true, fmt.Sprintf("orphaned by the finished run [%s:%s/%d]", uri, tag, number)
+	}
+	return false, ""
+}
+
+// RunFinished returns whether the run of flow is finished, the run is saved as running when it starts and its
+// result is saved when it's finished.
+func RunFinished(uri, tag string, number int64) bool {
+	if GetRuntime(uri, tag, number) != nil || model.DisableDB {
+		return false
+	}
+
+	f := &Flow{URI: uri}
+	namespace, repository, name, err := f.URIs()
+	if err != nil {
+		return false
+	}
+	flow := new(model.FlowV1)
+	if err := flow.Get(namespace, repository, name, tag); err != nil {
+		return false
+	}
+	run := new(model.FlowDataV1)
+	return run.Get(flow.ID, number) == nil && run.Result != Running
+}
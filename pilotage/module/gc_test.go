@@ -0,0 +1,122 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestKubernetesMeta(t *testing.T) {
+	f := newTestFlow("first\n")
+	f.Number = 4
+	if namespace := f.KubernetesNamespace(); namespace != apiv1.NamespaceDefault {
+		t.Errorf("Namespace of flow is %s, want %s", namespace, apiv1.NamespaceDefault)
+	}
+
+	f.Namespace = "ci"
+	j := &f.Stages[1].Actions[0].Jobs[0]
+	j.Resources = Resource{CPU: "1", Memory: "1Gi"}
+	j.setRun(f, 1, 0)
+
+	pod := j.PodTemplates("pod", f)
+	if pod.Namespace != "ci" {
+		t.Errorf("Namespace of pod is %s, want ci", pod.Namespace)
+	}
+	want := map[string]string{LabelManagedBy: ManagedByPilotage, LabelFlow: "containerops.test.flow", LabelTag: "latest",
+		LabelNumber: "4", LabelStage: "stage0", LabelAction: "action0", LabelJob: "job0"}
+	for key, value := range want {
+		if pod.Labels[key] != value {
+			t.Errorf("Label %s of pod is %q, want %q", key, pod.Labels[key], value)
+		}
+	}
+	if pod.Annotations[AnnotationURI] != f.URI || pod.Annotations[AnnotationTag] != f.Tag {
+		t.Errorf("Annotations of pod are %v, want the uri and tag of flow", pod.Annotations)
+	}
+
+	if meta := f.KubernetesMeta("workspace", nil); meta.Labels[LabelJob] != "" || meta.Labels[LabelNumber] != "4" {
+		t.Errorf("Labels of flow object are %v", meta.Labels)
+	}
+}
+
+func TestLabelValue(t *testing.T) {
+	for value, want := range map[string]string{
+		"latest":                       "latest",
+		"v1.0+build/1":                 "v1.0-build-1",
+		"-feature:x_":                  "feature-x",
+		strings.Repeat("a", 70):        strings.Repeat("a", 63),
+		strings.Repeat("b", 62) + "-c": strings.Repeat("b", 62),
+	} {
+		if got := labelValue(value); got != want {
+			t.Errorf("Label value of %q is %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestPodGarbage(t *testing.T) {
+	now := time.Now()
+	finished := func(uri, tag string, number int64) bool {
+		return uri == "containerops/test/flow" && tag == "latest" && number == 1
+	}
+
+	pod := func(phase apiv1.PodPhase, finishedAt time.Time, number string) *apiv1.Pod {
+		p := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod",
+			CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+			Labels:            map[string]string{LabelNumber: number},
+			Annotations:       map[string]string{AnnotationURI: "containerops/test/flow", AnnotationTag: "latest"}},
+			Status: apiv1.PodStatus{Phase: phase}}
+		if !finishedAt.IsZero() {
+			p.Status.ContainerStatuses = []apiv1.ContainerStatus{{State: apiv1.ContainerState{
+				Terminated: &apiv1.ContainerStateTerminated{FinishedAt: metav1.NewTime(finishedAt)}}}}
+		}
+		return p
+	}
+
+	deleting := pod(apiv1.PodSucceeded, now.Add(-time.Hour), "1")
+	deletionTimestamp := metav1.NewTime(now)
+	deleting.DeletionTimestamp = &deletionTimestamp
+	noAnnotations := pod(apiv1.PodRunning, time.Time{}, "1")
+	noAnnotations.Annotations = nil
+
+	for _, c := range []struct {
+		name    string
+		pod     *apiv1.Pod
+		garbage bool
+	}{
+		{"succeeded after ttl", pod(apiv1.PodSucceeded, now.Add(-time.Hour), "2"), true},
+		{"failed within ttl", pod(apiv1.PodFailed, now.Add(-time.Minute), "2"), false},
+		{"finished without status", pod(apiv1.PodFailed, time.Time{}, "2"), true},
+		{"running with finished run", pod(apiv1.PodRunning, time.Time{}, "1"), true},
+		{"pending with finished run", pod(apiv1.PodPending, time.Time{}, "1"), true},
+		{"running with unfinished run", pod(apiv1.PodRunning, time.Time{}, "2"), false},
+		{"running without number", pod(apiv1.PodRunning, time.Time{}, ""), false},
+		{"running without annotations", noAnnotations, false},
+		{"deleting", deleting, false},
+	} {
+		garbage, reason := PodGarbage(c.pod, 30*time.Minute, now, finished)
+		if garbage != c.garbage {
+			t.Errorf("Pod %s is garbage %v (%s), want %v", c.name, garbage, reason, c.garbage)
+		}
+		if garbage && reason == "" {
+			t.Errorf("Pod %s is garbage without reason", c.name)
+		}
+	}
+}
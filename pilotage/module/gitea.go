@@ -0,0 +1,39 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"crypto/sha256"
+	"net/http"
+)
+
+func init() {
+	RegisterGitWebhook(GiteaTrigger, &GiteaWebhook{})
+}
+
+// GiteaWebhook receives the push and pull_request events of Gitea, the payload is compatible with GitHub and
+// the request is signed with the secret in the header `X-Gitea-Signature`.
+type GiteaWebhook struct {
+}
+
+func (g *GiteaWebhook) Verify(header http.Header, body []byte, secret string) error {
+	return verifyHMAC(sha256.New, secret, body, header.Get("X-Gitea-Signature"))
+}
+
+func (g *GiteaWebhook) Parse(header http.Header, body []byte) (*GitEvent, error) {
+	return parseGitHubPayload(GiteaTrigger, header.Get("X-Gitea-Event"), body)
+}
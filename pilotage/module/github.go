@@ -0,0 +1,141 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	RegisterGitWebhook(GitHubTrigger, &GitHubWebhook{})
+}
+
+// GitHubWebhook receives the push and pull_request events of GitHub, the request is signed with the secret
+// in the header `X-Hub-Signature-256`, or `X-Hub-Signature` of the old GitHub Enterprise.
+type GitHubWebhook struct {
+}
+
+func (g *GitHubWebhook) Verify(header http.Header, body []byte, secret string) error {
+	if signature := header.Get("X-Hub-Signature-256"); signature != "" {
+		return verifyHMAC(sha256.New, secret, body, strings.TrimPrefix(signature, "sha256="))
+	}
+	return verifyHMAC(sha1.New, secret, body, strings.TrimPrefix(header.Get("X-Hub-Signature"), "sha1="))
+}
+
+func (g *GitHubWebhook) Parse(header http.Header, body []byte) (*GitEvent, error) {
+	return parseGitHubPayload(GitHubTrigger, header.Get("X-GitHub-Event"), body)
+}
+
+// githubPayload is the push and pull_request payload of GitHub, Gitea sends the compatible payload.
+type githubPayload struct {
+	Ref        string `json:"ref"`
+	After      string `json:"after"`
+	Deleted    bool   `json:"deleted"`
+	Action     string `json:"action"`
+	Number     int64  `json:"number"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+	Commits []struct {
+		Added    []string `json:"added"`
+		Removed  []string `json:"removed"`
+		Modified []string `json:"modified"`
+	} `json:"commits"`
+	PullRequest struct {
+		Head struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+}
+
+// The commit of deleted branch or tag.
+const zeroCommit = "0000000000000000000000000000000000000000"
+
+func parseGitHubPayload(provider, event string, body []byte) (*GitEvent, error) {
+	if event != "push" && event != "pull_request" {
+		return nil, nil
+	}
+
+	payload := githubPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("Unmarshal %s payload error: %s", provider, err.Error())
+	}
+
+	e := &GitEvent{Provider: provider, Repository: payload.Repository.FullName, Author: payload.Sender.Login}
+
+	if event == "pull_request" {
+		switch payload.Action {
+		case "opened", "reopened", "synchronize", "synchronized":
+		default:
+			return nil, nil
+		}
+
+		e.Event, e.PullRequest, e.Commit = PullRequestEvent, payload.Number, payload.PullRequest.Head.SHA
+		e.Branch, e.HeadBranch = payload.PullRequest.Base.Ref, payload.PullRequest.Head.Ref
+		return e, nil
+	}
+
+	if payload.Deleted || payload.After == zeroCommit {
+		return nil, nil
+	}
+
+	e.Commit = payload.After
+	if tag := strings.TrimPrefix(payload.Ref, "refs/tags/"); tag != payload.Ref {
+		e.Event, e.Tag = TagEvent, tag
+	} else {
+		e.Event, e.Branch = PushEvent, strings.TrimPrefix(payload.Ref, "refs/heads/")
+	}
+
+	for _, commit := range payload.Commits {
+		e.Paths = append(e.Paths, commit.Added...)
+		e.Paths = append(e.Paths, commit.Removed...)
+		e.Paths = append(e.Paths, commit.Modified...)
+	}
+
+	return e, nil
+}
+
+// verifyHMAC compares the hex signature with the HMAC of body in constant time.
+func verifyHMAC(h func() hash.Hash, secret string, body []byte, signature string) error {
+	expected, err := hex.DecodeString(signature)
+	if err != nil || secret == "" {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	RegisterGitWebhook(GitLabTrigger, &GitLabWebhook{})
+}
+
+// GitLabWebhook receives the push, tag push and merge request events of GitLab, the secret of trigger is
+// the token of webhook in the header `X-Gitlab-Token`.
+type GitLabWebhook struct {
+}
+
+type gitlabPayload struct {
+	ObjectKind   string `json:"object_kind"`
+	Ref          string `json:"ref"`
+	After        string `json:"after"`
+	CheckoutSHA  string `json:"checkout_sha"`
+	UserUsername string `json:"user_username"`
+	User         struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+	Commits []struct {
+		Added    []string `json:"added"`
+		Removed  []string `json:"removed"`
+		Modified []string `json:"modified"`
+	} `json:"commits"`
+	ObjectAttributes struct {
+		IID          int64  `json:"iid"`
+		Action       string `json:"action"`
+		SourceBranch string `json:"source_branch"`
+		TargetBranch string `json:"target_branch"`
+		LastCommit   struct {
+			ID string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+}
+
+func (g *GitLabWebhook) Verify(header http.Header, body []byte, secret string) error {
+	if secret == "" || subtle.ConstantTimeCompare([]byte(header.Get("X-Gitlab-Token")), []byte(secret)) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (g *GitLabWebhook) Parse(header http.Header, body []byte) (*GitEvent, error) {
+	switch header.Get("X-Gitlab-Event") {
+	case "Push Hook", "Tag Push Hook", "Merge Request Hook":
+	default:
+		return nil, nil
+	}
+
+	payload := gitlabPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("Unmarshal gitlab payload error: %s", err.Error())
+	}
+
+	e := &GitEvent{Provider: GitLabTrigger, Repository: payload.Project.PathWithNamespace}
+
+	switch payload.ObjectKind {
+	case "merge_request":
+		attributes := payload.ObjectAttributes
+		switch attributes.Action {
+		case "open", "reopen", "update":
+		default:
+			return nil, nil
+		}
+
+		e.Event, e.PullRequest, e.Commit, e.Author = PullRequestEvent, attributes.IID, attributes.LastCommit.ID, payload.User.Username
+		e.Branch, e.HeadBranch = attributes.TargetBranch, attributes.SourceBranch
+		return e, nil
+	case "push", "tag_push":
+		// The checkout sha is null when the branch or tag is deleted.
+		if payload.CheckoutSHA == "" || payload.After == zeroCommit {
+			return nil, nil
+		}
+
+		e.Commit, e.Author = payload.CheckoutSHA, payload.UserUsername
+		if payload.ObjectKind == "tag_push" {
+			e.Event, e.Tag = TagEvent, strings.TrimPrefix(payload.Ref, "refs/tags/")
+		} else {
+			e.Event, e.Branch = PushEvent, strings.TrimPrefix(payload.Ref, "refs/heads/")
+		}
+
+		for _, commit := range payload.Commits {
+			e.Paths = append(e.Paths, commit.Added...)
+			e.Paths = append(e.Paths, commit.Removed...)
+			e.Paths = append(e.Paths, commit.Modified...)
+		}
+		return e, nil
+	}
+
+	return nil, nil
+}
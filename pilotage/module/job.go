@@ -1,23 +1,20 @@
 package module
 
 import (
-	"bufio"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
-	. "github.com/logrusorgru/aurora"
 	homeDir "github.com/mitchellh/go-homedir"
 	apiv1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
@@ -27,10 +24,8 @@ import (
 	"github.com/Huawei/containerops/pilotage/model"
 )
 
-var (
-	RWlock        sync.RWMutex
-	GlobalOutputs map[string]string
-)
+// ResultFalse is the reason of the job failed by a false CO_RESULT.
+const ResultFalse = "ResultFalse"
 
 // Job is
 type Job struct {
@@ -38,61 +33,131 @@ type Job struct {
 	T             string              `json:"type" yaml:"type"`
 	Name          string              `json:"name" yaml:"name,omitempty"`
 	Kubectl       string              `json:"kubectl" yaml:"kubectl"`
+	Executor      string              `json:"executor,omitempty" yaml:"executor,omitempty"`
 	Endpoint      string              `json:"endpoint" yaml:"endpoint"`
 	Timeout       int64               `json:"timeout" yaml:"timeout"`
+	Retry         *Retry              `json:"retry,omitempty" yaml:"retry,omitempty"`
+	When          string              `json:"when,omitempty" yaml:"when,omitempty"`
+	Matrix        map[string][]string `json:"matrix,omitempty" yaml:"matrix,omitempty"`
 	Status        string              `json:"status,omitempty" yaml:"status,omitempty"`
+	ExitCode      int                 `json:"exit_code,omitempty" yaml:"-"`
+	Reason        string              `json:"reason,omitempty" yaml:"-"`
 	Resources     Resource            `json:"resources" yaml:"resources"`
+	Command       []string            `json:"command,omitempty" yaml:"command,omitempty"`
+	Args          []string            `json:"args,omitempty" yaml:"args,omitempty"`
+	VolumeMounts  []VolumeMount       `json:"volume_mounts,omitempty" yaml:"volume_mounts,omitempty"`
+	Pod           *Pod                `json:"pod,omitempty" yaml:"pod,omitempty"`
 	Logs          []string            `json:"logs,omitempty" yaml:"logs,omitempty"`
 	Environments  []map[string]string `json:"environments" yaml:"environments"`
+	Secrets       []map[string]string `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+	Artifacts     []Artifact          `json:"artifacts,omitempty" yaml:"artifacts,omitempty"`
 	Outputs       []string            `json:"outputs,omitempty" yaml:"outputs,omitempty"`
 	Subscriptions []map[string]string `json:"subscriptions,omitempty" yaml:"subscriptions,omitempty"`
+	Attempts      []Attempt           `json:"attempts,omitempty" yaml:"attempts,omitempty"`
+	Expansions    []Job               `json:"expansions,omitempty" yaml:"-"`
+
+	flow   *Flow
+	key    string
+	stage  string
+	action string
+	result string
 }
 
-// Resources is
+// Resources is the requests of container, the limits are the max resources of container.
 type Resource struct {
-	CPU    string `json:"cpu" yaml:"cpu"`
-	Memory string `json:"memory" yaml:"memory"`
+	CPU    string    `json:"cpu" yaml:"cpu"`
+	Memory string    `json:"memory" yaml:"memory"`
+	Limits *Resource `json:"limits,omitempty" yaml:"limits,omitempty"`
 }
 
-func init() {
-	GlobalOutputs = make(map[string]string)
+// Log records an INFO log of job.
+func (j *Job) Log(log string, verbose, timestamp bool) {
+	j.LogLevel(model.INFO, log, verbose, timestamp)
 }
 
-// TODO filter the log print with different color.
-func (j *Job) Log(log string, verbose, timestamp bool) {
+// LogLevel records a log of job in the level, the secret values in the log are masked.
+func (j *Job) LogLevel(level, log string, verbose, timestamp bool) {
+	log = j.flow.MaskSecrets(log)
+	logsLock.Lock()
 	j.Logs = append(j.Logs, fmt.Sprintf("[%s] %s", time.Now().String(), log))
+	logsLock.Unlock()
 	l := new(model.LogV1)
-	l.Create(model.INFO, model.JOB, j.ID, log)
+	l.Create(level, model.JOB, j.ID, j.flow.runNumber(), log)
+	j.flow.sinkLog(level, model.JOB, j.key, log)
 
-	if verbose == true {
-		if timestamp == true {
-			fmt.Println(Cyan(fmt.Sprintf("[%s] %s", time.Now().String(), strings.TrimSpace(log))))
-		} else {
-			fmt.Println(Cyan(log))
-		}
+	printLog(level, log, verbose, timestamp)
+}
+
+// setRun sets the flow run of job, the key `stage.action.job` identifies the job in the flow.
+func (j *Job) setRun(f *Flow, stageIndex, actionIndex int) {
+	j.flow, j.stage, j.action = f, f.Stages[stageIndex].Name, f.Stages[stageIndex].Actions[actionIndex].Name
+	j.key = fmt.Sprintf("%s.%s.%s", j.stage, j.action, j.Name)
+}
+
+// output records an output line of job container once in the logs of job, the lines are saved in batches. It's
+// published to the log stream of flow as the line of job and printed.
+func (j *Job) output(line string, verbose, timestamp bool) {
+	logsLock.Lock()
+	j.Logs = append(j.Logs, fmt.Sprintf("[%s] %s", time.Now().String(), line))
+	logsLock.Unlock()
+	l := new(model.LogV1)
+	l.CreateAsync(model.INFO, model.JOB, j.ID, j.flow.runNumber(), line)
+	j.flow.sinkLog(model.INFO, model.JOB, j.key, line)
+	if j.flow != nil {
+		j.flow.stream.Publish(j.key, line)
 	}
+
+	printLog(model.INFO, line, verbose, timestamp)
 }
 
-func (j *Job) Run(name string, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) (string, error) {
+func (j *Job) Run(ctx context.Context, name string, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) (status string, err error) {
+	j.setRun(f, stageIndex, actionIndex)
+	ctx, cancel := j.WithTimeout(ctx)
+	defer cancel()
 
 	j.SaveDatabase(verbose, timestamp, f, stageIndex, actionIndex)
 
-	randomContainerName := fmt.Sprintf("%s-%s", name, utils.RandomString(10))
-	podTemplate := j.PodTemplates(randomContainerName, f)
+	startTime := time.Now()
+	defer func() {
+		j.Status = status
+		j.SaveData(startTime, f, verbose, timestamp)
+	}()
 
-	if err := j.InvokePod(podTemplate, randomContainerName, verbose, timestamp, f, stageIndex, actionIndex); err != nil {
+	executor, err := j.GetExecutor(f)
+	if err != nil {
 		return Failure, err
 	}
 
-	j.Status = Success
+	status, err = j.Attempt(ctx, name, verbose, timestamp, func(ctx context.Context, randomContainerName string) error {
+		f.emit(Event{Type: JobCreated, Path: j.key, Status: Pending, Pod: randomContainerName, Executor: j.executorName(f)})
+		if err := executor.Execute(ctx, j, randomContainerName, verbose, timestamp, f, stageIndex, actionIndex); err != nil {
+			return err
+		}
+		return j.ResultError()
+	})
 
-	return Success, nil
+	// The artifacts are archived only when the job succeeded, and the job fails if they can't be archived.
+	if status == Success && len(j.Artifacts) > 0 {
+		if err := j.ArchiveArtifacts(ctx, name, verbose, timestamp, f); err != nil {
+			return Failure, err
+		}
+	}
+	return status, err
 }
 
-func (j *Job) RunKubectl(name string, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) (string, error) {
+func (j *Job) RunKubectl(ctx context.Context, name string, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) (status string, err error) {
+	j.setRun(f, stageIndex, actionIndex)
+	ctx, cancel := j.WithTimeout(ctx)
+	defer cancel()
 
 	j.SaveDatabase(verbose, timestamp, f, stageIndex, actionIndex)
 
+	startTime := time.Now()
+	defer func() {
+		j.Status = status
+		j.SaveData(startTime, f, verbose, timestamp)
+	}()
+
 	originYaml := []byte{}
 	if u, err := url.Parse(j.Kubectl); err != nil {
 		return Failure, err
@@ -130,22 +195,29 @@ func (j *Job) RunKubectl(name string, verbose, timestamp bool, f *Flow, stageInd
 		return Failure, err
 	}
 	apiServerInsecure := fmt.Sprintf("http:%s:8080", strings.Split(configFile.Host, ":")[1])
-	namespace := "default"
-	if f.Namespace != "" {
-		namespace = f.Namespace
-	}
-	randomContainerName := fmt.Sprintf("kubectl-create-%s", utils.RandomString(10))
-	podTemplate := j.KubectlPodTemplates(randomContainerName, apiServerInsecure, namespace, base64Yaml, f)
+	namespace := f.KubernetesNamespace()
 
-	if err := j.InvokePod(podTemplate, randomContainerName, verbose, timestamp, f, stageIndex, actionIndex); err != nil {
-		return Failure, err
-	}
+	return j.Attempt(ctx, "kubectl-create", verbose, timestamp, func(ctx context.Context, randomContainerName string) error {
+		f.emit(Event{Type: JobCreated, Path: j.key, Status: Pending, Pod: randomContainerName, Executor: KubernetesExecutor})
+		podTemplate := j.KubectlPodTemplates(randomContainerName, apiServerInsecure, namespace, base64Yaml, f)
+		if err := j.InvokePod(ctx, podTemplate, randomContainerName, verbose, timestamp, f, stageIndex, actionIndex); err != nil {
+			return err
+		}
+		return j.ResultError()
+	})
+}
 
-	j.Status = Success
-	return Success, nil
+// WithTimeout returns a context cancelled after the timeout seconds of job, zero timeout means never.
+func (j *Job) WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if j.Timeout > 0 {
+		return context.WithTimeout(ctx, time.Duration(j.Timeout)*time.Second)
+	}
+	return context.WithCancel(ctx)
 }
 
-func (j *Job) InvokePod(podTemplate *apiv1.Pod, randomContainerName string, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) error {
+// InvokePod creates the pod of job and follows the logs until the pod finished. When the context is done,
+// the pod will be deleted.
+func (j *Job) InvokePod(ctx context.Context, podTemplate *apiv1.Pod, randomContainerName string, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) error {
 	home, _ := homeDir.Dir()
 	if config, err := clientcmd.BuildConfigFromFlags("", fmt.Sprintf("%s/.kube/config", home)); err != nil {
 		return err
@@ -153,26 +225,53 @@ func (j *Job) InvokePod(podTemplate *apiv1.Pod, randomContainerName string, verb
 		if clientSet, err := kubernetes.NewForConfig(config); err != nil {
 			return err
 		} else {
-			p := clientSet.CoreV1().Pods(apiv1.NamespaceDefault)
+			p := clientSet.CoreV1().Pods(f.KubernetesNamespace())
 			if _, err := p.Create(podTemplate); err != nil {
 				j.Status = Failure
 				return err
 			}
 
+			// Delete the pod when the job timeout or cancelled.
+			finished := make(chan struct{})
+			defer close(finished)
+			go func() {
+				select {
+				case <-ctx.Done():
+					if IsClosed(finished) {
+						return
+					}
+					j.LogLevel(model.WARN, fmt.Sprintf("Job %s is %s, delete the pod %s", j.Name, ContextStatus(ctx), randomContainerName), verbose, timestamp)
+					if err := p.Delete(randomContainerName, &metav1.DeleteOptions{}); err != nil {
+						j.LogLevel(model.ERROR, fmt.Sprintf("Delete pod %s error: %s", randomContainerName, err.Error()), verbose, timestamp)
+					}
+				case <-finished:
+				}
+			}()
+
 			j.Status = Pending
-			time.Sleep(time.Second * 2)
+			if err := Sleep(ctx, time.Second*2); err != nil {
+				return err
+			}
 
-			start := time.Now()
+			start, scheduled := time.Now(), false
 		ForLoop:
 			for {
 				pod, err := p.Get(randomContainerName, metav1.GetOptions{})
 				if err != nil {
-					j.Log(err.Error(), false, timestamp)
+					j.LogLevel(model.ERROR, err.Error(), false, timestamp)
 					return err
 				}
+				if !scheduled && pod.Spec.NodeName != "" {
+					scheduled = true
+					f.emit(Event{Type: JobScheduled, Path: j.key, Status: string(pod.Status.Phase), Pod: randomContainerName, Node: pod.Spec.NodeName})
+				}
+				reason := ""
 				switch pod.Status.Phase {
 				case apiv1.PodPending:
 					j.Log(fmt.Sprintf("Job %s is %s", j.Name, pod.Status.Phase), verbose, timestamp)
+					if status := containerStatus(pod, randomContainerName); status != nil && status.State.Waiting != nil {
+						reason = status.State.Waiting.Reason
+					}
 				case apiv1.PodRunning, apiv1.PodSucceeded:
 					break ForLoop
 				case apiv1.PodUnknown:
@@ -183,44 +282,128 @@ func (j *Job) InvokePod(podTemplate *apiv1.Pod, randomContainerName string, verb
 				}
 				duration := time.Now().Sub(start)
 				if duration.Minutes() > 3 {
-					return errors.New(fmt.Sprintf("Job %s Pending more than 3 minutes", j.Name))
+					return &ExitError{ExitCode: -1, Reason: reason, Message: fmt.Sprintf("Job %s Pending more than 3 minutes", j.Name)}
+				}
+				if err := Sleep(ctx, time.Second*2); err != nil {
+					return err
 				}
-				time.Sleep(time.Second * 2)
 			}
 
 			req := p.GetLogs(randomContainerName, &apiv1.PodLogOptions{
+				Container:  randomContainerName,
 				Follow:     true,
 				Timestamps: false,
 			})
 
 			if read, err := req.Stream(); err != nil {
-				// TODO Parse ContainerCreating error
+				j.LogLevel(model.WARN, fmt.Sprintf("Follow logs of pod %s error: %s", randomContainerName, err.Error()), verbose, timestamp)
 			} else {
-				reader := bufio.NewReader(read)
-				for {
-					line, err := reader.ReadString('\n')
-					if err != nil {
-						if err == io.EOF {
-							break
-						}
-						j.Status = Failure
-						return err
+				go func() {
+					select {
+					case <-ctx.Done():
+						read.Close()
+					case <-finished:
 					}
-					if strings.Contains(line, "[COUT]") && len(j.Outputs) != 0 {
-						j.FetchOutputs(f.Stages[stageIndex].Name, f.Stages[stageIndex].Actions[actionIndex].Name, line)
+				}()
+
+				if err := j.ReadLogs(read, verbose, timestamp, f, stageIndex, actionIndex); err != nil {
+					if ctx.Err() != nil {
+						return ctx.Err()
 					}
+					j.Status = Failure
+					return err
+				}
+			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			// The logs end before the container terminated, wait for the exit code of container.
+			err := j.WaitPod(ctx, clientSet, f.KubernetesNamespace(), randomContainerName)
+
+			// The services keep the pod running after the job container exited, the pod is deleted to stop them.
+			if len(podTemplate.Spec.Containers) > 1 && ctx.Err() == nil {
+				if err := p.Delete(randomContainerName, &metav1.DeleteOptions{}); err != nil {
+					j.LogLevel(model.WARN, fmt.Sprintf("Delete pod %s error: %s", randomContainerName, err.Error()), verbose, timestamp)
+				}
+			}
+			return err
+		}
+	}
+}
+
+// WaitPod waits until the job container or the pod of job finished, and returns ExitError with the exit code and
+// reason of the terminated container when it failed. The job container ends the job although the services of pod
+// are still running.
+func (j *Job) WaitPod(ctx context.Context, clientSet *kubernetes.Clientset, namespace, randomContainerName string) error {
+	for {
+		pod, err := clientSet.CoreV1().Pods(namespace).Get(randomContainerName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
 
-					j.Status = Running
+		if status := containerStatus(pod, randomContainerName); status != nil && status.State.Terminated != nil {
+			if terminated := status.State.Terminated; terminated.ExitCode != 0 {
+				return &ExitError{ExitCode: int(terminated.ExitCode), Reason: terminated.Reason, Message: terminated.Message}
+			}
+			return nil
+		}
 
-					j.Log(line, false, timestamp)
-					f.Log(line, verbose, timestamp)
+		switch pod.Status.Phase {
+		case apiv1.PodSucceeded:
+			return nil
+		case apiv1.PodFailed:
+			// The pod fails without the exit code of job container when an init container failed.
+			statuses := append(append([]apiv1.ContainerStatus{}, pod.Status.ContainerStatuses...), pod.Status.InitContainerStatuses...)
+			for _, status := range statuses {
+				if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+					return &ExitError{ExitCode: int(terminated.ExitCode), Reason: terminated.Reason, Message: terminated.Message}
 				}
 			}
+			return &ExitError{ExitCode: -1, Reason: pod.Status.Reason, Message: pod.Status.Message}
+		}
+
+		if err := Sleep(ctx, time.Second); err != nil {
+			return err
+		}
+	}
+}
+
+// containerStatus returns the status of the container in pod, it's nil before the container is created.
+func containerStatus(pod *apiv1.Pod, name string) *apiv1.ContainerStatus {
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == name {
+			return &pod.Status.ContainerStatuses[i]
 		}
 	}
 	return nil
 }
 
+// ParseLog fetches the outputs from a log line of job and records the line. The outputs are fetched from the
+// line as it is, the secret values are masked only in the recorded line.
+func (j *Job) ParseLog(line string, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) {
+	if strings.Contains(line, "[COUT]") {
+		j.fetchResult(line)
+	}
+	if strings.Contains(line, "[COUT]") && len(j.Outputs) != 0 {
+		if err := j.FetchOutputs(f, f.Stages[stageIndex].Name, f.Stages[stageIndex].Actions[actionIndex].Name, line); err != nil {
+			j.LogLevel(model.ERROR, fmt.Sprintf("Fetch outputs of job [%s] error: %s", j.Name, err.Error()), false, timestamp)
+		}
+	}
+
+	line = f.MaskSecrets(line)
+	j.Status = Running
+	if len(j.Attempts) > 0 {
+		attempt := &j.Attempts[len(j.Attempts)-1]
+		attempt.Logs = append(attempt.Logs, line)
+	}
+
+	key := fmt.Sprintf("%s.%s.%s", f.Stages[stageIndex].Name, f.Stages[stageIndex].Actions[actionIndex].Name, j.Name)
+	j.output(line, verbose, timestamp)
+	f.emit(Event{Type: JobOutput, Path: key, Line: strings.TrimRight(line, "\r\n")})
+}
+
 func (j *Job) SaveDatabase(verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) {
 	// Save Job into database
 	job := new(model.JobV1)
@@ -230,34 +413,62 @@ func (j *Job) SaveDatabase(verbose, timestamp bool, f *Flow, stageIndex, actionI
 	subscriptions, _ := json.Marshal(j.Subscriptions)
 	jobID, err := job.Put(f.Stages[stageIndex].Actions[actionIndex].ID, j.Timeout, j.Name, j.T, j.Endpoint, string(resources), string(environments), string(outputs), string(subscriptions))
 	if err != nil {
-		j.Log(fmt.Sprintf("Save Job [%s] errorK: %s", j.Name, err.Error()), false, timestamp)
+		j.LogLevel(model.ERROR, fmt.Sprintf("Save Job [%s] errorK: %s", j.Name, err.Error()), false, timestamp)
 	}
 	j.ID = jobID
+}
 
-	// Record Job data
+// SaveData records the result of job running.
+func (j *Job) SaveData(startTime time.Time, f *Flow, verbose, timestamp bool) {
 	jobData := new(model.JobDataV1)
-	startTime := time.Now()
 
-	defer func() {
-		currentNumber, err := jobData.GetNumbers(j.ID)
-		if err != nil {
-			j.Log(fmt.Sprintf("Get Job Data [%s] Numbers error: %s", j.Name, err.Error()), verbose, timestamp)
-		}
-		if err := jobData.Put(j.ID, currentNumber+1, j.Status, startTime, time.Now()); err != nil {
-			j.Log(fmt.Sprintf("Save Job Data [%s] error: %s", j.Name, err.Error()), false, timestamp)
-		}
-	}()
+	currentNumber, err := jobData.GetNumbers(j.ID)
+	if err != nil {
+		j.LogLevel(model.ERROR, fmt.Sprintf("Get Job Data [%s] Numbers error: %s", j.Name, err.Error()), verbose, timestamp)
+	}
+	jobData.ExitCode, jobData.Reason = int64(j.ExitCode), j.Reason
+	if artifacts := j.ArchivedArtifacts(); len(artifacts) > 0 {
+		data, _ := json.Marshal(artifacts)
+		jobData.Artifacts = string(data)
+	}
+	if err := jobData.Put(j.ID, currentNumber+1, f.Number, j.Status, startTime, time.Now()); err != nil {
+		j.LogLevel(model.ERROR, fmt.Sprintf("Save Job Data [%s] error: %s", j.Name, err.Error()), false, timestamp)
+		return
+	}
+	j.SaveAttempts(jobData.ID, verbose, timestamp)
 }
 
-func (j *Job) FetchOutputs(stageName, actionName, log string) error {
-	output := strings.TrimPrefix(log, "[COUT]")
-	splits := strings.Split(output, "=")
+// fetchResult records the value of a `[COUT] CO_RESULT = VALUE` log line, whether or not CO_RESULT is an output of job.
+func (j *Job) fetchResult(log string) {
+	output := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(log), "[COUT]"))
+	if splits := strings.SplitN(output, "=", 2); len(splits) == 2 && strings.TrimSpace(splits[0]) == "CO_RESULT" {
+		j.result = strings.TrimSpace(splits[1])
+	}
+}
+
+// ResultError returns ExitError when the job outputs a false CO_RESULT, the component reports its failure by it
+// even if the container exits with zero.
+func (j *Job) ResultError() error {
+	if result, err := strconv.ParseBool(j.result); err == nil && !result {
+		return &ExitError{ExitCode: 0, Reason: ResultFalse, Message: fmt.Sprintf("Job [%s] outputs CO_RESULT = %s", j.Name, j.result)}
+	}
+	return nil
+}
+
+// FetchOutputs saves the output of a `[COUT] KEY = VALUE` log line into the outputs of the flow run.
+func (j *Job) FetchOutputs(f *Flow, stageName, actionName, log string) error {
+	output := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(log), "[COUT]"))
+	splits := strings.SplitN(output, "=", 2)
+	if len(splits) != 2 {
+		return fmt.Errorf("Invalid output: %s", output)
+	}
+
 	for _, o := range j.Outputs {
 		if strings.TrimSpace(o) == strings.TrimSpace(splits[0]) {
 			key := fmt.Sprintf("%s.%s.%s[%s]", stageName, actionName, j.Name, o)
-			RWlock.Lock()
-			GlobalOutputs[key] = strings.TrimSpace(splits[1])
-			RWlock.Unlock()
+			if err := f.GetOutputs().Set(j.ID, key, strings.TrimSpace(splits[1])); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
@@ -269,9 +480,7 @@ func (j *Job) KubectlPodTemplates(randomContainerName, apiServer, namespace, yam
 			Kind:       "Pod",
 			APIVersion: "v1",
 		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name: randomContainerName,
-		},
+		ObjectMeta: f.KubernetesMeta(randomContainerName, j),
 		Spec: apiv1.PodSpec{
 			Containers: []apiv1.Container{
 				{
@@ -323,25 +532,50 @@ func (j *Job) PodTemplates(randomContainerName string, f *Flow) *apiv1.Pod {
 			Kind:       "Pod",
 			APIVersion: "v1",
 		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name: randomContainerName,
-		},
+		ObjectMeta: f.KubernetesMeta(randomContainerName, j),
 		Spec: apiv1.PodSpec{
 			Containers: []apiv1.Container{
 				{
-					Name:  randomContainerName,
-					Image: j.Endpoint,
-					Resources: apiv1.ResourceRequirements{
-						Requests: apiv1.ResourceList{
-							apiv1.ResourceCPU:    resource.MustParse(j.Resources.CPU),
-							apiv1.ResourceMemory: resource.MustParse(j.Resources.Memory),
-						},
-					},
+					Name:         randomContainerName,
+					Image:        j.Endpoint,
+					Command:      j.Command,
+					Args:         j.Args,
+					Resources:    j.Resources.Requirements(),
+					VolumeMounts: volumeMounts(j.VolumeMounts),
 				},
 			},
 			RestartPolicy: apiv1.RestartPolicyNever,
 		},
 	}
+	result.Spec.Containers[0].Env = j.EnvVars(f)
+
+	var workspace *apiv1.VolumeMount
+	if f.Workspace != nil && f.workspace != "" {
+		volume, mount := workspaceVolume(f)
+		result.Spec.Volumes = append(result.Spec.Volumes, volume)
+		result.Spec.Containers[0].VolumeMounts = append(result.Spec.Containers[0].VolumeMounts, mount)
+		workspace = &mount
+	}
+
+	if j.Pod != nil {
+		j.Pod.apply(&result.Spec, workspace)
+	}
+
+	// The secrets are referenced from the Kubernetes Secret of the same name as pod.
+	for _, env := range j.SecretEnvs(f) {
+		result.Spec.Containers[0].Env = append(result.Spec.Containers[0].Env, apiv1.EnvVar{Name: env.Name,
+			ValueFrom: &apiv1.EnvVarSource{SecretKeyRef: &apiv1.SecretKeySelector{
+				LocalObjectReference: apiv1.LocalObjectReference{Name: randomContainerName}, Key: env.Name}}})
+	}
+
+	return result
+}
+
+// EnvVars returns the environments of job container, including the user defined environments,
+// the flow environments and the subscriptions. The secrets are returned by SecretEnvs.
+func (j *Job) EnvVars(f *Flow) []apiv1.EnvVar {
+	result := []apiv1.EnvVar{}
+
 	//Add user defined enviroments
 	if len(j.Environments) > 0 {
 		for _, environment := range j.Environments {
@@ -350,7 +584,7 @@ func (j *Job) PodTemplates(randomContainerName string, f *Flow) *apiv1.Pod {
 					Name:  k,
 					Value: v,
 				}
-				result.Spec.Containers[0].Env = append(result.Spec.Containers[0].Env, env)
+				result = append(result, env)
 			}
 		}
 	}
@@ -362,25 +596,31 @@ func (j *Job) PodTemplates(randomContainerName string, f *Flow) *apiv1.Pod {
 					Name:  k,
 					Value: v,
 				}
-				result.Spec.Containers[0].Env = append(result.Spec.Containers[0].Env, env)
+				result = append(result, env)
 			}
 		}
 	}
 
+	//Add the path of workspace
+	if f.Workspace != nil {
+		result = append(result, apiv1.EnvVar{Name: "CO_WORKSPACE", Value: f.Workspace.MountPath()})
+	}
+
 	//Add user defined subscrptions
 	if len(j.Subscriptions) > 0 {
 		for _, subscription := range j.Subscriptions {
 			for k, env_key := range subscription {
-				if env_value, ok := GlobalOutputs[k]; ok {
+				if env_value, ok := f.GetOutputs().Get(k); ok {
 					env := apiv1.EnvVar{
 						Name:  env_key,
 						Value: env_value,
 					}
-					result.Spec.Containers[0].Env = append(result.Spec.Containers[0].Env, env)
+					result = append(result, env)
 				}
 			}
 		}
 	}
+
 	return result
 }
 
@@ -0,0 +1,260 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	homeDir "github.com/mitchellh/go-homedir"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+const (
+	// The labels and annotations of the Kubernetes objects of jobs. The label values are sanitized,
+	// the exact URI and tag of flow are the annotations.
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	LabelFlow      = "pilotage.containerops.io/flow"
+	LabelTag       = "pilotage.containerops.io/tag"
+	LabelNumber    = "pilotage.containerops.io/number"
+	LabelStage     = "pilotage.containerops.io/stage"
+	LabelAction    = "pilotage.containerops.io/action"
+	LabelJob       = "pilotage.containerops.io/job"
+	AnnotationURI  = "pilotage.containerops.io/uri"
+	AnnotationTag  = "pilotage.containerops.io/tag"
+
+	ManagedByPilotage = "pilotage"
+)
+
+var labelValueReplacer = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+func init() {
+	RegisterExecutor(KubernetesExecutor, &KubernetesJobExecutor{})
+}
+
+// KubernetesNamespace returns the Kubernetes namespace of the job pods, it's the namespace of flow or default.
+func (f *Flow) KubernetesNamespace() string {
+	if f.Namespace == "" {
+		return apiv1.NamespaceDefault
+	}
+	return f.Namespace
+}
+
+// KubernetesMeta returns the metadata of a Kubernetes object of the flow run, the object of job is labelled with
+// the stage, action and job besides the flow, tag and number of run.
+func (f *Flow) KubernetesMeta(name string, j *Job) metav1.ObjectMeta {
+	meta := metav1.ObjectMeta{
+		Name:      name,
+		Namespace: f.KubernetesNamespace(),
+		Labels: map[string]string{
+			LabelManagedBy: ManagedByPilotage,
+			LabelFlow:      labelValue(strings.Replace(f.URI, "/", ".", -1)),
+			LabelTag:       labelValue(f.Tag),
+			LabelNumber:    strconv.FormatInt(f.Number, 10),
+		},
+		Annotations: map[string]string{AnnotationURI: f.URI, AnnotationTag: f.Tag},
+	}
+	if j != nil {
+		meta.Labels[LabelStage], meta.Labels[LabelAction], meta.Labels[LabelJob] = labelValue(j.stage), labelValue(j.action), labelValue(j.Name)
+	}
+	return meta
+}
+
+// labelValue replaces the invalid characters of Kubernetes label value, it's at most 63 characters beginning
+// and ending with an alphanumeric character.
+func labelValue(value string) string {
+	value = labelValueReplacer.ReplaceAllString(value, "-")
+	if len(value) > 63 {
+		value = value[:63]
+	}
+	return strings.Trim(value, "_.-")
+}
+
+// KubernetesJobExecutor runs job as a pod in the Kubernetes cluster of `~/.kube/config`.
+type KubernetesJobExecutor struct {
+}
+
+// The secrets of job are saved in a Kubernetes Secret of the same name as pod, which is deleted with the pod.
+func (k *KubernetesJobExecutor) Execute(ctx context.Context, j *Job, containerName string, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) error {
+	podTemplate := j.PodTemplates(containerName, f)
+
+	if envs := j.SecretEnvs(f); len(envs) > 0 {
+		clientSet, err := kubernetesClient()
+		if err != nil {
+			return err
+		}
+
+		secret := &apiv1.Secret{ObjectMeta: f.KubernetesMeta(containerName, j), Type: apiv1.SecretTypeOpaque,
+			Data: map[string][]byte{}}
+		for _, env := range envs {
+			secret.Data[env.Name] = []byte(env.Value)
+		}
+
+		secrets := clientSet.CoreV1().Secrets(f.KubernetesNamespace())
+		if _, err := secrets.Create(secret); err != nil {
+			return err
+		}
+		defer func() {
+			if err := secrets.Delete(containerName, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				j.LogLevel(model.ERROR, fmt.Sprintf("Delete secret %s error: %s", containerName, err.Error()), verbose, timestamp)
+			}
+		}()
+	}
+
+	return j.InvokePod(ctx, podTemplate, containerName, verbose, timestamp, f, stageIndex, actionIndex)
+}
+
+// Clean deletes the pod and secret of job or the PersistentVolumeClaim of workspace in the namespace of flow,
+// the ones already deleted are ignored.
+func (k *KubernetesJobExecutor) Clean(f *Flow, containerName string) error {
+	clientSet, err := kubernetesClient()
+	if err != nil {
+		return err
+	}
+
+	namespace := f.KubernetesNamespace()
+	if err := clientSet.CoreV1().Pods(namespace).Delete(containerName, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err := clientSet.CoreV1().Secrets(namespace).Delete(containerName, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err := clientSet.CoreV1().PersistentVolumeClaims(namespace).Delete(containerName, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// CreateWorkspace creates the PersistentVolumeClaim of workspace mounted by the job pods.
+func (k *KubernetesJobExecutor) CreateWorkspace(f *Flow, name string) error {
+	clientSet, err := kubernetesClient()
+	if err != nil {
+		return err
+	}
+
+	w := f.Workspace
+	size, err := resource.ParseQuantity(w.StorageSize())
+	if err != nil {
+		return err
+	}
+	claim := &apiv1.PersistentVolumeClaim{
+		ObjectMeta: f.KubernetesMeta(name, nil),
+		Spec: apiv1.PersistentVolumeClaimSpec{
+			AccessModes: []apiv1.PersistentVolumeAccessMode{apiv1.PersistentVolumeAccessMode(w.Mode())},
+			Resources: apiv1.ResourceRequirements{
+				Requests: apiv1.ResourceList{apiv1.ResourceStorage: size},
+			},
+		},
+	}
+	if w.StorageClass != "" {
+		claim.Spec.StorageClassName = &w.StorageClass
+	}
+
+	_, err = clientSet.CoreV1().PersistentVolumeClaims(f.KubernetesNamespace()).Create(claim)
+	return err
+}
+
+// DeleteWorkspace deletes the PersistentVolumeClaim of workspace.
+func (k *KubernetesJobExecutor) DeleteWorkspace(f *Flow, name string) error {
+	clientSet, err := kubernetesClient()
+	if err != nil {
+		return err
+	}
+
+	if err := clientSet.CoreV1().PersistentVolumeClaims(f.KubernetesNamespace()).Delete(name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// Archive runs a pod mounting the workspace to upload the artifact, the pod is deleted after it finished.
+func (k *KubernetesJobExecutor) Archive(ctx context.Context, containerName string, f *Flow, j *Job, a *Artifact, url string) error {
+	clientSet, err := kubernetesClient()
+	if err != nil {
+		return err
+	}
+
+	volume, mount := workspaceVolume(f)
+	pod := &apiv1.Pod{
+		ObjectMeta: f.KubernetesMeta(containerName, j),
+		Spec: apiv1.PodSpec{
+			Containers: []apiv1.Container{{
+				Name:         containerName,
+				Image:        artifactImage(),
+				Command:      []string{"sh", "-c", artifactCommand(mount.MountPath, a, url)},
+				VolumeMounts: []apiv1.VolumeMount{mount},
+			}},
+			Volumes:       []apiv1.Volume{volume},
+			RestartPolicy: apiv1.RestartPolicyNever,
+		},
+	}
+
+	pods := clientSet.CoreV1().Pods(f.KubernetesNamespace())
+	if _, err := pods.Create(pod); err != nil {
+		return err
+	}
+	defer pods.Delete(containerName, &metav1.DeleteOptions{})
+
+	for {
+		if err := Sleep(ctx, time.Second*2); err != nil {
+			return err
+		}
+
+		pod, err := pods.Get(containerName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		switch pod.Status.Phase {
+		case apiv1.PodSucceeded:
+			return nil
+		case apiv1.PodFailed:
+			if len(pod.Status.ContainerStatuses) > 0 && pod.Status.ContainerStatuses[0].State.Terminated != nil {
+				terminated := pod.Status.ContainerStatuses[0].State.Terminated
+				return &ExitError{ExitCode: int(terminated.ExitCode), Reason: terminated.Reason, Message: terminated.Message}
+			}
+			return fmt.Errorf("Pod %s failed: %s", containerName, pod.Status.Reason)
+		}
+	}
+}
+
+// workspaceVolume returns the volume of the workspace PersistentVolumeClaim and the mount of it.
+func workspaceVolume(f *Flow) (apiv1.Volume, apiv1.VolumeMount) {
+	volume := apiv1.Volume{Name: "workspace", VolumeSource: apiv1.VolumeSource{
+		PersistentVolumeClaim: &apiv1.PersistentVolumeClaimVolumeSource{ClaimName: f.workspace}}}
+	return volume, apiv1.VolumeMount{Name: volume.Name, MountPath: f.Workspace.MountPath()}
+}
+
+// kubernetesClient returns the client of the Kubernetes cluster in `~/.kube/config`.
+func kubernetesClient() (*kubernetes.Clientset, error) {
+	home, _ := homeDir.Dir()
+	config, err := clientcmd.BuildConfigFromFlags("", fmt.Sprintf("%s/.kube/config", home))
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
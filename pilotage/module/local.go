@@ -0,0 +1,73 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// LocalJobExecutor runs the endpoint of job as a shell command line in the local, it's
+// useful to debug a flow without container runtime.
+type LocalJobExecutor struct {
+}
+
+func (l *LocalJobExecutor) Execute(ctx context.Context, j *Job, containerName string, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", j.Endpoint)
+
+	cmd.Env = os.Environ()
+	for _, env := range j.EnvVars(f) {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", env.Name, env.Value))
+	}
+	for _, env := range j.SecretEnvs(f) {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", env.Name, env.Value))
+	}
+	// The command runs in the workspace directory, and the last CO_WORKSPACE overrides the one of container path.
+	if f.Workspace != nil && f.workspace != "" {
+		cmd.Dir = localWorkspace(f.workspace)
+		cmd.Env = append(cmd.Env, fmt.Sprintf("CO_WORKSPACE=%s", cmd.Dir))
+	}
+
+	return j.RunCommand(ctx, cmd, verbose, timestamp, f, stageIndex, actionIndex)
+}
+
+// CreateWorkspace creates the workspace as a temporary directory, the size and storage class of workspace are ignored.
+func (l *LocalJobExecutor) CreateWorkspace(f *Flow, name string) error {
+	return os.MkdirAll(localWorkspace(name), 0755)
+}
+
+// DeleteWorkspace removes the directory of workspace.
+func (l *LocalJobExecutor) DeleteWorkspace(f *Flow, name string) error {
+	return os.RemoveAll(localWorkspace(name))
+}
+
+// Archive uploads the artifact in the workspace directory.
+func (l *LocalJobExecutor) Archive(ctx context.Context, containerName string, f *Flow, j *Job, a *Artifact, url string) error {
+	return uploadArtifact(ctx, localWorkspace(f.workspace), a, url)
+}
+
+// Clean removes the directory of workspace left by the daemon, the commands of jobs are stopped with the daemon.
+func (l *LocalJobExecutor) Clean(f *Flow, containerName string) error {
+	return l.DeleteWorkspace(f, containerName)
+}
+
+func localWorkspace(name string) string {
+	return filepath.Join(os.TempDir(), name)
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	. "github.com/logrusorgru/aurora"
+
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+// printLog prints the log in the color of level when verbose.
+func printLog(level, log string, verbose, timestamp bool) {
+	if verbose == false {
+		return
+	}
+
+	if timestamp == true {
+		log = fmt.Sprintf("[%s] %s", time.Now().String(), strings.TrimSpace(log))
+	}
+
+	switch level {
+	case model.ERROR:
+		fmt.Println(Red(log))
+	case model.WARN:
+		fmt.Println(Brown(log))
+	case model.DEBUG:
+		fmt.Println(Blue(log))
+	default:
+		fmt.Println(Cyan(log))
+	}
+}
+
+// StartLogRetention purges the logs in database every interval until the context is done. The logs older than
+// retention are deleted, and the DEBUG logs older than debugRetention are deleted. Zero retention keeps the logs.
+func StartLogRetention(ctx context.Context, retention, debugRetention, interval time.Duration) {
+	if retention <= 0 && debugRetention <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			PurgeLogs(retention, debugRetention)
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// PurgeLogs deletes the logs older than retention and the DEBUG logs older than debugRetention.
+func PurgeLogs(retention, debugRetention time.Duration) {
+	l := new(model.LogV1)
+
+	if debugRetention > 0 {
+		if count, err := l.Purge([]string{model.DEBUG}, time.Now().Add(-debugRetention)); err != nil {
+			printLog(model.ERROR, fmt.Sprintf("Purge DEBUG logs error: %s", err.Error()), true, true)
+		} else if count > 0 {
+			printLog(model.INFO, fmt.Sprintf("Purge %d DEBUG logs before %s", count, debugRetention), true, true)
+		}
+	}
+
+	if retention > 0 {
+		if count, err := l.Purge(nil, time.Now().Add(-retention)); err != nil {
+			printLog(model.ERROR, fmt.Sprintf("Purge logs error: %s", err.Error()), true, true)
+		} else if count > 0 {
+			printLog(model.INFO, fmt.Sprintf("Purge %d logs before %s", count, retention), true, true)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+func TestLogNumber(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("first\n")
+	if err := f.LocalRun(context.Background(), false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+
+	stage := f.Stages[1]
+	if stage.flow.runNumber() != f.Number || stage.Actions[0].flow.runNumber() != f.Number ||
+		stage.Actions[0].Jobs[0].flow.runNumber() != f.Number {
+		t.Errorf("The number of logs is [%d, %d, %d], want %d", stage.flow.runNumber(),
+			stage.Actions[0].flow.runNumber(), stage.Actions[0].Jobs[0].flow.runNumber(), f.Number)
+	}
+
+	if key := stage.Actions[0].Jobs[0].key; key != "stage0.action0.job0" {
+		t.Errorf("The key of job is %q, want %q", key, "stage0.action0.job0")
+	}
+
+	if logs := stage.Actions[0].Jobs[0].Logs; len(logs) == 0 {
+		t.Errorf("The output lines should be in the job logs")
+	}
+}
+
+func TestJobLogsConcurrent(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("first\n")
+	j := &f.Stages[1].Actions[0].Jobs[0]
+	j.setRun(f, 1, 0)
+
+	// The goroutine deleting the container of a cancelled job logs while the job records its output lines.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			j.LogLevel(model.WARN, "delete the pod", false, false)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			j.output("line\n", false, false)
+		}
+	}()
+	wg.Wait()
+
+	if len(j.Logs) != 200 {
+		t.Errorf("The job has %d logs, want 200", len(j.Logs))
+	}
+}
+
+func TestJobOutputOnce(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("first\n")
+	if err := f.LocalRun(context.Background(), false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+
+	count := func(logs []string) int {
+		n := 0
+		for _, log := range logs {
+			if strings.Contains(log, "first") {
+				n++
+			}
+		}
+		return n
+	}
+	if n := count(f.Stages[1].Actions[0].Jobs[0].Logs); n != 1 {
+		t.Errorf("The output line is %d times in the job logs, want 1", n)
+	}
+	if n := count(f.Logs); n != 0 {
+		t.Errorf("The output line is %d times in the flow logs, want 0", n)
+	}
+
+	lines, _ := f.GetLogStream().Subscribe("")
+	n := 0
+	for _, l := range lines {
+		if l.Line == "first" {
+			n++
+			if l.Job != "stage0.action0.job0" {
+				t.Errorf("The output line is published as the line of %q", l.Job)
+			}
+		}
+	}
+	if n != 1 {
+		t.Errorf("The output line is %d times in the log stream, want 1", n)
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Huawei/containerops/pilotage/config"
+)
+
+const (
+	defaultLogFileMaxSize    = 100 // Megabytes
+	defaultLogFileMaxBackups = 5
+)
+
+func init() {
+	RegisterLogSink(FileSink, NewFileLogSink)
+}
+
+// FileLogSink writes the logs as JSON lines to a local file. When the file is larger than the max size,
+// it's rotated to `path.1`, and the `path.1` is rotated to `path.2`, until the max backups.
+type FileLogSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+func NewFileLogSink(c config.SinkConfig) (LogSink, error) {
+	if c.Path == "" {
+		return nil, fmt.Errorf("The path of file log sink is required")
+	}
+
+	s := &FileLogSink{path: c.Path, maxSize: c.MaxSize * 1024 * 1024, maxBackups: c.MaxBackups}
+	if s.maxSize <= 0 {
+		s.maxSize = defaultLogFileMaxSize * 1024 * 1024
+	}
+	if s.maxBackups <= 0 {
+		s.maxBackups = defaultLogFileMaxBackups
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return nil, err
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileLogSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+// rotate renames the log file to the first backup, and removes the backup beyond the max backups.
+func (s *FileLogSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", s.path, s.maxBackups))
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+	}
+	if err := os.Rename(s.path, fmt.Sprintf("%s.1", s.path)); err != nil {
+		return err
+	}
+
+	return s.open()
+}
+
+func (s *FileLogSink) Write(entries []LogEntry) error {
+	writer := bufio.NewWriter(s.file)
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+
+		if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			if err := s.rotate(); err != nil {
+				return err
+			}
+			writer.Reset(s.file)
+		}
+
+		if _, err := writer.Write(line); err != nil {
+			return err
+		}
+		s.size += int64(len(line))
+	}
+
+	return writer.Flush()
+}
+
+func (s *FileLogSink) Close() error {
+	return s.file.Close()
+}
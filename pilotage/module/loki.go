@@ -0,0 +1,118 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Huawei/containerops/pilotage/config"
+)
+
+func init() {
+	RegisterLogSink(LokiSink, NewLokiLogSink)
+}
+
+// LokiLogSink pushes the logs to Loki. The streams are labeled with the flow, level and the labels of configurations,
+// and the line is the JSON of log, which is parsed by the `| json` of LogQL.
+type LokiLogSink struct {
+	config config.SinkConfig
+	client *http.Client
+}
+
+func NewLokiLogSink(c config.SinkConfig) (LogSink, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("The url of loki log sink is required")
+	}
+
+	return &LokiLogSink{config: c, client: &http.Client{Timeout: sinkRequestTimeout}}, nil
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// labels returns the labels of log stream, the run number and path aren't labels for the high cardinality.
+func (s *LokiLogSink) labels(entry LogEntry) map[string]string {
+	labels := map[string]string{"app": "pilotage", "level": entry.Level}
+	if entry.Flow != "" {
+		labels["flow"] = entry.Flow
+	}
+	for key, value := range s.config.Labels {
+		labels[key] = value
+	}
+	return labels
+}
+
+func (s *LokiLogSink) Write(entries []LogEntry) error {
+	streams, keys := map[string]*lokiStream{}, []string{}
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		labels := s.labels(entry)
+		key := lokiStreamKey(labels)
+		stream, ok := streams[key]
+		if !ok {
+			stream = &lokiStream{Stream: labels}
+			streams[key], keys = stream, append(keys, key)
+		}
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(entry.Time.UnixNano(), 10), string(line)})
+	}
+
+	push := struct {
+		Streams []*lokiStream `json:"streams"`
+	}{}
+	for _, key := range keys {
+		push.Streams = append(push.Streams, streams[key])
+	}
+
+	body, err := json.Marshal(push)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	if s.config.Tenant != "" {
+		header.Set("X-Scope-OrgID", s.config.Tenant)
+	}
+
+	url := fmt.Sprintf("%s/loki/api/v1/push", strings.TrimSuffix(s.config.URL, "/"))
+	_, err = postSink(s.client, s.config, url, "application/json", body, header)
+	return err
+}
+
+func (s *LokiLogSink) Close() error {
+	return nil
+}
+
+// lokiStreamKey returns the same key for the same labels.
+func lokiStreamKey(labels map[string]string) string {
+	pairs := []string{}
+	for key, value := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", key, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
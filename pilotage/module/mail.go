@@ -3,6 +3,7 @@ package module
 import (
 	"bytes"
 	"fmt"
+	"html"
 	"net/mail"
 	"net/smtp"
 
@@ -20,13 +21,17 @@ func init() {
 type MailNotifier struct {
 }
 
-func (m *MailNotifier) Notify(flow *Flow, receivers []string) error {
+func (m *MailNotifier) Notify(flow *Flow, n Notification, receiver Receiver) error {
+	message, err := receiver.Message(n)
+	if err != nil {
+		return err
+	}
 
-	subject := fmt.Sprintf("[ContainerOps] Excution Result of Flow: %s is [%s] ", flow.URI, strings.ToUpper(flow.Status))
-	htmlBody := fmt.Sprintf("Flow URI: %s <br /> Tag: %s <br /> Title: %s <br /> Result: %s", flow.URI, flow.Tag, flow.Title, flow.Status)
+	subject := fmt.Sprintf("[ContainerOps] Excution Result of Flow: %s is [%s] ", n.Subject(), strings.ToUpper(n.Status))
+	htmlBody := strings.Replace(html.EscapeString(strings.TrimSpace(message)), "\n", "<br />", -1)
 	msg := email.NewHTMLMessage(subject, htmlBody)
 	msg.From = mail.Address{Name: "ContainerOps", Address: common.Mail.User}
-	msg.To = receivers
+	msg.To = []string{receiver.Address}
 
 	//attach log to attachments
 	fileName := fmt.Sprintf("log-%s:%s-%s.txt", flow.URI, flow.Tag, time.Now().Format("20060102150405"))
@@ -35,6 +40,17 @@ func (m *MailNotifier) Notify(flow *Flow, receivers []string) error {
 		buf.WriteString("\r\n")
 		buf.WriteString(logLine)
 	}
+	// The output lines of jobs are only in the logs of jobs.
+	for _, stage := range flow.Stages {
+		for _, action := range stage.Actions {
+			for _, job := range action.Jobs {
+				for _, logLine := range job.Logs {
+					buf.WriteString("\r\n")
+					buf.WriteString(logLine)
+				}
+			}
+		}
+	}
 	data := buf.Bytes()
 	msg.Attachments[fileName] = &email.Attachment{
 		Filename: fileName,
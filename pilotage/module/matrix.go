@@ -0,0 +1,105 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+var matrixNameReplacer = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// Expand returns a job for every combination of the matrix variables. The variables are injected
+// as environments and replace the ${KEY} in the endpoint, the name of job is suffixed with the values
+// in the order of sorted keys, like build-1.8-alpine for the matrix {GO: [1.8], OS: [alpine]}.
+func (j *Job) Expand() []Job {
+	keys := []string{}
+	for k := range j.Matrix {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	combinations := []map[string]string{{}}
+	for _, k := range keys {
+		next := []map[string]string{}
+		for _, combination := range combinations {
+			for _, v := range j.Matrix[k] {
+				c := map[string]string{k: v}
+				for ck, cv := range combination {
+					c[ck] = cv
+				}
+				next = append(next, c)
+			}
+		}
+		combinations = next
+	}
+
+	jobs := []Job{}
+	for _, combination := range combinations {
+		job := *j
+		job.ID, job.Status, job.Logs, job.Attempts, job.Matrix, job.Expansions = 0, "", nil, nil, nil, nil
+
+		values, replaces := []string{}, []string{}
+		for _, k := range keys {
+			values = append(values, matrixNameReplacer.ReplaceAllString(combination[k], "-"))
+			replaces = append(replaces, fmt.Sprintf("${%s}", k), combination[k])
+		}
+		job.Name = strings.Join(append([]string{j.Name}, values...), "-")
+		job.Endpoint = strings.NewReplacer(replaces...).Replace(j.Endpoint)
+		job.Environments = append(append([]map[string]string{}, j.Environments...), combination)
+		job.Artifacts = append([]Artifact{}, j.Artifacts...)
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs
+}
+
+// RunMatrix runs the expanded jobs in parallel, every one of them has its own job record and data.
+// The status is failure, cancel or timeout of the first stopped job, the others are cancelled then as fail fast.
+func (j *Job) RunMatrix(ctx context.Context, name string, verbose, timestamp bool, f *Flow, stageIndex, actionIndex int) (string, error) {
+	j.setRun(f, stageIndex, actionIndex)
+	j.Expansions = j.Expand()
+	j.Status = Running
+
+	j.Log(fmt.Sprintf("Job [%s] expands to %d jobs", j.Name, len(j.Expansions)), verbose, timestamp)
+
+	j.Status = RunDAG(ctx, make([][]int, len(j.Expansions)), 0, true, func(ctx context.Context, index int) string {
+		job := &j.Expansions[index]
+
+		var status string
+		var err error
+		if job.Kubectl != "" {
+			status, err = job.RunKubectl(ctx, name, verbose, timestamp, f, stageIndex, actionIndex)
+		} else {
+			status, err = job.Run(ctx, name, verbose, timestamp, f, stageIndex, actionIndex)
+		}
+
+		if err != nil {
+			job.LogLevel(model.ERROR, fmt.Sprintf("Job [%s] run error: %s", job.Name, err.Error()), verbose, timestamp)
+			return Failure
+		}
+		return status
+	})
+
+	return j.Status, nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	j := &Job{Name: "build", Endpoint: "golang:${GO}-${OS}", Environments: []map[string]string{{"CO_DATA": "data"}},
+		Matrix: map[string][]string{"OS": {"alpine", "stretch"}, "GO": {"1.8", "1.9"}}}
+
+	jobs := j.Expand()
+
+	names, endpoints := []string{}, []string{}
+	for _, job := range jobs {
+		names, endpoints = append(names, job.Name), append(endpoints, job.Endpoint)
+	}
+	if want := []string{"build-1.8-alpine", "build-1.8-stretch", "build-1.9-alpine", "build-1.9-stretch"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Expanded names are %v, want %v", names, want)
+	}
+	if want := []string{"golang:1.8-alpine", "golang:1.8-stretch", "golang:1.9-alpine", "golang:1.9-stretch"}; !reflect.DeepEqual(endpoints, want) {
+		t.Errorf("Expanded endpoints are %v, want %v", endpoints, want)
+	}
+
+	want := []map[string]string{{"CO_DATA": "data"}, {"GO": "1.9", "OS": "stretch"}}
+	if !reflect.DeepEqual(jobs[3].Environments, want) {
+		t.Errorf("Expanded environments are %v, want %v", jobs[3].Environments, want)
+	}
+	if len(j.Environments) != 1 {
+		t.Errorf("The environments of matrix job are changed: %v", j.Environments)
+	}
+}
+
+func TestRunMatrix(t *testing.T) {
+	executor := newFakeExecutor()
+
+	f := newTestFlow("")
+	job := &f.Stages[1].Actions[0].Jobs[0]
+	job.Endpoint = "${GO}\n"
+	job.Matrix = map[string][]string{"GO": {"1.8", "1.9", "1.10"}}
+
+	if err := f.LocalRun(context.Background(), false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+
+	if f.Status != Success {
+		t.Errorf("Flow status is %s, want %s", f.Status, Success)
+	}
+	if len(job.Expansions) != 3 {
+		t.Fatalf("Job expands to %d jobs, want 3", len(job.Expansions))
+	}
+	for _, expansion := range job.Expansions {
+		if expansion.Status != Success {
+			t.Errorf("Job [%s] status is %s, want %s", expansion.Name, expansion.Status, Success)
+		}
+		value := executor.envs[expansion.Name]["GO"]
+		if want := expansion.Endpoint[:len(expansion.Endpoint)-1]; value != want {
+			t.Errorf("Job [%s] GO environment is %s, want %s", expansion.Name, value, want)
+		}
+	}
+}
+
+func TestRunMatrixFailure(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("")
+	job := &f.Stages[1].Actions[0].Jobs[0]
+	job.Endpoint = "${RESULT}"
+	job.Matrix = map[string][]string{"RESULT": {"fail", "block"}}
+
+	if err := f.LocalRun(context.Background(), false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+
+	if f.Status != Failure {
+		t.Errorf("Flow status is %s, want %s", f.Status, Failure)
+	}
+	if status := job.Expansions[1].Status; status != Cancel {
+		t.Errorf("The blocked job status is %s, want %s", status, Cancel)
+	}
+}
@@ -16,6 +16,17 @@ limitations under the License.
 
 package module
 
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
 const (
 	// Result Type
 	Cancel  = "cancel"
@@ -23,4 +34,88 @@ const (
 	Running = "running"
 	Failure = "failure"
 	Success = "success"
+	Timeout = "timeout"
+	Paused  = "paused"
+	Skipped = "skipped"
+
+	// Queue Status
+	Queued   = "queued"
+	Orphaned = "orphaned"
 )
+
+// namePattern is the names of namespaces, repositories, secrets and artifacts, which are safe in the file paths.
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidName checks the name is letters, digits, `_`, `.` and `-` beginning with a letter or digit, so it's
+// neither empty nor `..`.
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// ContextStatus returns the result type of a done context, it's empty when the context is not done.
+func ContextStatus(ctx context.Context) string {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return Timeout
+	case context.Canceled:
+		return Cancel
+	}
+
+	return ""
+}
+
+// IsStopped returns true when the status stops the running of flow.
+func IsStopped(status string) bool {
+	return status == Failure || status == Cancel || status == Timeout
+}
+
+// IsClosed checks whether the channel is closed without blocking.
+func IsClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// Sleep pauses for the duration, it returns the error of context when the context is done before.
+func Sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// postHTTP posts the body with the headers and returns the response body, the 4xx and 5xx responses are errors.
+func postHTTP(client *http.Client, url, contentType string, body []byte, header http.Header) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		// The url isn't in the error, it may have the token like the webhook of Slack.
+		return nil, fmt.Errorf("Response %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	return data, nil
+}
@@ -1,13 +1,36 @@
 package module
 
 import (
+	"bytes"
 	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/Huawei/containerops/pilotage/model"
 )
 
+const (
+	// Notification Trigger, the other triggers are the result types.
+	ChangedTrigger = "changed"
+
+	notifyRequestTimeout = 10 * time.Second
+)
+
+// DefaultNotificationTemplate is the message of receivers without template, the data is Notification.
+const DefaultNotificationTemplate = `[ContainerOps] Flow {{.URI}}:{{.Tag}} #{{.Number}}{{if .Path}} {{.Path}}{{end}} is {{.Status}}{{if .Title}}: {{.Title}}{{end}}
+Duration: {{.Duration}}{{if and .Previous (ne .Previous .Status)}}, the last run is {{.Previous}}{{end}}
+{{range .Stages}}
+Stage {{.Name}}: {{.Status}}{{range .Actions}}{{if ne .Status "success"}}
+  Action {{.Name}}: {{.Status}}{{end}}{{end}}{{end}}
+`
+
 var Notifiers = make(map[string]Notifier)
 
+// Notifier sends the notification of flow run to a receiver, the notification is the result of flow, or the
+// result of a stage or an action when the receiver is attached to them.
 type Notifier interface {
-	Notify(flow *Flow, receivers []string) error
+	Notify(flow *Flow, n Notification, receiver Receiver) error
 }
 
 func Register(name string, notifier Notifier) error {
@@ -17,3 +40,211 @@ func Register(name string, notifier Notifier) error {
 	Notifiers[name] = notifier
 	return nil
 }
+
+// Receiver receives the flow execution result. The on triggers are the result types like success and failure,
+// or `changed` when the result is different from the last run, and the receiver without triggers receives all.
+// The template is a Go text/template of the message, and the data is Notification.
+type Receiver struct {
+	Type     string   `json:"type" yaml:"type"`
+	Address  string   `json:"address" yaml:"address"`
+	Secret   string   `json:"secret,omitempty" yaml:"secret,omitempty"`
+	On       []string `json:"on,omitempty" yaml:"on,omitempty"`
+	Template string   `json:"template,omitempty" yaml:"template,omitempty"`
+}
+
+// Match returns whether the receiver is notified of the result, the previous is the result of last run,
+// and it's empty for the first run.
+func (r *Receiver) Match(status, previous string) bool {
+	if len(r.On) == 0 {
+		return true
+	}
+
+	for _, on := range r.On {
+		if on == status || (on == ChangedTrigger && status != previous) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Message renders the template of receiver with the notification, the secret values of flow are masked.
+func (r *Receiver) Message(n Notification) (string, error) {
+	text := r.Template
+	if text == "" {
+		text = DefaultNotificationTemplate
+	}
+
+	t, err := template.New("notification").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	if err := t.Execute(buffer, n); err != nil {
+		return "", err
+	}
+	return maskSecrets(buffer.String(), n.masks), nil
+}
+
+// Notification is the result of flow run with the summaries of stages. The event is flow.finished,
+// stage.finished or action.finished, the path and the only stage summary are the stage or action of event.
+type Notification struct {
+	Event    string         `json:"event"`
+	Path     string         `json:"path,omitempty"`
+	URI      string         `json:"uri"`
+	Tag      string         `json:"tag"`
+	Title    string         `json:"title"`
+	Number   int64          `json:"number"`
+	Status   string         `json:"status"`
+	Previous string         `json:"previous,omitempty"`
+	Start    time.Time      `json:"start"`
+	End      time.Time      `json:"end"`
+	Duration string         `json:"duration"`
+	Stages   []StageSummary `json:"stages"`
+
+	masks []string
+}
+
+// Subject returns the URI of flow, and the path of stage or action for their notifications.
+func (n Notification) Subject() string {
+	if n.Path != "" {
+		return fmt.Sprintf("%s %s", n.URI, n.Path)
+	}
+	return n.URI
+}
+
+type StageSummary struct {
+	Name    string          `json:"name"`
+	Status  string          `json:"status"`
+	Actions []ActionSummary `json:"actions"`
+}
+
+type ActionSummary struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// Notification returns the result of flow run, the start and end stages are omitted.
+func (f *Flow) Notification() Notification {
+	n := f.notification(FlowFinished, "", f.Status, f.previous, f.start, f.end)
+
+	for _, stage := range f.Stages {
+		if stage.T == StartStage || stage.T == EndStage {
+			continue
+		}
+		n.Stages = append(n.Stages, stage.summary(stage.Actions))
+	}
+
+	return n
+}
+
+// StageNotification returns the result of a finished stage.
+func (f *Flow) StageNotification(stageIndex int) Notification {
+	stage := &f.Stages[stageIndex]
+
+	n := f.notification(StageFinished, stage.Name, stage.Status, stage.previous(f), stage.start, stage.end)
+	n.Stages = append(n.Stages, stage.summary(stage.Actions))
+
+	return n
+}
+
+// ActionNotification returns the result of a finished action, the stage of summary has only the action.
+func (f *Flow) ActionNotification(stageIndex, actionIndex int) Notification {
+	stage, action := &f.Stages[stageIndex], &f.Stages[stageIndex].Actions[actionIndex]
+
+	n := f.notification(ActionFinished, fmt.Sprintf("%s.%s", stage.Name, action.Name), action.Status,
+		action.previous(f), action.start, action.end)
+	n.Stages = append(n.Stages, stage.summary([]Action{*action}))
+
+	return n
+}
+
+func (f *Flow) notification(event, path, status, previous string, start, end time.Time) Notification {
+	return Notification{Event: event, Path: path, URI: f.URI, Tag: f.Tag, Title: f.Title, Number: f.Number,
+		Status: status, Previous: previous, Start: start, End: end,
+		Duration: (end.Sub(start) / time.Second * time.Second).String(), Stages: []StageSummary{}, masks: f.masks}
+}
+
+func (s *Stage) summary(actions []Action) StageSummary {
+	summary := StageSummary{Name: s.Name, Status: s.Status, Actions: []ActionSummary{}}
+	for _, action := range actions {
+		summary.Actions = append(summary.Actions, ActionSummary{Name: action.Name, Status: action.Status})
+	}
+	return summary
+}
+
+// previous returns the result of stage in the last run, it's empty when the stage didn't run.
+func (s *Stage) previous(f *Flow) string {
+	if f.Number <= 1 {
+		return ""
+	}
+
+	stageData := new(model.StageDataV1)
+	if data, err := stageData.ListByFlowNumber([]int64{s.ID}, f.Number-1); err == nil && len(data) > 0 {
+		return data[0].Result
+	}
+	return ""
+}
+
+// previous returns the result of action in the last run, it's empty when the action didn't run.
+func (a *Action) previous(f *Flow) string {
+	if f.Number <= 1 {
+		return ""
+	}
+
+	actionData := new(model.ActionDataV1)
+	if data, err := actionData.ListByFlowNumber([]int64{a.ID}, f.Number-1); err == nil && len(data) > 0 {
+		return data[0].Result
+	}
+	return ""
+}
+
+// Notify sends the result of flow run to the receivers whose triggers match the result.
+func (f *Flow) Notify(verbose, timestamp bool) {
+	f.notify(f.Receivers, f.Notification(), verbose, timestamp)
+}
+
+// eventNotifier returns the function sending the result of the finished stage or action to its receivers, the
+// result is the one when the event is published while the other actions of stage may be still running.
+func (f *Flow) eventNotifier(e Event, verbose, timestamp bool) func() {
+	for i := range f.Stages {
+		stage := &f.Stages[i]
+		if e.Type == StageFinished && e.Path == stage.Name && len(stage.Receivers) > 0 {
+			n := f.StageNotification(i)
+			return func() { f.notify(stage.Receivers, n, verbose, timestamp) }
+		}
+
+		for j := range stage.Actions {
+			action := &stage.Actions[j]
+			if e.Type == ActionFinished && e.Path == fmt.Sprintf("%s.%s", stage.Name, action.Name) && len(action.Receivers) > 0 {
+				n := f.ActionNotification(i, j)
+				return func() { f.notify(action.Receivers, n, verbose, timestamp) }
+			}
+		}
+	}
+	return func() {}
+}
+
+func (f *Flow) notify(receivers []Receiver, n Notification, verbose, timestamp bool) {
+	for i := range receivers {
+		receiver := &receivers[i]
+		if !receiver.Match(n.Status, n.Previous) {
+			continue
+		}
+
+		notifier, ok := Notifiers[receiver.Type]
+		if !ok {
+			f.LogLevel(model.ERROR, fmt.Sprintf("Unknown receiver type: %s", receiver.Type), verbose, timestamp)
+			continue
+		}
+
+		if err := notifier.Notify(f, n, *receiver); err != nil {
+			f.LogLevel(model.ERROR, fmt.Sprintf("Notify %s receiver of %s error: %s", receiver.Type, n.Event, err.Error()), verbose, timestamp)
+		} else {
+			f.Log(fmt.Sprintf("Notify %s receiver of %s success", receiver.Type, n.Event), verbose, timestamp)
+		}
+	}
+}
+
+var notifyClient = &http.Client{Timeout: notifyRequestTimeout}
@@ -0,0 +1,175 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReceiverMatch(t *testing.T) {
+	cases := []struct {
+		on       []string
+		status   string
+		previous string
+		want     bool
+	}{
+		{nil, Success, Success, true},
+		{[]string{Failure}, Failure, Success, true},
+		{[]string{Failure}, Success, Failure, false},
+		{[]string{ChangedTrigger}, Success, Failure, true},
+		{[]string{ChangedTrigger}, Success, Success, false},
+		{[]string{ChangedTrigger}, Failure, "", true},
+		{[]string{Failure, ChangedTrigger}, Failure, Failure, true},
+	}
+
+	for _, c := range cases {
+		r := Receiver{On: c.on}
+		if got := r.Match(c.status, c.previous); got != c.want {
+			t.Errorf("Receiver on %v matches %s after %q is %v, want %v", c.on, c.status, c.previous, got, c.want)
+		}
+	}
+}
+
+func TestReceiverMessage(t *testing.T) {
+	newFakeExecutor()
+	f := newTestFlow("first\n", "fail")
+	f.LocalRun(context.Background(), false, false)
+
+	message, err := (&Receiver{}).Message(f.Notification())
+	if err != nil {
+		t.Fatalf("Render message error: %s", err.Error())
+	}
+
+	for _, want := range []string{"Flow containerops/test/flow:latest", "is failure", "Stage stage0: success",
+		"Stage stage1: failure", "Action action1: failure"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("Message %q doesn't contain %q", message, want)
+		}
+	}
+	if strings.Contains(message, "Action action0") {
+		t.Errorf("Message %q shouldn't contain the success action", message)
+	}
+
+	message, _ = (&Receiver{Template: "{{.URI}} {{len .Stages}}"}).Message(f.Notification())
+	if message != "containerops/test/flow 2" {
+		t.Errorf("Message of template is %q", message)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		signature = r.Header.Get(WebhookSignatureHeader)
+	}))
+	defer server.Close()
+
+	f := &Flow{URI: "containerops/test/flow", Tag: "latest", Number: 2, Status: Failure, previous: Success}
+	if err := Notifiers["webhook"].Notify(f, f.Notification(), Receiver{Type: "webhook", Address: server.URL, Secret: "secret"}); err != nil {
+		t.Fatalf("Notify error: %s", err.Error())
+	}
+
+	if signature != WebhookSignature("secret", body) || !strings.HasPrefix(signature, "sha256=") {
+		t.Errorf("Signature is %q", signature)
+	}
+
+	payload := WebhookPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("Unmarshal payload error: %s", err.Error())
+	}
+	if payload.Status != Failure || payload.Previous != Success || payload.Number != 2 || payload.Message == "" {
+		t.Errorf("Payload is %+v", payload)
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("no_service"))
+	}))
+	defer server.Close()
+
+	f := &Flow{URI: "containerops/test/flow", Status: Success}
+	err := Notifiers["slack"].Notify(f, f.Notification(), Receiver{Type: "slack", Address: server.URL + "/services/token"})
+	if err == nil || !strings.Contains(err.Error(), "no_service") || strings.Contains(err.Error(), "token") {
+		t.Errorf("Notify error is %v, want the response without the webhook URL", err)
+	}
+}
+
+func TestDingTalkNotifier(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(`{"errcode":310000,"errmsg":"sign not match"}`))
+	}))
+	defer server.Close()
+
+	f := &Flow{URI: "containerops/test/flow", Status: Success}
+	err := Notifiers["dingtalk"].Notify(f, f.Notification(), Receiver{Type: "dingtalk", Address: server.URL + "/robot/send?access_token=abc", Secret: "SEC"})
+	if err == nil || !strings.Contains(err.Error(), "sign not match") {
+		t.Errorf("Notify error is %v, want the DingTalk error", err)
+	}
+	if query.Get("access_token") != "abc" || query.Get("timestamp") == "" || query.Get("sign") == "" {
+		t.Errorf("Query is %v", query)
+	}
+
+	// The sign of the DingTalk document example.
+	signed, _ := DingTalkSign("https://oapi.dingtalk.com/robot/send?access_token=abc", "SEC", time.Unix(0, 1577836800000*int64(time.Millisecond)))
+	u, _ := url.Parse(signed)
+	if u.Query().Get("timestamp") != "1577836800000" || u.Query().Get("sign") == "" {
+		t.Errorf("Signed URL is %s", signed)
+	}
+}
+
+func TestNotifyUnknownReceiver(t *testing.T) {
+	f := &Flow{URI: "containerops/test/flow", Status: Success, Receivers: []Receiver{{Type: "unknown", Address: "nobody"}}}
+	f.Notify(false, false)
+
+	if len(f.Logs) != 1 || !strings.Contains(f.Logs[0], "Unknown receiver type") {
+		t.Errorf("Logs are %v", f.Logs)
+	}
+}
+
+func TestValidateReceivers(t *testing.T) {
+	f := newTestFlow("first\n")
+	f.Receivers = []Receiver{
+		{Type: "mail", Address: "ops@containerops.sh", On: []string{Failure, ChangedTrigger}},
+		{Type: "slack", Address: "hooks.slack.com/services/T0/B0/X", On: []string{"broken"}, Template: "{{.URI"},
+	}
+
+	paths := map[string]bool{}
+	for _, e := range f.Validate() {
+		paths[e.Path] = true
+	}
+	for _, path := range []string{"receivers[1].address", "receivers[1].on[0]", "receivers[1].template"} {
+		if !paths[path] {
+			t.Errorf("Validation errors %v should contain %s", paths, path)
+		}
+	}
+	if paths["receivers[0].address"] || paths["receivers[0].on[0]"] || paths["receivers[0].on[1]"] {
+		t.Errorf("The mail receiver should be valid, but errors are %v", paths)
+	}
+}
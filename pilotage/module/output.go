@@ -0,0 +1,84 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"sync"
+
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+// RunOutputs is the outputs of jobs in one run of flow, keyed by `stage.action.job[output]`.
+// Every run has its own outputs, so the concurrent runs of a flow never overwrite each other.
+type RunOutputs struct {
+	FlowID int64
+	Number int64
+
+	lock   sync.RWMutex
+	values map[string]string
+}
+
+func NewRunOutputs(flowID, number int64) *RunOutputs {
+	return &RunOutputs{FlowID: flowID, Number: number, values: make(map[string]string)}
+}
+
+// Load reads the outputs of the run saved in database.
+func (o *RunOutputs) Load() error {
+	outputs, err := new(model.OutputV1).List(o.FlowID, o.Number)
+	if err != nil {
+		return err
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	for _, output := range outputs {
+		o.values[output.Key] = output.Value
+	}
+
+	return nil
+}
+
+func (o *RunOutputs) Get(key string) (string, bool) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	value, ok := o.values[key]
+	return value, ok
+}
+
+// Set saves the output of job into the run and database.
+func (o *RunOutputs) Set(jobID int64, key, value string) error {
+	o.lock.Lock()
+	o.values[key] = value
+	o.lock.Unlock()
+
+	return new(model.OutputV1).Put(o.FlowID, o.Number, jobID, key, value)
+}
+
+// All returns a copy of the outputs.
+func (o *RunOutputs) All() map[string]string {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	result := make(map[string]string, len(o.values))
+	for k, v := range o.values {
+		result[k] = v
+	}
+
+	return result
+}
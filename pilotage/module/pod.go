@@ -0,0 +1,185 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// HostPathVolumes allows the host_path volumes of pods mounting the paths of nodes. The daemon allows them only
+// when `host_path` of `[pilotage.daemon]` enables it, and the CLI allows them for the flow files.
+var HostPathVolumes = false
+
+// Pod is the options of the Kubernetes pod running job. The services are containers running beside the job
+// container like a database of the integration tests, they're stopped with the pod after the job container exited.
+type Pod struct {
+	NodeSelector     map[string]string `json:"node_selector,omitempty" yaml:"node_selector,omitempty"`
+	Tolerations      []Toleration      `json:"tolerations,omitempty" yaml:"tolerations,omitempty"`
+	ServiceAccount   string            `json:"service_account,omitempty" yaml:"service_account,omitempty"`
+	ImagePullSecrets []string          `json:"image_pull_secrets,omitempty" yaml:"image_pull_secrets,omitempty"`
+	Volumes          []Volume          `json:"volumes,omitempty" yaml:"volumes,omitempty"`
+	InitContainers   []Container       `json:"init_containers,omitempty" yaml:"init_containers,omitempty"`
+	Services         []Container       `json:"services,omitempty" yaml:"services,omitempty"`
+}
+
+// Toleration tolerates the taints of nodes matching the key, value and effect.
+type Toleration struct {
+	Key      string `json:"key,omitempty" yaml:"key,omitempty"`
+	Operator string `json:"operator,omitempty" yaml:"operator,omitempty"`
+	Value    string `json:"value,omitempty" yaml:"value,omitempty"`
+	Effect   string `json:"effect,omitempty" yaml:"effect,omitempty"`
+	Seconds  *int64 `json:"toleration_seconds,omitempty" yaml:"toleration_seconds,omitempty"`
+}
+
+// Volume is a volume of pod, one of its sources is set.
+type Volume struct {
+	Name                  string `json:"name" yaml:"name"`
+	EmptyDir              bool   `json:"empty_dir,omitempty" yaml:"empty_dir,omitempty"`
+	HostPath              string `json:"host_path,omitempty" yaml:"host_path,omitempty"`
+	ConfigMap             string `json:"config_map,omitempty" yaml:"config_map,omitempty"`
+	Secret                string `json:"secret,omitempty" yaml:"secret,omitempty"`
+	PersistentVolumeClaim string `json:"persistent_volume_claim,omitempty" yaml:"persistent_volume_claim,omitempty"`
+}
+
+// VolumeMount mounts a volume of pod at the path of container.
+type VolumeMount struct {
+	Name     string `json:"name" yaml:"name"`
+	Path     string `json:"path" yaml:"path"`
+	SubPath  string `json:"sub_path,omitempty" yaml:"sub_path,omitempty"`
+	ReadOnly bool   `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+}
+
+// Container is an init container or a service container of pod.
+type Container struct {
+	Name         string              `json:"name" yaml:"name"`
+	Image        string              `json:"image" yaml:"image"`
+	Command      []string            `json:"command,omitempty" yaml:"command,omitempty"`
+	Args         []string            `json:"args,omitempty" yaml:"args,omitempty"`
+	Environments []map[string]string `json:"environments,omitempty" yaml:"environments,omitempty"`
+	Resources    Resource            `json:"resources" yaml:"resources"`
+	VolumeMounts []VolumeMount       `json:"volume_mounts,omitempty" yaml:"volume_mounts,omitempty"`
+}
+
+// Requirements returns the resource requests and limits of container, the empty quantities are omitted.
+func (r *Resource) Requirements() apiv1.ResourceRequirements {
+	requirements := apiv1.ResourceRequirements{Requests: resourceList(r.CPU, r.Memory)}
+	if r.Limits != nil {
+		requirements.Limits = resourceList(r.Limits.CPU, r.Limits.Memory)
+	}
+	return requirements
+}
+
+func resourceList(cpu, memory string) apiv1.ResourceList {
+	list := apiv1.ResourceList{}
+	if cpu != "" {
+		list[apiv1.ResourceCPU] = resource.MustParse(cpu)
+	}
+	if memory != "" {
+		list[apiv1.ResourceMemory] = resource.MustParse(memory)
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	return list
+}
+
+// KubernetesVolume returns the volume of pod spec, the host path isn't its source unless HostPathVolumes allows it.
+func (v *Volume) KubernetesVolume() apiv1.Volume {
+	volume := apiv1.Volume{Name: v.Name}
+	switch {
+	case v.EmptyDir:
+		volume.EmptyDir = &apiv1.EmptyDirVolumeSource{}
+	case v.HostPath != "" && HostPathVolumes:
+		volume.HostPath = &apiv1.HostPathVolumeSource{Path: v.HostPath}
+	case v.ConfigMap != "":
+		volume.ConfigMap = &apiv1.ConfigMapVolumeSource{LocalObjectReference: apiv1.LocalObjectReference{Name: v.ConfigMap}}
+	case v.Secret != "":
+		volume.Secret = &apiv1.SecretVolumeSource{SecretName: v.Secret}
+	case v.PersistentVolumeClaim != "":
+		volume.PersistentVolumeClaim = &apiv1.PersistentVolumeClaimVolumeSource{ClaimName: v.PersistentVolumeClaim}
+	}
+	return volume
+}
+
+// sources returns the number of sources set in the volume.
+func (v *Volume) sources() int {
+	count := 0
+	if v.EmptyDir {
+		count++
+	}
+	for _, source := range []string{v.HostPath, v.ConfigMap, v.Secret, v.PersistentVolumeClaim} {
+		if source != "" {
+			count++
+		}
+	}
+	return count
+}
+
+func volumeMounts(mounts []VolumeMount) []apiv1.VolumeMount {
+	result := []apiv1.VolumeMount{}
+	for _, m := range mounts {
+		result = append(result, apiv1.VolumeMount{Name: m.Name, MountPath: m.Path, SubPath: m.SubPath, ReadOnly: m.ReadOnly})
+	}
+	return result
+}
+
+// KubernetesContainer returns the container of pod spec with its own environments.
+func (c *Container) KubernetesContainer() apiv1.Container {
+	container := apiv1.Container{Name: c.Name, Image: c.Image, Command: c.Command, Args: c.Args,
+		Resources: c.Resources.Requirements(), VolumeMounts: volumeMounts(c.VolumeMounts)}
+	for _, environment := range c.Environments {
+		for k, v := range environment {
+			container.Env = append(container.Env, apiv1.EnvVar{Name: k, Value: v})
+		}
+	}
+	return container
+}
+
+// apply sets the options of pod to the spec. The services are the containers after the job container, they start
+// after the init containers of pod and run until the pod is deleted when the job container exited. The workspace is
+// mounted in the init containers preparing it for the job, its path is the CO_WORKSPACE environment.
+func (p *Pod) apply(spec *apiv1.PodSpec, workspace *apiv1.VolumeMount) {
+	spec.NodeSelector = p.NodeSelector
+	spec.ServiceAccountName = p.ServiceAccount
+
+	for _, t := range p.Tolerations {
+		spec.Tolerations = append(spec.Tolerations, apiv1.Toleration{Key: t.Key, Operator: apiv1.TolerationOperator(t.Operator),
+			Value: t.Value, Effect: apiv1.TaintEffect(t.Effect), TolerationSeconds: t.Seconds})
+	}
+
+	for _, name := range p.ImagePullSecrets {
+		spec.ImagePullSecrets = append(spec.ImagePullSecrets, apiv1.LocalObjectReference{Name: name})
+	}
+
+	for i := range p.Volumes {
+		spec.Volumes = append(spec.Volumes, p.Volumes[i].KubernetesVolume())
+	}
+
+	for i := range p.InitContainers {
+		container := p.InitContainers[i].KubernetesContainer()
+		if workspace != nil {
+			container.VolumeMounts = append(container.VolumeMounts, *workspace)
+			container.Env = append(container.Env, apiv1.EnvVar{Name: "CO_WORKSPACE", Value: workspace.MountPath})
+		}
+		spec.InitContainers = append(spec.InitContainers, container)
+	}
+
+	for i := range p.Services {
+		spec.Containers = append(spec.Containers, p.Services[i].KubernetesContainer())
+	}
+}
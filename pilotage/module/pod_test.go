@@ -0,0 +1,224 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+const podFlow = `uri: containerops/test/pod
+tag: latest
+workspace:
+  path: /src
+stages:
+  - type: start
+    name: start
+  - type: normal
+    name: test
+    sequencing: sequence
+    actions:
+      - name: integration
+        jobs:
+          - name: go
+            endpoint: golang:latest
+            command: ["/bin/sh", "-c"]
+            args: ["go test ./..."]
+            resources:
+              cpu: "1"
+              memory: 1Gi
+              limits:
+                cpu: "2"
+                memory: 2Gi
+            volume_mounts:
+              - name: cache
+                path: /go/pkg/mod
+                read_only: true
+            pod:
+              node_selector:
+                disktype: ssd
+              tolerations:
+                - key: dedicated
+                  operator: Equal
+                  value: ci
+                  effect: NoSchedule
+                  toleration_seconds: 60
+              service_account: ci
+              image_pull_secrets: ["registry"]
+              volumes:
+                - name: cache
+                  persistent_volume_claim: go-mod-cache
+              init_containers:
+                - name: fixtures
+                  image: busybox:latest
+                  command: ["sh", "-c", "cp -r /fixtures $CO_WORKSPACE"]
+              services:
+                - name: mysql
+                  image: mysql:8.0
+                  environments:
+                    - MYSQL_ROOT_PASSWORD: test
+                  resources:
+                    memory: 512Mi
+  - type: end
+    name: end
+`
+
+func TestPodOptions(t *testing.T) {
+	f := new(Flow)
+	if err := f.ParseFlow([]byte(podFlow)); err != nil {
+		t.Fatalf("Parse flow error: %s", err.Error())
+	}
+	if errs := f.Validate(); len(errs) > 0 {
+		t.Fatalf("Validate flow error: %s", errs.Error())
+	}
+
+	f.workspace = "workspace-pod"
+	j := &f.Stages[1].Actions[0].Jobs[0]
+	j.setRun(f, 1, 0)
+	pod := j.PodTemplates("pod", f)
+	spec := pod.Spec
+
+	container := spec.Containers[0]
+	if container.Name != "pod" {
+		t.Fatalf("Containers of pod are %v, want the job container first", spec.Containers)
+	}
+	if len(container.Command) != 2 || container.Command[1] != "-c" || len(container.Args) != 1 {
+		t.Errorf("Command of job container is %v %v", container.Command, container.Args)
+	}
+	if limit := container.Resources.Limits[apiv1.ResourceMemory]; limit.String() != "2Gi" {
+		t.Errorf("Memory limit of job container is %s, want 2Gi", limit.String())
+	}
+	if request := container.Resources.Requests[apiv1.ResourceCPU]; request.String() != "1" {
+		t.Errorf("CPU request of job container is %s, want 1", request.String())
+	}
+	mounts := map[string]string{}
+	for _, mount := range container.VolumeMounts {
+		mounts[mount.Name] = mount.MountPath
+	}
+	if mounts["cache"] != "/go/pkg/mod" || mounts["workspace"] != "/src" {
+		t.Errorf("Volume mounts of job container are %v", container.VolumeMounts)
+	}
+
+	if spec.NodeSelector["disktype"] != "ssd" || spec.ServiceAccountName != "ci" {
+		t.Errorf("Node selector is %v and service account is %s", spec.NodeSelector, spec.ServiceAccountName)
+	}
+	if len(spec.Tolerations) != 1 || spec.Tolerations[0].Effect != apiv1.TaintEffectNoSchedule ||
+		spec.Tolerations[0].TolerationSeconds == nil || *spec.Tolerations[0].TolerationSeconds != 60 {
+		t.Errorf("Tolerations of pod are %v", spec.Tolerations)
+	}
+	if len(spec.ImagePullSecrets) != 1 || spec.ImagePullSecrets[0].Name != "registry" {
+		t.Errorf("Image pull secrets of pod are %v", spec.ImagePullSecrets)
+	}
+	volumes := map[string]apiv1.Volume{}
+	for _, volume := range spec.Volumes {
+		volumes[volume.Name] = volume
+	}
+	if claim := volumes["cache"].PersistentVolumeClaim; claim == nil || claim.ClaimName != "go-mod-cache" {
+		t.Errorf("Volume cache of pod is %v", volumes["cache"])
+	}
+	if claim := volumes["workspace"].PersistentVolumeClaim; claim == nil || claim.ClaimName != "workspace-pod" {
+		t.Errorf("Volume workspace of pod is %v", volumes["workspace"])
+	}
+
+	// The services are the containers after the job container.
+	if len(spec.InitContainers) != 1 || len(spec.Containers) != 2 {
+		t.Fatalf("Init containers of pod are %v and containers are %v, want fixtures and mysql", spec.InitContainers, spec.Containers)
+	}
+	mysql, fixtures := spec.Containers[1], spec.InitContainers[0]
+	if mysql.Name != "mysql" || mysql.Image != "mysql:8.0" {
+		t.Errorf("Service container is %v, want mysql", mysql)
+	}
+	if len(mysql.Env) != 1 || mysql.Env[0].Name != "MYSQL_ROOT_PASSWORD" || mysql.Resources.Limits != nil {
+		t.Errorf("Service container has envs %v and resources %v", mysql.Env, mysql.Resources)
+	}
+	if fixtures.Name != "fixtures" || len(fixtures.VolumeMounts) != 1 ||
+		fixtures.VolumeMounts[0].MountPath != "/src" {
+		t.Errorf("Init container is %v, want fixtures mounting the workspace", fixtures)
+	}
+	if len(fixtures.Env) != 1 || fixtures.Env[0].Name != "CO_WORKSPACE" || fixtures.Env[0].Value != "/src" {
+		t.Errorf("Envs of init container are %v", fixtures.Env)
+	}
+}
+
+func TestValidatePod(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("first\n", "second\n")
+	j := &f.Stages[1].Actions[0].Jobs[0]
+	j.Resources = Resource{CPU: "2", Limits: &Resource{CPU: "1", Memory: "big"}}
+	j.VolumeMounts = []VolumeMount{{Name: "cache", Path: "cache"}, {Name: "missing", Path: "/missing"}}
+	j.Pod = &Pod{
+		Tolerations: []Toleration{{Key: "dedicated", Operator: "Exists", Value: "ci"}, {Operator: "In", Effect: "Never"}},
+		Volumes: []Volume{{Name: "cache", EmptyDir: true}, {Name: "cache", HostPath: "/cache"},
+			{Name: "workspace", EmptyDir: true}, {Name: "Data", HostPath: "/data", Secret: "data"}},
+		InitContainers: []Container{{Name: "init", Image: "busybox"}},
+		Services:       []Container{{Name: "init"}},
+	}
+	f.Stages[2].Actions[0].Jobs[0].Kubectl = "deployment.yaml"
+	f.Stages[2].Actions[0].Jobs[0].Command = []string{"sh"}
+
+	paths := map[string]bool{}
+	for _, e := range f.Validate() {
+		paths[e.Path] = true
+	}
+	want := []string{
+		"stages[1].actions[0].jobs[0].resources.limits.cpu",
+		"stages[1].actions[0].jobs[0].resources.limits.memory",
+		"stages[1].actions[0].jobs[0].volume_mounts[0].path",
+		"stages[1].actions[0].jobs[0].volume_mounts[1].name",
+		"stages[1].actions[0].jobs[0].pod.tolerations[0].value",
+		"stages[1].actions[0].jobs[0].pod.tolerations[1].operator",
+		"stages[1].actions[0].jobs[0].pod.tolerations[1].effect",
+		"stages[1].actions[0].jobs[0].pod.volumes[1].name",
+		"stages[1].actions[0].jobs[0].pod.volumes[2].name",
+		"stages[1].actions[0].jobs[0].pod.volumes[3].name",
+		"stages[1].actions[0].jobs[0].pod.volumes[3]",
+		"stages[1].actions[0].jobs[0].pod.services[0].name",
+		"stages[1].actions[0].jobs[0].pod.services[0].image",
+		"stages[2].actions[0].jobs[0].pod",
+	}
+	for _, path := range want {
+		if !paths[path] {
+			t.Errorf("Missing validation error of %s in %v", path, paths)
+		}
+	}
+	if len(paths) != len(want) {
+		t.Errorf("Unexpected validation errors: %v", paths)
+	}
+}
+
+func TestHostPathVolumes(t *testing.T) {
+	newFakeExecutor()
+	HostPathVolumes = false
+	defer func() { HostPathVolumes = true }()
+
+	f := newTestFlow("first\n")
+	f.Stages[1].Actions[0].Jobs[0].Pod = &Pod{Volumes: []Volume{{Name: "root", HostPath: "/"}}}
+
+	paths := map[string]bool{}
+	for _, e := range f.Validate() {
+		paths[e.Path] = true
+	}
+	if path := "stages[1].actions[0].jobs[0].pod.volumes[0].host_path"; !paths[path] || len(paths) != 1 {
+		t.Errorf("Missing validation error of %s in %v", path, paths)
+	}
+
+	if volume := f.Stages[1].Actions[0].Jobs[0].Pod.Volumes[0].KubernetesVolume(); volume.HostPath != nil {
+		t.Errorf("The disabled host path is the source of volume %v", volume)
+	}
+}
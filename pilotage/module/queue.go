@@ -0,0 +1,374 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+// QueuedRun is a flow run of the daemon queue. It's queued until the concurrency limits allow it to run,
+// pending until the flow starts and then running until it's finished.
+type QueuedRun struct {
+	ID      int64     `json:"id"`
+	URI     string    `json:"uri"`
+	Tag     string    `json:"tag"`
+	Status  string    `json:"status"`
+	Number  int64     `json:"number,omitempty"`
+	Created time.Time `json:"created"`
+	Started time.Time `json:"started"`
+
+	done chan struct{}
+}
+
+// Wait blocks until the run is finished or removed from the queue.
+func (r QueuedRun) Wait() {
+	<-r.done
+}
+
+type queueEntry struct {
+	QueuedRun
+
+	flow       *Flow
+	containers []string
+}
+
+// RunQueue runs the flows of daemon in the order of submission, the concurrency limits the running flows of
+// daemon and the flow concurrency limits the running runs of every flow, zero means unlimited. The concurrency
+// of flow overrides the flow concurrency of queue.
+// The queue is saved in database, so the queued runs are restored and the orphaned runs are cleaned after the
+// daemon restarts.
+type RunQueue struct {
+	concurrency     int
+	flowConcurrency int
+
+	lock     sync.Mutex
+	entries  []*queueEntry
+	sequence int64
+	wake     chan struct{}
+	run      func(f *Flow)
+}
+
+var (
+	runQueueLock sync.RWMutex
+	runQueue     *RunQueue
+)
+
+func NewRunQueue(concurrency, flowConcurrency int) *RunQueue {
+	return &RunQueue{concurrency: concurrency, flowConcurrency: flowConcurrency, wake: make(chan struct{}, 1),
+		run: func(f *Flow) {
+			f.LocalRun(context.Background(), true, true)
+		}}
+}
+
+// StartRunQueue recovers the runs left by the last daemon, and runs the queued flows until the context is done.
+func StartRunQueue(ctx context.Context, concurrency, flowConcurrency int) *RunQueue {
+	q := NewRunQueue(concurrency, flowConcurrency)
+	if err := q.Recover(); err != nil {
+		printLog(model.ERROR, fmt.Sprintf("Recover the run queue error: %s", err.Error()), true, true)
+	}
+
+	runQueueLock.Lock()
+	runQueue = q
+	runQueueLock.Unlock()
+
+	go q.Run(ctx)
+	return q
+}
+
+// GetRunQueue returns the started queue, it's nil when the daemon doesn't start it.
+func GetRunQueue() *RunQueue {
+	runQueueLock.RLock()
+	defer runQueueLock.RUnlock()
+
+	return runQueue
+}
+
+// Submit runs the flow by the started queue, the flow runs at once without the queue.
+func Submit(f *Flow) (QueuedRun, error) {
+	if q := GetRunQueue(); q != nil {
+		return q.Enqueue(f)
+	}
+
+	now := time.Now()
+	r := QueuedRun{URI: f.URI, Tag: f.Tag, Status: Pending, Created: now, Started: now, done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		f.LocalRun(context.Background(), true, true)
+	}()
+	return r, nil
+}
+
+// Enqueue saves the flow in the queue, it runs when the concurrency limits allow.
+func (q *RunQueue) Enqueue(f *Flow) (QueuedRun, error) {
+	namespace, repository, name, err := f.URIs()
+	if err != nil {
+		return QueuedRun{}, err
+	}
+	content, err := f.YAML()
+	if err != nil {
+		return QueuedRun{}, err
+	}
+
+	record := new(model.QueueV1)
+	if err := record.Create(namespace, repository, name, f.Tag, string(content), Queued); err != nil {
+		return QueuedRun{}, err
+	}
+
+	q.lock.Lock()
+	entry := q.add(record.ID, f, time.Now())
+	r := entry.QueuedRun
+	q.lock.Unlock()
+
+	printLog(model.INFO, fmt.Sprintf("Flow [%s:%s] is queued: %d", f.URI, f.Tag, r.ID), true, true)
+	q.notify()
+	return r, nil
+}
+
+// add appends a queued run, the id is generated when the database is disabled.
+func (q *RunQueue) add(id int64, f *Flow, created time.Time) *queueEntry {
+	if id == 0 {
+		q.sequence++
+		id = q.sequence
+	}
+
+	entry := &queueEntry{flow: f, QueuedRun: QueuedRun{ID: id, URI: f.URI, Tag: f.Tag, Status: Queued,
+		Created: created, done: make(chan struct{})}}
+	q.entries = append(q.entries, entry)
+	return entry
+}
+
+func (q *RunQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run dispatches the queued runs when a run is queued or finished until the context is done.
+func (q *RunQueue) Run(ctx context.Context) {
+	for {
+		q.Dispatch()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		}
+	}
+}
+
+// Dispatch starts the queued runs in order until the concurrency limits are reached. A run exceeding the
+// flow concurrency doesn't block the runs of other flows behind it.
+func (q *RunQueue) Dispatch() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	running, flows := 0, map[string]int{}
+	for _, entry := range q.entries {
+		if entry.Status != Queued {
+			running++
+			flows[entry.URI+":"+entry.Tag]++
+		}
+	}
+
+	for _, entry := range q.entries {
+		if entry.Status != Queued {
+			continue
+		}
+		if q.concurrency > 0 && running >= q.concurrency {
+			return
+		}
+
+		key, limit := entry.URI+":"+entry.Tag, q.flowConcurrency
+		if entry.flow.Concurrency > 0 {
+			limit = entry.flow.Concurrency
+		}
+		if limit > 0 && flows[key] >= limit {
+			continue
+		}
+
+		running++
+		flows[key]++
+		q.start(entry)
+	}
+}
+
+// start runs the flow of entry, the number and containers of the run are saved for the recovery.
+func (q *RunQueue) start(entry *queueEntry) {
+	entry.Status, entry.Started = Pending, time.Now()
+	q.update(entry.ID, map[string]interface{}{"status": entry.Status, "started_at": entry.Started})
+
+	f := entry.flow
+	unsubscribe := f.SubscribeEvents(func(e Event) {
+		switch e.Type {
+		case FlowStarted:
+			q.lock.Lock()
+			entry.Status, entry.Number = Running, e.Number
+			q.lock.Unlock()
+			q.update(entry.ID, map[string]interface{}{"status": Running, "flow_id": f.ID, "number": e.Number})
+		case JobCreated, WorkspaceCreated:
+			q.lock.Lock()
+			entry.containers = append(entry.containers, fmt.Sprintf("%s/%s", e.Executor, e.Pod))
+			containers := strings.Join(entry.containers, "\n")
+			q.lock.Unlock()
+			q.update(entry.ID, map[string]interface{}{"containers": containers})
+		}
+	})
+
+	go func() {
+		defer q.finish(entry)
+		defer unsubscribe()
+		q.run(f)
+	}()
+}
+
+// finish removes the entry from the queue, and dispatches the queued runs.
+func (q *RunQueue) finish(entry *queueEntry) {
+	q.lock.Lock()
+	q.remove(entry)
+	q.lock.Unlock()
+
+	q.notify()
+}
+
+func (q *RunQueue) remove(entry *queueEntry) {
+	for i, e := range q.entries {
+		if e == entry {
+			q.entries = append(q.entries[:i:i], q.entries[i+1:]...)
+			break
+		}
+	}
+
+	if err := new(model.QueueV1).Delete(entry.ID); err != nil {
+		printLog(model.ERROR, fmt.Sprintf("Delete queued run %d error: %s", entry.ID, err.Error()), true, true)
+	}
+	close(entry.done)
+}
+
+func (q *RunQueue) update(id int64, columns map[string]interface{}) {
+	if err := new(model.QueueV1).Update(id, columns); err != nil {
+		printLog(model.ERROR, fmt.Sprintf("Update queued run %d error: %s", id, err.Error()), true, true)
+	}
+}
+
+// Cancel removes a queued run, the running one is cancelled by the flow number.
+func (q *RunQueue) Cancel(id int64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for _, entry := range q.entries {
+		if entry.ID != id {
+			continue
+		}
+		if entry.Status != Queued {
+			return fmt.Errorf("The queued run %d is %s", id, entry.Status)
+		}
+
+		q.remove(entry)
+		printLog(model.WARN, fmt.Sprintf("Flow [%s:%s] is removed from the queue: %d", entry.URI, entry.Tag, id), true, true)
+		return nil
+	}
+
+	return fmt.Errorf("The queued run %d is not found", id)
+}
+
+// Runs returns the queued and running runs in the order of submission, the empty URI matches all flows.
+func (q *RunQueue) Runs(uri, tag string) []QueuedRun {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	runs := []QueuedRun{}
+	for _, entry := range q.entries {
+		if uri == "" || (entry.URI == uri && entry.Tag == tag) {
+			runs = append(runs, entry.QueuedRun)
+		}
+	}
+	return runs
+}
+
+// Recover restores the queued runs saved by the last daemon. The runs running when the daemon stopped are
+// orphaned, their results are saved as orphaned and the containers and workspaces of their jobs are removed.
+func (q *RunQueue) Recover() error {
+	records, err := new(model.QueueV1).List()
+	if err != nil {
+		return err
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for _, record := range records {
+		uri := fmt.Sprintf("%s/%s/%s", record.Namespace, record.Repository, record.Name)
+		if record.Status == Running {
+			q.orphan(uri, record)
+			continue
+		}
+
+		f := &Flow{}
+		if err := f.ParseFlow([]byte(record.Content)); err != nil {
+			printLog(model.ERROR, fmt.Sprintf("Restore queued run %d of flow [%s:%s] error: %s", record.ID, uri, record.Tag, err.Error()), true, true)
+			new(model.QueueV1).Delete(record.ID)
+			continue
+		}
+		f.Model, f.Number, f.Status = DaemonStart, 1, Pending
+
+		// The pending run didn't start the flow, it's queued again.
+		q.add(record.ID, f, record.CreatedAt)
+		q.update(record.ID, map[string]interface{}{"status": Queued})
+	}
+
+	return nil
+}
+
+func (q *RunQueue) orphan(uri string, record model.QueueV1) {
+	printLog(model.WARN, fmt.Sprintf("The Number [%d] run of flow [%s:%s] is orphaned by the last daemon", record.Number, uri, record.Tag), true, true)
+
+	if record.FlowID > 0 && record.Number > 0 {
+		flowData := new(model.FlowDataV1)
+		if err := flowData.Put(record.FlowID, record.Number, Orphaned, record.StartedAt, time.Now()); err != nil {
+			printLog(model.ERROR, fmt.Sprintf("Save orphaned run of flow [%s:%s] error: %s", uri, record.Tag, err.Error()), true, true)
+		}
+	}
+
+	// The namespace of flow decides the Kubernetes namespace of the containers.
+	f := &Flow{}
+	if err := f.ParseFlow([]byte(record.Content)); err != nil {
+		printLog(model.WARN, fmt.Sprintf("Parse orphaned run of flow [%s:%s] error: %s", uri, record.Tag, err.Error()), true, true)
+	}
+
+	for _, container := range strings.Split(record.Containers, "\n") {
+		parts := strings.SplitN(container, "/", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if cleaner, ok := JobExecutors[parts[0]].(JobCleaner); ok {
+			if err := cleaner.Clean(f, parts[1]); err != nil {
+				printLog(model.WARN, fmt.Sprintf("Clean %s container %s error: %s", parts[0], parts[1], err.Error()), true, true)
+			}
+		}
+	}
+
+	if err := new(model.QueueV1).Delete(record.ID); err != nil {
+		printLog(model.ERROR, fmt.Sprintf("Delete queued run %d error: %s", record.ID, err.Error()), true, true)
+	}
+}
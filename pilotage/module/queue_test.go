@@ -0,0 +1,299 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+// newBlockingQueue returns a queue whose runs block until they're released.
+func newBlockingQueue(concurrency, flowConcurrency int) (*RunQueue, chan *Flow, map[*Flow]chan struct{}) {
+	q := NewRunQueue(concurrency, flowConcurrency)
+	started, release := make(chan *Flow, 10), map[*Flow]chan struct{}{}
+
+	var lock sync.Mutex
+	q.run = func(f *Flow) {
+		lock.Lock()
+		ch := release[f]
+		lock.Unlock()
+
+		started <- f
+		<-ch
+	}
+	return q, started, release
+}
+
+func queueStatuses(q *RunQueue) []string {
+	statuses := []string{}
+	for _, r := range q.Runs("", "") {
+		statuses = append(statuses, r.Status)
+	}
+	return statuses
+}
+
+func waitStarted(t *testing.T, started chan *Flow, count int) {
+	for i := 0; i < count; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatalf("The Number [%d] run doesn't start", i)
+		}
+	}
+}
+
+func TestRunQueueConcurrency(t *testing.T) {
+	newFakeExecutor()
+	q, started, release := newBlockingQueue(2, 1)
+
+	flows := []*Flow{
+		{URI: "containerops/test/a", Tag: "latest"},
+		{URI: "containerops/test/a", Tag: "latest"},
+		{URI: "containerops/test/b", Tag: "latest"},
+		{URI: "containerops/test/c", Tag: "latest"},
+	}
+	runs := []QueuedRun{}
+	for _, f := range flows {
+		release[f] = make(chan struct{})
+		r, err := q.Enqueue(f)
+		if err != nil {
+			t.Fatalf("Enqueue flow error: %s", err.Error())
+		}
+		runs = append(runs, r)
+	}
+
+	// The second run of a waits for the flow concurrency, the run of b is dispatched before it.
+	q.Dispatch()
+	waitStarted(t, started, 2)
+	if statuses := queueStatuses(q); statuses[0] != Pending || statuses[1] != Queued || statuses[2] != Pending || statuses[3] != Queued {
+		t.Fatalf("The statuses of queue are %v", statuses)
+	}
+
+	close(release[flows[0]])
+	runs[0].Wait()
+	q.Dispatch()
+	waitStarted(t, started, 1)
+	if statuses := queueStatuses(q); len(statuses) != 3 || statuses[0] != Pending || statuses[1] != Pending || statuses[2] != Queued {
+		t.Fatalf("The statuses of queue after a run finished are %v", statuses)
+	}
+
+	for _, f := range flows[1:] {
+		close(release[f])
+	}
+	for _, r := range runs[1:3] {
+		r.Wait()
+	}
+	q.Dispatch()
+	waitStarted(t, started, 1)
+	runs[3].Wait()
+
+	if runs := q.Runs("", ""); len(runs) != 0 {
+		t.Errorf("The finished runs are left in the queue: %+v", runs)
+	}
+}
+
+func TestRunQueueFlowConcurrency(t *testing.T) {
+	newFakeExecutor()
+	q, started, release := newBlockingQueue(0, 1)
+
+	runs := []QueuedRun{}
+	for i := 0; i < 3; i++ {
+		f := &Flow{URI: "containerops/test/a", Tag: "latest", Concurrency: 2}
+		release[f] = make(chan struct{})
+		r, _ := q.Enqueue(f)
+		runs = append(runs, r)
+	}
+	defer func() {
+		for _, ch := range release {
+			close(ch)
+		}
+		runs[0].Wait()
+		runs[1].Wait()
+	}()
+
+	q.Dispatch()
+	waitStarted(t, started, 2)
+	if statuses := queueStatuses(q); statuses[0] != Pending || statuses[1] != Pending || statuses[2] != Queued {
+		t.Errorf("The concurrency of flow should override the queue, the statuses are %v", statuses)
+	}
+	if runs := q.Runs("containerops/test/b", "latest"); len(runs) != 0 {
+		t.Errorf("The runs of other flow are %+v", runs)
+	}
+}
+
+func TestRunQueueEvents(t *testing.T) {
+	newFakeExecutor()
+	q := NewRunQueue(0, 0)
+	release := make(chan struct{})
+
+	q.run = func(f *Flow) {
+		f.Number = 5
+		f.emit(Event{Type: FlowStarted, Status: Running})
+		f.emit(Event{Type: JobCreated, Path: "build.compile.go", Pod: "go-abc", Executor: DockerExecutor})
+		<-release
+	}
+
+	r, _ := q.Enqueue(&Flow{URI: "containerops/test/a", Tag: "latest"})
+	q.Dispatch()
+	defer func() {
+		close(release)
+		r.Wait()
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		runs := q.Runs("containerops/test/a", "latest")
+		if len(runs) == 1 && runs[0].Status == Running {
+			if runs[0].ID != r.ID || runs[0].Number != 5 || runs[0].Started.IsZero() {
+				t.Errorf("The running run is %+v", runs[0])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("The run doesn't change to running: %+v", runs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	q.lock.Lock()
+	containers := q.entries[0].containers
+	q.lock.Unlock()
+	if len(containers) != 1 || containers[0] != "docker/go-abc" {
+		t.Errorf("The containers of run are %v", containers)
+	}
+}
+
+func TestRunQueueLocalRun(t *testing.T) {
+	executor := newFakeExecutor()
+	q := NewRunQueue(1, 1)
+
+	created := make(chan Event, 10)
+	id := Events.Subscribe(func(e Event) {
+		if e.Type == JobCreated {
+			created <- e
+		}
+	})
+	defer Events.Unsubscribe(id)
+
+	r, err := q.Enqueue(newTestFlow("first\n"))
+	if err != nil {
+		t.Fatalf("Enqueue flow error: %s", err.Error())
+	}
+	q.Dispatch()
+
+	done := make(chan struct{})
+	go func() {
+		r.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The queued flow doesn't finish")
+	}
+
+	executor.lock.Lock()
+	runs := executor.runs["job0"]
+	executor.lock.Unlock()
+	if runs != 1 {
+		t.Errorf("The job of queued flow runs %d times", runs)
+	}
+
+	select {
+	case e := <-created:
+		if e.Executor != "fake" || e.Path != "stage0.action0.job0" || e.Pod == "" {
+			t.Errorf("The job.created event is %+v", e)
+		}
+	default:
+		t.Error("No job.created event of the queued flow")
+	}
+}
+
+func TestRunQueueCancel(t *testing.T) {
+	newFakeExecutor()
+	q, _, _ := newBlockingQueue(0, 0)
+
+	r, _ := q.Enqueue(&Flow{URI: "containerops/test/a", Tag: "latest"})
+	if err := q.Cancel(r.ID); err != nil {
+		t.Fatalf("Cancel queued run error: %s", err.Error())
+	}
+	r.Wait()
+
+	if err := q.Cancel(r.ID); err == nil {
+		t.Error("Cancel a removed run should be error")
+	}
+	if runs := q.Runs("", ""); len(runs) != 0 {
+		t.Errorf("The cancelled run is left in the queue: %+v", runs)
+	}
+}
+
+type cleanerExecutor struct {
+	fakeJobExecutor
+	cleaned []string
+}
+
+func (c *cleanerExecutor) Clean(f *Flow, containerName string) error {
+	c.cleaned = append(c.cleaned, fmt.Sprintf("%s/%s", f.KubernetesNamespace(), containerName))
+	return nil
+}
+
+func TestRunQueueOrphan(t *testing.T) {
+	newFakeExecutor()
+	cleaner := &cleanerExecutor{}
+	JobExecutors["cleaner"] = cleaner
+	defer delete(JobExecutors, "cleaner")
+
+	NewRunQueue(0, 0).orphan("containerops/test/a", model.QueueV1{Tag: "latest", Status: Running, FlowID: 1, Number: 2,
+		Content: "uri: containerops/test/a\nnamespace: ci\n", Containers: "cleaner/build-1\nfake/build-2\ncleaner/build-3"})
+
+	if len(cleaner.cleaned) != 2 || cleaner.cleaned[0] != "ci/build-1" || cleaner.cleaned[1] != "ci/build-3" {
+		t.Errorf("The cleaned containers are %v", cleaner.cleaned)
+	}
+}
+
+func TestSubmitWithoutQueue(t *testing.T) {
+	executor := newFakeExecutor()
+
+	r, err := Submit(newTestFlow("first\n"))
+	if err != nil {
+		t.Fatalf("Submit flow error: %s", err.Error())
+	}
+	if r.Status != Pending {
+		t.Errorf("The status of submitted run is %s", r.Status)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		r.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The submitted flow doesn't finish")
+	}
+
+	executor.lock.Lock()
+	defer executor.lock.Unlock()
+	if executor.runs["job0"] != 1 {
+		t.Errorf("The job of submitted flow runs %d times", executor.runs["job0"])
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Huawei/containerops/common/utils"
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+// Retry is the retry policy of job. The job is retried when it fails with one of the exit codes or reasons,
+// or any failure if neither of them is set.
+type Retry struct {
+	Attempts   int      `json:"attempts" yaml:"attempts"`
+	Backoff    int64    `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	MaxBackoff int64    `json:"max_backoff,omitempty" yaml:"max_backoff,omitempty"`
+	ExitCodes  []int    `json:"exit_codes,omitempty" yaml:"exit_codes,omitempty"`
+	Reasons    []string `json:"reasons,omitempty" yaml:"reasons,omitempty"`
+}
+
+// Attempt is one execution of the job container.
+type Attempt struct {
+	Number   int       `json:"number" yaml:"number"`
+	Status   string    `json:"status" yaml:"status"`
+	ExitCode int       `json:"exit_code" yaml:"exit_code"`
+	Reason   string    `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Start    time.Time `json:"start" yaml:"start"`
+	End      time.Time `json:"end" yaml:"end"`
+	Logs     []string  `json:"logs,omitempty" yaml:"logs,omitempty"`
+}
+
+// ExitError is returned by the executor when the job container exits abnormally,
+// the reason is the one reported by the container runtime like ErrImagePull.
+type ExitError struct {
+	ExitCode int
+	Reason   string
+	Message  string
+}
+
+func (e *ExitError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("Container exited with code %d, reason: %s", e.ExitCode, e.Reason)
+}
+
+// ShouldRetry returns whether the job should run again after the number attempt failed with err.
+func (r *Retry) ShouldRetry(number int, err error) bool {
+	if r == nil || number >= r.Attempts {
+		return false
+	}
+	if len(r.ExitCodes) == 0 && len(r.Reasons) == 0 {
+		return true
+	}
+
+	exitErr, ok := err.(*ExitError)
+	if !ok {
+		return false
+	}
+	for _, code := range r.ExitCodes {
+		if code == exitErr.ExitCode {
+			return true
+		}
+	}
+	for _, reason := range r.Reasons {
+		if strings.EqualFold(reason, exitErr.Reason) {
+			return true
+		}
+	}
+	return false
+}
+
+// Delay returns the backoff before the next attempt, it's doubled after every failed attempt
+// and limited by the max backoff.
+func (r *Retry) Delay(number int) time.Duration {
+	if r == nil || r.Backoff <= 0 {
+		return 0
+	}
+
+	delay, max := time.Duration(r.Backoff)*time.Second, time.Duration(r.MaxBackoff)*time.Second
+	for i := 1; i < number && (max <= 0 || delay < max); i++ {
+		delay *= 2
+	}
+	if max > 0 && delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// Attempt runs the job container until it succeeds or the retry policy gives up,
+// every attempt uses a new container name with the prefix. The exit code and reason of job are the ones of
+// the last attempt.
+func (j *Job) Attempt(ctx context.Context, prefix string, verbose, timestamp bool, execute func(ctx context.Context, containerName string) error) (string, error) {
+	j.Attempts = nil
+
+	for number := 1; ; number++ {
+		j.Attempts, j.result = append(j.Attempts, Attempt{Number: number, Status: Running, Start: time.Now()}), ""
+		err := execute(ctx, fmt.Sprintf("%s-%s", prefix, utils.RandomString(10)))
+
+		attempt := &j.Attempts[len(j.Attempts)-1]
+		attempt.End = time.Now()
+		if exitErr, ok := err.(*ExitError); ok {
+			attempt.ExitCode, attempt.Reason = exitErr.ExitCode, exitErr.Reason
+		}
+		j.ExitCode, j.Reason = attempt.ExitCode, attempt.Reason
+
+		if err == nil {
+			attempt.Status = Success
+			return Success, nil
+		}
+
+		if status := ContextStatus(ctx); status != "" {
+			attempt.Status = status
+			j.LogLevel(model.WARN, fmt.Sprintf("Job [%s] is %s: %s", j.Name, status, err.Error()), false, timestamp)
+			return status, nil
+		}
+
+		attempt.Status = Failure
+		if !j.Retry.ShouldRetry(number, err) {
+			return Failure, err
+		}
+
+		delay := j.Retry.Delay(number)
+		j.LogLevel(model.WARN, fmt.Sprintf("Job [%s] attempt %d failed: %s, retry in %s", j.Name, number, err.Error(), delay), verbose, timestamp)
+		if err := Sleep(ctx, delay); err != nil {
+			status := ContextStatus(ctx)
+			j.LogLevel(model.WARN, fmt.Sprintf("Job [%s] is %s while waiting to retry", j.Name, status), false, timestamp)
+			return status, nil
+		}
+	}
+}
+
+// SaveAttempts records the attempts of job data.
+func (j *Job) SaveAttempts(jobDataID int64, verbose, timestamp bool) {
+	for _, a := range j.Attempts {
+		attempt := new(model.AttemptV1)
+		if err := attempt.Put(jobDataID, j.ID, int64(a.Number), int64(a.ExitCode), a.Status, a.Reason, strings.Join(a.Logs, ""), a.Start, a.End); err != nil {
+			j.LogLevel(model.ERROR, fmt.Sprintf("Save Job [%s] attempt %d error: %s", j.Name, a.Number, err.Error()), false, timestamp)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("flaky")
+	job := &f.Stages[1].Actions[0].Jobs[0]
+	job.Retry = &Retry{Attempts: 3}
+
+	if err := f.LocalRun(context.Background(), false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+
+	if f.Status != Success {
+		t.Errorf("Flow status is %s, want %s", f.Status, Success)
+	}
+	if len(job.Attempts) != 3 {
+		t.Fatalf("Job has %d attempts, want 3", len(job.Attempts))
+	}
+	for i, status := range []string{Failure, Failure, Success} {
+		if job.Attempts[i].Status != status {
+			t.Errorf("Attempt %d status is %s, want %s", i+1, job.Attempts[i].Status, status)
+		}
+	}
+	if job.Attempts[0].ExitCode != 1 {
+		t.Errorf("Attempt 1 exit code is %d, want 1", job.Attempts[0].ExitCode)
+	}
+	if len(job.Attempts[2].Logs) != 1 || job.Attempts[2].Logs[0] != "flaky" {
+		t.Errorf("Attempt 3 logs are %v, want [flaky]", job.Attempts[2].Logs)
+	}
+}
+
+func TestRetryExitCodes(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("flaky")
+	job := &f.Stages[1].Actions[0].Jobs[0]
+	job.Retry = &Retry{Attempts: 3, ExitCodes: []int{137}, Reasons: []string{"ErrImagePull"}}
+
+	if err := f.LocalRun(context.Background(), false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+
+	if f.Status != Failure {
+		t.Errorf("Flow status is %s, want %s", f.Status, Failure)
+	}
+	if len(job.Attempts) != 1 {
+		t.Errorf("Job has %d attempts, want 1", len(job.Attempts))
+	}
+	if job.ExitCode != 1 || job.Reason != "Error" {
+		t.Errorf("Job exits with %d: %s, want 1: Error", job.ExitCode, job.Reason)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	r := &Retry{Attempts: 5, Backoff: 2, MaxBackoff: 10}
+
+	for number, want := range map[int]time.Duration{1: 2 * time.Second, 2: 4 * time.Second, 3: 8 * time.Second, 4: 10 * time.Second} {
+		if delay := r.Delay(number); delay != want {
+			t.Errorf("Delay of attempt %d is %s, want %s", number, delay, want)
+		}
+	}
+
+	if delay := (&Retry{Attempts: 2}).Delay(1); delay != 0 {
+		t.Errorf("Delay without backoff is %s, want 0", delay)
+	}
+}
+
+func TestRetryCancel(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("fail")
+	job := &f.Stages[1].Actions[0].Jobs[0]
+	job.Retry = &Retry{Attempts: 3, Backoff: 60}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if err := f.LocalRun(ctx, false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+
+	if job.Status != Timeout {
+		t.Errorf("Job status is %s, want %s", job.Status, Timeout)
+	}
+	if len(job.Attempts) != 1 {
+		t.Errorf("Job has %d attempts, want 1", len(job.Attempts))
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	runtimesLock sync.RWMutex
+	runtimes     = make(map[string]*Flow)
+)
+
+func runtimeKey(uri, tag string, number int64) string {
+	return fmt.Sprintf("%s:%s/%d", uri, tag, number)
+}
+
+// AddRuntime registers a running flow, then it could be found by the URI, tag and number. The number is
+// reserved in the database, without the database a number in use by another run is skipped.
+func AddRuntime(f *Flow) {
+	runtimesLock.Lock()
+	defer runtimesLock.Unlock()
+
+	for runtimes[runtimeKey(f.URI, f.Tag, f.Number)] != nil {
+		f.Number++
+	}
+	runtimes[runtimeKey(f.URI, f.Tag, f.Number)] = f
+}
+
+// RemoveRuntime unregisters a flow when it's finished.
+func RemoveRuntime(f *Flow) {
+	runtimesLock.Lock()
+	defer runtimesLock.Unlock()
+
+	delete(runtimes, runtimeKey(f.URI, f.Tag, f.Number))
+}
+
+// GetRuntimes returns the running flows of the URI and tag.
+func GetRuntimes(uri, tag string) []*Flow {
+	runtimesLock.RLock()
+	defer runtimesLock.RUnlock()
+
+	flows := []*Flow{}
+	for _, f := range runtimes {
+		if f.URI == uri && f.Tag == tag {
+			flows = append(flows, f)
+		}
+	}
+	return flows
+}
+
+// GetRuntime returns the running flow, it's nil when the flow is not running.
+func GetRuntime(uri, tag string, number int64) *Flow {
+	runtimesLock.RLock()
+	defer runtimesLock.RUnlock()
+
+	return runtimes[runtimeKey(uri, tag, number)]
+}
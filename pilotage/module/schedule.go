@@ -0,0 +1,283 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+// The interval of reloading the schedules from the flow files.
+const scheduleReloadInterval = time.Minute
+
+// Schedule is a schedule trigger of the flow file, the next is the time firing the flow.
+type Schedule struct {
+	URI      string    `json:"uri"`
+	Tag      string    `json:"tag"`
+	File     string    `json:"file"`
+	Schedule string    `json:"schedule"`
+	Missed   string    `json:"missed"`
+	Overlap  string    `json:"overlap"`
+	Last     time.Time `json:"last"`
+	Next     time.Time `json:"next"`
+
+	cron *Cron
+	done chan struct{}
+}
+
+// Scheduler runs the flows of schedule triggers in the files `dir/namespace/repository/*.yml`, the files are
+// reloaded every minute.
+type Scheduler struct {
+	dir       string
+	lock      sync.Mutex
+	schedules map[string]*Schedule
+	run       func(f *Flow)
+}
+
+var (
+	schedulerLock sync.RWMutex
+	scheduler     *Scheduler
+)
+
+func NewScheduler(dir string) *Scheduler {
+	return &Scheduler{dir: dir, schedules: make(map[string]*Schedule), run: func(f *Flow) {
+		run, err := Submit(f)
+		if err != nil {
+			printLog(model.ERROR, fmt.Sprintf("Queue the flow [%s:%s] error: %s", f.URI, f.Tag, err.Error()), true, true)
+			return
+		}
+		run.Wait()
+	}}
+}
+
+// StartScheduler runs the scheduler of flow files in the directory until the context is done.
+func StartScheduler(ctx context.Context, dir string) *Scheduler {
+	s := NewScheduler(dir)
+
+	schedulerLock.Lock()
+	scheduler = s
+	schedulerLock.Unlock()
+
+	go s.Run(ctx)
+	return s
+}
+
+// GetSchedules returns the schedules of the started scheduler.
+func GetSchedules() []Schedule {
+	schedulerLock.RLock()
+	defer schedulerLock.RUnlock()
+
+	if scheduler == nil {
+		return []Schedule{}
+	}
+	return scheduler.Schedules()
+}
+
+// Run fires the schedules on time and reloads the flow files until the context is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	loaded := time.Time{}
+	for {
+		now := time.Now()
+		if now.Sub(loaded) >= scheduleReloadInterval {
+			if err := s.Load(now); err != nil {
+				printLog(model.ERROR, fmt.Sprintf("Load schedules error: %s", err.Error()), true, true)
+			}
+			loaded = now
+		}
+
+		wait := scheduleReloadInterval - time.Since(loaded)
+		if next := s.Tick(now); !next.IsZero() && next.Sub(time.Now()) < wait {
+			wait = next.Sub(time.Now())
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Load reads the schedule triggers of the flow files. The next time of a new schedule is restored from the
+// database, and the schedules of removed files or triggers are removed.
+func (s *Scheduler) Load(now time.Time) error {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*", "*", "*.yml"))
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	loaded := map[string]bool{}
+	for _, file := range files {
+		f := &Flow{}
+		if data, err := ioutil.ReadFile(file); err != nil || f.ParseFlow(data) != nil {
+			continue
+		}
+
+		for _, trigger := range f.Triggers {
+			if trigger.Type != ScheduleTrigger {
+				continue
+			}
+			c, err := ParseCron(trigger.Schedule)
+			if err != nil {
+				continue
+			}
+
+			missed, overlap := trigger.Missed, trigger.Overlap
+			if missed == "" {
+				missed = MissedSkip
+			}
+			if overlap == "" {
+				overlap = OverlapAllow
+			}
+
+			key := fmt.Sprintf("%s:%s %s", f.URI, f.Tag, trigger.Schedule)
+			loaded[key] = true
+
+			if schedule, ok := s.schedules[key]; ok {
+				schedule.File, schedule.Missed, schedule.Overlap = file, missed, overlap
+				continue
+			}
+
+			schedule := &Schedule{URI: f.URI, Tag: f.Tag, File: file, Schedule: trigger.Schedule,
+				Missed: missed, Overlap: overlap, cron: c, Next: c.Next(now)}
+			s.restore(schedule, now)
+			s.schedules[key] = schedule
+		}
+	}
+
+	for key := range s.schedules {
+		if !loaded[key] {
+			delete(s.schedules, key)
+		}
+	}
+
+	return nil
+}
+
+// restore sets the last and next time of schedule saved before the daemon restarts.
+func (s *Scheduler) restore(schedule *Schedule, now time.Time) {
+	namespace, repository, name, err := (&Flow{URI: schedule.URI}).URIs()
+	if err != nil {
+		return
+	}
+
+	record := new(model.ScheduleV1)
+	if err := record.Get(namespace, repository, name, schedule.Tag, schedule.Schedule); err != nil || record.Next.IsZero() {
+		return
+	}
+
+	schedule.Last = record.Last
+	schedule.Next = nextFire(schedule.cron, schedule.Missed, record.Next, now)
+	if record.Next.Before(now) {
+		printLog(model.WARN, fmt.Sprintf("Schedule [%s] of flow [%s:%s] missed the run at %s, the missed policy is %s",
+			schedule.Schedule, schedule.URI, schedule.Tag, record.Next.Format(time.RFC3339), schedule.Missed), true, true)
+	}
+}
+
+// nextFire returns the next time of a schedule saved with the next time, the missed run fires at once
+// with the run_once policy.
+func nextFire(c *Cron, missed string, next, now time.Time) time.Time {
+	if !next.Before(now) {
+		return next
+	}
+	if missed == MissedRunOnce {
+		return now
+	}
+	return c.Next(now)
+}
+
+// Tick fires the schedules whose next time is due, and returns the earliest next time of the schedules.
+func (s *Scheduler) Tick(now time.Time) time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	earliest := time.Time{}
+	for _, schedule := range s.schedules {
+		if !schedule.Next.IsZero() && !schedule.Next.After(now) {
+			s.fire(schedule, now)
+		}
+		if !schedule.Next.IsZero() && (earliest.IsZero() || schedule.Next.Before(earliest)) {
+			earliest = schedule.Next
+		}
+	}
+
+	return earliest
+}
+
+// fire runs the flow of schedule by the overlap policy when the last run is still running.
+func (s *Scheduler) fire(schedule *Schedule, now time.Time) {
+	schedule.Last, schedule.Next = now, schedule.cron.Next(now)
+	if namespace, repository, name, err := (&Flow{URI: schedule.URI}).URIs(); err == nil {
+		record := new(model.ScheduleV1)
+		if err := record.Put(namespace, repository, name, schedule.Tag, schedule.Schedule, schedule.Last, schedule.Next); err != nil {
+			printLog(model.ERROR, fmt.Sprintf("Save schedule [%s] of flow [%s:%s] error: %s", schedule.Schedule, schedule.URI, schedule.Tag, err.Error()), true, true)
+		}
+	}
+
+	runtimes := GetRuntimes(schedule.URI, schedule.Tag)
+	if len(runtimes) > 0 || (schedule.done != nil && !IsClosed(schedule.done)) {
+		switch schedule.Overlap {
+		case OverlapForbid:
+			printLog(model.WARN, fmt.Sprintf("Flow [%s:%s] is running, skip the schedule [%s]", schedule.URI, schedule.Tag, schedule.Schedule), true, true)
+			return
+		case OverlapReplace:
+			for _, f := range runtimes {
+				f.Cancel()
+			}
+		}
+	}
+
+	f := &Flow{}
+	if err := f.ParseFlowFromFile(schedule.File, DaemonStart, false, true); err != nil {
+		return
+	}
+	f.Environments = append(f.Environments, map[string]string{
+		"CO_SCHEDULE": schedule.Schedule, "CO_SCHEDULE_TIME": now.Format(time.RFC3339)})
+
+	done := make(chan struct{})
+	schedule.done = done
+	go func() {
+		defer close(done)
+		s.run(f)
+	}()
+}
+
+// Schedules returns the schedules sorted by the next time.
+func (s *Scheduler) Schedules() []Schedule {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	schedules := []Schedule{}
+	for _, schedule := range s.schedules {
+		schedules = append(schedules, *schedule)
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].Next.Before(schedules[j].Next) })
+
+	return schedules
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const scheduledFlow = `uri: containerops/test/nightly
+tag: latest
+triggers:
+  - type: schedule
+    schedule: "0 2 * * *"
+    overlap: %s
+stages:
+  - type: start
+    name: start
+`
+
+func newTestScheduler(t *testing.T, overlap string) (*Scheduler, chan *Flow, func()) {
+	dir, err := ioutil.TempDir("", "schedule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(dir, "containerops", "test"), 0755)
+	content := strings.Replace(scheduledFlow, "%s", overlap, 1)
+	if err := ioutil.WriteFile(filepath.Join(dir, "containerops", "test", "latest.yml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, release := make(chan *Flow, 10), make(chan struct{})
+	s := NewScheduler(dir)
+	s.run = func(f *Flow) {
+		runs <- f
+		<-release
+	}
+
+	var once sync.Once
+	return s, runs, func() {
+		once.Do(func() { close(release) })
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSchedulerTick(t *testing.T) {
+	newFakeExecutor()
+	s, runs, cleanup := newTestScheduler(t, OverlapForbid)
+	defer cleanup()
+
+	now := time.Date(2017, 6, 12, 1, 0, 0, 0, time.Local)
+	if err := s.Load(now); err != nil {
+		t.Fatalf("Load schedules error: %s", err.Error())
+	}
+
+	schedules := s.Schedules()
+	if len(schedules) != 1 || schedules[0].Next != time.Date(2017, 6, 12, 2, 0, 0, 0, time.Local) ||
+		schedules[0].Missed != MissedSkip || schedules[0].Overlap != OverlapForbid {
+		t.Fatalf("Schedules are %+v", schedules)
+	}
+
+	if next := s.Tick(now); next != schedules[0].Next {
+		t.Errorf("The next of tick is %s", next)
+	}
+	select {
+	case f := <-runs:
+		t.Fatalf("Flow %s runs before the schedule", f.URI)
+	default:
+	}
+
+	fire := time.Date(2017, 6, 12, 2, 0, 1, 0, time.Local)
+	if next := s.Tick(fire); next != time.Date(2017, 6, 13, 2, 0, 0, 0, time.Local) {
+		t.Errorf("The next of tick is %s", next)
+	}
+
+	select {
+	case f := <-runs:
+		envs := map[string]string{}
+		for _, env := range f.Environments {
+			for k, v := range env {
+				envs[k] = v
+			}
+		}
+		if f.URI != "containerops/test/nightly" || envs["CO_SCHEDULE"] != "0 2 * * *" || envs["CO_SCHEDULE_TIME"] == "" {
+			t.Errorf("The scheduled flow is %s with environments %v", f.URI, envs)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The scheduled flow doesn't run")
+	}
+
+	// The last run is running, the forbid policy skips the schedule.
+	s.Tick(time.Date(2017, 6, 13, 2, 0, 0, 0, time.Local))
+	select {
+	case <-runs:
+		t.Error("The overlapped schedule runs with the forbid policy")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if schedules := s.Schedules(); schedules[0].Next != time.Date(2017, 6, 14, 2, 0, 0, 0, time.Local) {
+		t.Errorf("The next of skipped schedule is %s", schedules[0].Next)
+	}
+}
+
+func TestSchedulerOverlapAllow(t *testing.T) {
+	newFakeExecutor()
+	s, runs, cleanup := newTestScheduler(t, OverlapAllow)
+	defer cleanup()
+
+	s.Load(time.Date(2017, 6, 12, 1, 0, 0, 0, time.Local))
+	s.Tick(time.Date(2017, 6, 12, 2, 0, 0, 0, time.Local))
+	s.Tick(time.Date(2017, 6, 13, 2, 0, 0, 0, time.Local))
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-runs:
+		case <-time.After(time.Second):
+			t.Fatalf("The Number [%d] run doesn't start with the allow policy", i)
+		}
+	}
+}
+
+func TestSchedulerReload(t *testing.T) {
+	newFakeExecutor()
+	s, _, cleanup := newTestScheduler(t, OverlapAllow)
+	defer cleanup()
+
+	now := time.Date(2017, 6, 12, 1, 0, 0, 0, time.Local)
+	s.Load(now)
+	os.RemoveAll(s.dir)
+	s.Load(now)
+
+	if schedules := s.Schedules(); len(schedules) != 0 {
+		t.Errorf("The schedules of removed file are %+v", schedules)
+	}
+}
+
+func TestNextFire(t *testing.T) {
+	c, _ := ParseCron("0 2 * * *")
+	now := time.Date(2017, 6, 12, 10, 0, 0, 0, time.UTC)
+	missed := time.Date(2017, 6, 12, 2, 0, 0, 0, time.UTC)
+	future := time.Date(2017, 6, 13, 2, 0, 0, 0, time.UTC)
+
+	if next := nextFire(c, MissedSkip, missed, now); next != future {
+		t.Errorf("The next of skipped missed run is %s", next)
+	}
+	if next := nextFire(c, MissedRunOnce, missed, now); next != now {
+		t.Errorf("The next of run_once missed run is %s", next)
+	}
+	if next := nextFire(c, MissedRunOnce, future, now); next != future {
+		t.Errorf("The next of not missed run is %s", next)
+	}
+}
+
+func TestValidateScheduleTrigger(t *testing.T) {
+	f := newTestFlow("first\n")
+	f.Triggers = []Trigger{
+		{Type: ScheduleTrigger, Schedule: "@daily", Missed: MissedRunOnce, Overlap: OverlapReplace},
+		{Type: ScheduleTrigger, Schedule: "0 25 * * *", Missed: "later", Overlap: "queue"},
+	}
+
+	paths := map[string]bool{}
+	for _, e := range f.Validate() {
+		paths[e.Path] = true
+	}
+	for _, path := range []string{"triggers[1].schedule", "triggers[1].missed", "triggers[1].overlap"} {
+		if !paths[path] {
+			t.Errorf("Validation errors %v should contain %s", paths, path)
+		}
+	}
+	for path := range paths {
+		if strings.HasPrefix(path, "triggers[0]") {
+			t.Errorf("The schedule trigger should be valid, but errors are %v", paths)
+		}
+	}
+}
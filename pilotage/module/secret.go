@@ -0,0 +1,197 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/Huawei/containerops/common/utils"
+	"github.com/Huawei/containerops/pilotage/config"
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+// SecretMask replaces the secret values in the job logs and notifications.
+const SecretMask = "******"
+
+var ErrNoSecretKey = errors.New("No secret key is configured in [pilotage.secret]")
+
+// ValidSecretName checks the name of secret, it's letters, digits, `_`, `.` and `-`.
+func ValidSecretName(name string) bool {
+	return ValidName(name)
+}
+
+// SecretStore keeps the secrets of namespaces, the flows reference the secrets of their namespace by name.
+type SecretStore interface {
+	Get(namespace, name string) (string, error)
+	Put(namespace, name, value string) error
+	Delete(namespace, name string) error
+	List(namespace string) ([]string, error)
+}
+
+// Secrets is the store of the secrets referenced by flows.
+var Secrets SecretStore = &DatabaseSecretStore{}
+
+// DatabaseSecretStore saves the secrets in database encrypted by the fernet keys of `[pilotage.secret]`.
+type DatabaseSecretStore struct {
+}
+
+func (d *DatabaseSecretStore) Get(namespace, name string) (string, error) {
+	if model.DisableDB {
+		return "", fmt.Errorf("Secret [%s/%s] is not found, the secrets need the database", namespace, name)
+	}
+	if len(config.Pilotage.Secret.Keys) == 0 {
+		return "", ErrNoSecretKey
+	}
+
+	secret := new(model.SecretV1)
+	if err := secret.Get(namespace, name); err == gorm.ErrRecordNotFound {
+		return "", fmt.Errorf("Secret [%s/%s] is not found", namespace, name)
+	} else if err != nil {
+		return "", err
+	}
+
+	value, err := utils.Decrypt([]byte(secret.Value), config.Pilotage.Secret.Keys...)
+	if err != nil {
+		return "", fmt.Errorf("Decrypt secret [%s/%s] error: %s", namespace, name, err.Error())
+	}
+	return string(value), nil
+}
+
+func (d *DatabaseSecretStore) Put(namespace, name, value string) error {
+	if len(config.Pilotage.Secret.Keys) == 0 {
+		return ErrNoSecretKey
+	}
+
+	token, err := utils.Encrypt([]byte(value), config.Pilotage.Secret.Keys[0])
+	if err != nil {
+		return err
+	}
+	return new(model.SecretV1).Put(namespace, name, string(token))
+}
+
+func (d *DatabaseSecretStore) Delete(namespace, name string) error {
+	return new(model.SecretV1).Delete(namespace, name)
+}
+
+func (d *DatabaseSecretStore) List(namespace string) ([]string, error) {
+	secrets, err := new(model.SecretV1).List(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, secret := range secrets {
+		names = append(names, secret.Name)
+	}
+	return names, nil
+}
+
+// SecretEnv is an environment of job container whose value is the secret.
+type SecretEnv struct {
+	Name   string
+	Secret string
+	Value  string
+}
+
+// LoadSecrets reads the secrets referenced by the flow and its jobs from the store before the flow runs,
+// the values of them are masked in the job logs and notifications.
+func (f *Flow) LoadSecrets() error {
+	names := map[string]bool{}
+	for _, secret := range f.Secrets {
+		for _, name := range secret {
+			names[name] = true
+		}
+	}
+	for _, stage := range f.Stages {
+		for _, action := range stage.Actions {
+			for _, job := range action.Jobs {
+				for _, secret := range job.Secrets {
+					for _, name := range secret {
+						names[name] = true
+					}
+				}
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	namespace, _, _, err := f.URIs()
+	if err != nil {
+		return err
+	}
+
+	f.secrets, f.masks = map[string]string{}, []string{}
+	for name := range names {
+		value, err := Secrets.Get(namespace, name)
+		if err != nil {
+			return err
+		}
+		f.secrets[name] = value
+
+		// The logs are read line by line, so every line of a multiline secret like SSH key is masked.
+		for _, line := range strings.Split(value, "\n") {
+			if line = strings.TrimRight(line, "\r"); strings.TrimSpace(line) != "" {
+				f.masks = append(f.masks, line)
+			}
+		}
+	}
+	sort.Slice(f.masks, func(i, j int) bool { return len(f.masks[i]) > len(f.masks[j]) })
+
+	return nil
+}
+
+// MaskSecrets replaces the secret values of flow in the text, the text is unchanged without the flow.
+func (f *Flow) MaskSecrets(text string) string {
+	if f == nil {
+		return text
+	}
+	return maskSecrets(text, f.masks)
+}
+
+func maskSecrets(text string, masks []string) string {
+	for _, mask := range masks {
+		text = strings.Replace(text, mask, SecretMask, -1)
+	}
+	return text
+}
+
+// SecretEnvs returns the secret environments of job container, the secrets of job override the ones of flow
+// with the same environment name.
+func (j *Job) SecretEnvs(f *Flow) []SecretEnv {
+	envs, names := []SecretEnv{}, map[string]bool{}
+	for _, secrets := range [][]map[string]string{j.Secrets, f.Secrets} {
+		for _, secret := range secrets {
+			for env, name := range secret {
+				if names[env] {
+					continue
+				}
+				names[env] = true
+				envs = append(envs, SecretEnv{Name: env, Secret: name, Value: f.secrets[name]})
+			}
+		}
+	}
+
+	sort.Slice(envs, func(a, b int) bool { return envs[a].Name < envs[b].Name })
+	return envs
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+// memorySecretStore keeps the secrets in memory for tests.
+type memorySecretStore map[string]string
+
+func (m memorySecretStore) Get(namespace, name string) (string, error) {
+	value, ok := m[namespace+"/"+name]
+	if !ok {
+		return "", fmt.Errorf("Secret [%s/%s] is not found", namespace, name)
+	}
+	return value, nil
+}
+
+func (m memorySecretStore) Put(namespace, name, value string) error {
+	m[namespace+"/"+name] = value
+	return nil
+}
+
+func (m memorySecretStore) Delete(namespace, name string) error {
+	delete(m, namespace+"/"+name)
+	return nil
+}
+
+func (m memorySecretStore) List(namespace string) ([]string, error) {
+	names := []string{}
+	for key := range m {
+		if strings.HasPrefix(key, namespace+"/") {
+			names = append(names, strings.TrimPrefix(key, namespace+"/"))
+		}
+	}
+	return names, nil
+}
+
+func useSecrets(secrets memorySecretStore) func() {
+	store := Secrets
+	Secrets = secrets
+	return func() { Secrets = store }
+}
+
+func TestSecretEnvs(t *testing.T) {
+	defer useSecrets(memorySecretStore{"containerops/password": "p4ss", "containerops/key": "k3y"})()
+
+	f := newTestFlow("first\n")
+	f.Secrets = []map[string]string{{"PASSWORD": "password"}, {"KEY": "password"}}
+	f.Stages[1].Actions[0].Jobs[0].Secrets = []map[string]string{{"KEY": "key"}}
+	if err := f.LoadSecrets(); err != nil {
+		t.Fatalf("Load secrets error: %s", err.Error())
+	}
+
+	envs := f.Stages[1].Actions[0].Jobs[0].SecretEnvs(f)
+	want := []SecretEnv{{Name: "KEY", Secret: "key", Value: "k3y"}, {Name: "PASSWORD", Secret: "password", Value: "p4ss"}}
+	if fmt.Sprint(envs) != fmt.Sprint(want) {
+		t.Errorf("Secret envs are %v, want %v", envs, want)
+	}
+
+	f.Stages[1].Actions[0].Jobs[0].Resources = Resource{CPU: "1", Memory: "1Gi"}
+	pod := f.Stages[1].Actions[0].Jobs[0].PodTemplates("pod", f)
+	refs := 0
+	for _, env := range pod.Spec.Containers[0].Env {
+		if env.Name != "KEY" && env.Name != "PASSWORD" {
+			continue
+		}
+		refs++
+		if env.Value != "" || env.ValueFrom == nil || env.ValueFrom.SecretKeyRef == nil {
+			t.Fatalf("Env %s of pod isn't from secret: %v", env.Name, env)
+		}
+		if ref := env.ValueFrom.SecretKeyRef; ref.Name != "pod" || ref.Key != env.Name {
+			t.Errorf("Env %s references secret %s[%s], want pod[%s]", env.Name, ref.Name, ref.Key, env.Name)
+		}
+	}
+	if refs != 2 {
+		t.Errorf("Pod has %d secret envs, want 2", refs)
+	}
+}
+
+func TestMaskSecrets(t *testing.T) {
+	defer useSecrets(memorySecretStore{"containerops/key": "-----BEGIN KEY-----\r\nabc\r\n-----END KEY-----", "containerops/short": "abc1"})()
+
+	f := newTestFlow("first\n")
+	f.Secrets = []map[string]string{{"KEY": "key"}, {"SHORT": "short"}}
+	if err := f.LoadSecrets(); err != nil {
+		t.Fatalf("Load secrets error: %s", err.Error())
+	}
+
+	cases := map[string]string{
+		"-----BEGIN KEY-----": SecretMask,
+		"value abc1 value":    "value ****** value",
+		"abc":                 SecretMask,
+		"nothing":             "nothing",
+	}
+	for text, want := range cases {
+		if got := f.MaskSecrets(text); got != want {
+			t.Errorf("Mask %q is %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestLocalRunSecrets(t *testing.T) {
+	newFakeExecutor()
+	defer useSecrets(memorySecretStore{"containerops/password": "p4ss"})()
+
+	f := newTestFlow("[COUT] CO_RESULT = p4ss\n")
+	f.Secrets = []map[string]string{{"PASSWORD": "password"}}
+	if err := f.LocalRun(context.Background(), false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+	if f.Status != Success {
+		t.Fatalf("Flow status is %s, want %s", f.Status, Success)
+	}
+
+	job := f.Stages[1].Actions[0].Jobs[0]
+	for _, logs := range [][]string{job.Logs, job.Attempts[0].Logs, f.Logs} {
+		for _, log := range logs {
+			if strings.Contains(log, "p4ss") {
+				t.Errorf("Log isn't masked: %s", log)
+			}
+		}
+	}
+
+	// The output is fetched from the line as it is, only the recorded logs are masked.
+	if value, _ := f.GetOutputs().Get("stage0.action0.job0[CO_RESULT]"); value != "p4ss" {
+		t.Errorf("Output is %q, want %q", value, "p4ss")
+	}
+
+	f.LogLevel(model.ERROR, "Executor error: PASSWORD=p4ss", false, false)
+	f.Stages[1].LogLevel(model.ERROR, "Stage error: p4ss", false, false)
+	f.Stages[1].Actions[0].LogLevel(model.ERROR, "Action error: p4ss", false, false)
+	job.LogLevel(model.ERROR, "Kubectl error: p4ss", false, false)
+	for _, logs := range [][]string{f.Logs, f.Stages[1].Logs, f.Stages[1].Actions[0].Logs, job.Logs} {
+		if log := logs[len(logs)-1]; strings.Contains(log, "p4ss") {
+			t.Errorf("Error log isn't masked: %s", log)
+		}
+	}
+
+	message, err := (&Receiver{Template: "{{.Status}} p4ss"}).Message(f.Notification())
+	if err != nil {
+		t.Fatalf("Render message error: %s", err.Error())
+	}
+	if strings.Contains(message, "p4ss") {
+		t.Errorf("Message isn't masked: %s", message)
+	}
+
+	f = newTestFlow("first\n")
+	f.Secrets = []map[string]string{{"PASSWORD": "unknown"}}
+	if err := f.LocalRun(context.Background(), false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+	if f.Status != Failure {
+		t.Errorf("Flow status with missing secret is %s, want %s", f.Status, Failure)
+	}
+}
+
+func TestValidateSecrets(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("first\n")
+	f.Secrets = []map[string]string{{"1PASSWORD": "password"}}
+	f.Stages[1].Actions[0].Jobs[0].Secrets = []map[string]string{{"KEY": "bad/name"}}
+
+	paths := map[string]bool{}
+	for _, e := range f.Validate() {
+		paths[e.Path] = true
+	}
+	for _, path := range []string{"secrets[0]", "stages[1].actions[0].jobs[0].secrets[0]"} {
+		if !paths[path] {
+			t.Errorf("Missing validation error of %s in %v", path, paths)
+		}
+	}
+}
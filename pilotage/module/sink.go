@@ -0,0 +1,253 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Huawei/containerops/pilotage/config"
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+const (
+	// Log Sink Type
+	FileSink          = "file"
+	ElasticsearchSink = "elasticsearch"
+	LokiSink          = "loki"
+
+	defaultSinkBatchSize     = 500
+	defaultSinkFlushInterval = time.Second
+	sinkQueueSize            = 8192
+	sinkRequestTimeout       = 10 * time.Second
+)
+
+// LogEntry is a log of flow run sent to the log sinks. The path is `stage`, `stage.action` or `stage.action.job`
+// of the stage, action or job logs, and empty for the flow logs. The event is the type of an event log.
+type LogEntry struct {
+	Time    time.Time `json:"@timestamp"`
+	Level   string    `json:"level"`
+	Flow    string    `json:"flow"`
+	Tag     string    `json:"tag"`
+	Number  int64     `json:"number"`
+	Phase   string    `json:"phase"`
+	Path    string    `json:"path,omitempty"`
+	Message string    `json:"message"`
+	Event   string    `json:"event,omitempty"`
+}
+
+// LogSink writes the logs to a logging system. The Write is called in one goroutine with a batch of logs.
+type LogSink interface {
+	Write(entries []LogEntry) error
+	Close() error
+}
+
+// LogSinkFactory creates a log sink with the configurations.
+type LogSinkFactory func(c config.SinkConfig) (LogSink, error)
+
+var LogSinkFactories = make(map[string]LogSinkFactory)
+
+func RegisterLogSink(name string, factory LogSinkFactory) error {
+	if _, ok := LogSinkFactories[name]; ok {
+		return fmt.Errorf("Log sink %s already exist", name)
+	}
+	LogSinkFactories[name] = factory
+	return nil
+}
+
+func init() {
+	// The job output lines are sent to the log sinks as the logs of job, the other events are sent as event logs.
+	Events.Subscribe(func(e Event) {
+		if e.Type != JobOutput {
+			sendLog(e.logEntry())
+		}
+	})
+}
+
+var (
+	sinksLock sync.RWMutex
+	sinks     []*sinkWriter
+)
+
+// sinkWriter writes the logs to a sink in batches from its own goroutine, so a slow sink never blocks the jobs
+// or the other sinks. The logs are dropped when the queue is full.
+type sinkWriter struct {
+	name     string
+	sink     LogSink
+	queue    chan LogEntry
+	size     int
+	interval time.Duration
+	dropped  int64
+	done     chan struct{}
+}
+
+// OpenLogSinks creates the log sinks of configurations, the logs of flow runs are sent to them until CloseLogSinks.
+func OpenLogSinks(configs []config.SinkConfig) error {
+	writers := []*sinkWriter{}
+	for i, c := range configs {
+		factory, ok := LogSinkFactories[c.Type]
+		if !ok {
+			closeSinkWriters(writers)
+			return fmt.Errorf("Unknown log sink type of sink [%d]: %s", i, c.Type)
+		}
+
+		sink, err := factory(c)
+		if err != nil {
+			closeSinkWriters(writers)
+			return fmt.Errorf("Create log sink [%d] %s error: %s", i, c.Type, err.Error())
+		}
+
+		w := &sinkWriter{name: c.Type, sink: sink, queue: make(chan LogEntry, sinkQueueSize),
+			size: c.BatchSize, interval: time.Duration(c.FlushInterval) * time.Second, done: make(chan struct{})}
+		if w.size <= 0 {
+			w.size = defaultSinkBatchSize
+		}
+		if w.interval <= 0 {
+			w.interval = defaultSinkFlushInterval
+		}
+
+		go w.run()
+		writers = append(writers, w)
+	}
+
+	sinksLock.Lock()
+	defer sinksLock.Unlock()
+
+	closeSinkWriters(sinks)
+	sinks = writers
+	return nil
+}
+
+// CloseLogSinks writes the queued logs and closes the log sinks.
+func CloseLogSinks() {
+	sinksLock.Lock()
+	defer sinksLock.Unlock()
+
+	closeSinkWriters(sinks)
+	sinks = nil
+}
+
+func closeSinkWriters(writers []*sinkWriter) {
+	for _, w := range writers {
+		close(w.queue)
+		<-w.done
+	}
+}
+
+// sendLog sends the log to all the log sinks.
+func sendLog(entry LogEntry) {
+	sinksLock.RLock()
+	defer sinksLock.RUnlock()
+
+	for _, w := range sinks {
+		select {
+		case w.queue <- entry:
+		default:
+			atomic.AddInt64(&w.dropped, 1)
+		}
+	}
+}
+
+func (w *sinkWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	batch := make([]LogEntry, 0, w.size)
+	write := func() {
+		if dropped := atomic.SwapInt64(&w.dropped, 0); dropped > 0 {
+			printLog(model.WARN, fmt.Sprintf("Log sink %s dropped %d logs for the full queue", w.name, dropped), true, true)
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.sink.Write(batch); err != nil {
+			printLog(model.ERROR, fmt.Sprintf("Log sink %s write %d logs error: %s", w.name, len(batch), err.Error()), true, true)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case entry, ok := <-w.queue:
+			if !ok {
+				write()
+				if err := w.sink.Close(); err != nil {
+					printLog(model.ERROR, fmt.Sprintf("Close log sink %s error: %s", w.name, err.Error()), true, true)
+				}
+				return
+			}
+
+			if batch = append(batch, entry); len(batch) >= w.size {
+				write()
+			}
+		case <-ticker.C:
+			write()
+		}
+	}
+}
+
+// runNumber returns the number of flow run, it's zero when the flow isn't running.
+func (f *Flow) runNumber() int64 {
+	if f == nil {
+		return 0
+	}
+	return f.Number
+}
+
+// sinkLog sends a log of the flow run to the log sinks.
+func (f *Flow) sinkLog(level, phase, path, message string) {
+	entry := LogEntry{Time: time.Now(), Level: level, Phase: phase, Path: path,
+		Message: strings.TrimRight(message, "\r\n")}
+	if f != nil {
+		entry.Flow, entry.Tag, entry.Number = f.URI, f.Tag, f.Number
+	}
+
+	sendLog(entry)
+}
+
+// logEntry returns the event log, the phase is the one of event type like STAGE of stage.finished.
+func (e Event) logEntry() LogEntry {
+	message := []string{e.Type}
+	for _, s := range []string{e.Status, e.Pod, e.Node} {
+		if s != "" {
+			message = append(message, s)
+		}
+	}
+
+	return LogEntry{Time: e.Time, Level: model.INFO, Flow: e.URI, Tag: e.Tag, Number: e.Number,
+		Phase: strings.ToUpper(strings.SplitN(e.Type, ".", 2)[0]), Path: e.Path, Message: strings.Join(message, " "), Event: e.Type}
+}
+
+// postSink posts the body to the HTTP log sink with the basic auth of configurations, and returns the response body.
+func postSink(client *http.Client, c config.SinkConfig, url, contentType string, body []byte, header http.Header) ([]byte, error) {
+	if c.Username != "" {
+		if header == nil {
+			header = http.Header{}
+		}
+		auth := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", c.Username, c.Password)))
+		header.Set("Authorization", fmt.Sprintf("Basic %s", auth))
+	}
+
+	return postHTTP(client, url, contentType, body, header)
+}
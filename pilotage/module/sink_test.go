@@ -0,0 +1,182 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Huawei/containerops/pilotage/config"
+)
+
+func readLogFile(t *testing.T, path string) []LogEntry {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open log file error: %s", err.Error())
+	}
+	defer file.Close()
+
+	entries := []LogEntry{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := LogEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Unmarshal log line %q error: %s", scanner.Text(), err.Error())
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestFileLogSinkRotate(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pilotage-sink")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "logs", "pilotage.log")
+	sink, err := NewFileLogSink(config.SinkConfig{Type: FileSink, Path: path, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("Create file log sink error: %s", err.Error())
+	}
+	s := sink.(*FileLogSink)
+	s.maxSize = 400
+
+	for i := 0; i < 10; i++ {
+		if err := s.Write([]LogEntry{{Level: "INFO", Flow: "containerops/test/flow", Message: strings.Repeat("x", 50)}}); err != nil {
+			t.Fatalf("Write logs error: %s", err.Error())
+		}
+	}
+	s.Close()
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		if entries := readLogFile(t, name); len(entries) == 0 {
+			t.Errorf("The log file %s is empty", name)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("The backups beyond max backups should be removed")
+	}
+}
+
+func TestElasticsearchLogSink(t *testing.T) {
+	var lines []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("Request %s with %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		if user, password, _ := r.BasicAuth(); user != "elastic" || password != "secret" {
+			t.Errorf("Basic auth is %s:%s", user, password)
+		}
+
+		data, _ := ioutil.ReadAll(r.Body)
+		lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+		if strings.Contains(string(data), "fail") {
+			w.Write([]byte(`{"errors":true,"items":[{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}]}`))
+			return
+		}
+		w.Write([]byte(`{"errors":false,"items":[{"index":{"status":201}}]}`))
+	}))
+	defer server.Close()
+
+	sink, _ := NewElasticsearchLogSink(config.SinkConfig{URL: server.URL + "/", Username: "elastic", Password: "secret"})
+	if err := sink.Write([]LogEntry{{Level: "INFO", Message: "hello"}}); err != nil {
+		t.Fatalf("Write logs error: %s", err.Error())
+	}
+
+	if len(lines) != 2 || lines[0] != `{"index":{"_index":"pilotage"}}` || !strings.Contains(lines[1], `"message":"hello"`) {
+		t.Errorf("Bulk body is %v", lines)
+	}
+
+	if err := sink.Write([]LogEntry{{Level: "INFO", Message: "fail"}}); err == nil ||
+		!strings.Contains(err.Error(), "mapper_parsing_exception") {
+		t.Errorf("Write error is %v, want the bulk item error", err)
+	}
+}
+
+func TestLokiLogSink(t *testing.T) {
+	push := struct {
+		Streams []lokiStream `json:"streams"`
+	}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/loki/api/v1/push" || r.Header.Get("X-Scope-OrgID") != "ops" {
+			t.Errorf("Request %s with tenant %q", r.URL.Path, r.Header.Get("X-Scope-OrgID"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+			t.Errorf("Decode push error: %s", err.Error())
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink, _ := NewLokiLogSink(config.SinkConfig{URL: server.URL, Tenant: "ops", Labels: map[string]string{"env": "ci"}})
+	now := time.Now()
+	err := sink.Write([]LogEntry{
+		{Time: now, Level: "INFO", Flow: "containerops/test/flow", Message: "first"},
+		{Time: now, Level: "ERROR", Flow: "containerops/test/flow", Message: "second"},
+		{Time: now, Level: "INFO", Flow: "containerops/test/flow", Message: "third"},
+	})
+	if err != nil {
+		t.Fatalf("Write logs error: %s", err.Error())
+	}
+
+	if len(push.Streams) != 2 || len(push.Streams[0].Values) != 2 || len(push.Streams[1].Values) != 1 {
+		t.Fatalf("Pushed streams are %v", push.Streams)
+	}
+	if labels := push.Streams[0].Stream; labels["env"] != "ci" || labels["level"] != "INFO" || labels["flow"] != "containerops/test/flow" {
+		t.Errorf("Stream labels are %v", labels)
+	}
+	if value := push.Streams[1].Values[0]; !strings.Contains(value[1], `"message":"second"`) {
+		t.Errorf("Stream value is %v", value)
+	}
+}
+
+func TestOpenLogSinks(t *testing.T) {
+	if err := OpenLogSinks([]config.SinkConfig{{Type: "unknown"}}); err == nil {
+		t.Errorf("Open the unknown log sink should fail")
+	}
+
+	dir, _ := ioutil.TempDir("", "pilotage-sink")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "pilotage.log")
+	if err := OpenLogSinks([]config.SinkConfig{{Type: FileSink, Path: path}}); err != nil {
+		t.Fatalf("Open log sinks error: %s", err.Error())
+	}
+
+	newFakeExecutor()
+	f := newTestFlow("first\n")
+	f.LocalRun(context.Background(), false, false)
+	CloseLogSinks()
+
+	found := false
+	for _, entry := range readLogFile(t, path) {
+		if entry.Path == "stage0.action0.job0" && entry.Message == "first" && entry.Flow == f.URI && entry.Number == f.Number {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("The output line of job isn't in the log file")
+	}
+}
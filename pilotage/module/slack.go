@@ -0,0 +1,40 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"encoding/json"
+)
+
+func init() {
+	Register("slack", &SlackNotifier{})
+}
+
+// SlackNotifier posts the message to the Incoming Webhook of Slack, the address of receiver is the webhook URL.
+type SlackNotifier struct {
+}
+
+func (s *SlackNotifier) Notify(flow *Flow, n Notification, receiver Receiver) error {
+	message, err := receiver.Message(n)
+	if err != nil {
+		return err
+	}
+
+	body, _ := json.Marshal(map[string]string{"text": message})
+	_, err = postHTTP(notifyClient, receiver.Address, "application/json", body, nil)
+	return err
+}
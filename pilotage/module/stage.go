@@ -1,8 +1,12 @@
 package module
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Huawei/containerops/pilotage/model"
@@ -17,35 +21,92 @@ const (
 	PauseStage  = "pause"
 )
 
+var pauseLock sync.Mutex
+
+// logsLock guards the logs of flow, stage and job, which are written by the stages and actions running in parallel
+// and by the goroutines deleting the containers of cancelled jobs.
+var logsLock sync.Mutex
+
 // Stage is
 type Stage struct {
-	ID         int64    `json:"-" yaml:"-"`
-	T          string   `json:"type" yaml:"type"`
-	Name       string   `json:"name" yaml:"name"`
-	Title      string   `json:"title" yaml:"title"`
-	Sequencing string   `json:"sequencing,omitempty" yaml:"sequencing,omitempty"`
-	Status     string   `json:"status,omitempty" yaml:"status,omitempty"`
-	Logs       []string `json:"logs,omitempty" yaml:"logs,omitempty"`
-	Actions    []Action `json:"actions,omitempty" yaml:"actions,omitempty"`
+	ID            int64        `json:"-" yaml:"-"`
+	T             string       `json:"type" yaml:"type"`
+	Name          string       `json:"name" yaml:"name"`
+	Title         string       `json:"title" yaml:"title"`
+	Sequencing    string       `json:"sequencing,omitempty" yaml:"sequencing,omitempty"`
+	DependsOn     []string     `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
+	Parallelism   int          `json:"parallelism,omitempty" yaml:"parallelism,omitempty"`
+	FailFast      bool         `json:"fail_fast,omitempty" yaml:"fail_fast,omitempty"`
+	Timeout       int64        `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	TimeoutPolicy string       `json:"timeout_policy,omitempty" yaml:"timeout_policy,omitempty"`
+	Approval      *Approval    `json:"approval,omitempty" yaml:"approval,omitempty"`
+	When          string       `json:"when,omitempty" yaml:"when,omitempty"`
+	Status        string       `json:"status,omitempty" yaml:"status,omitempty"`
+	Logs          []string     `json:"logs,omitempty" yaml:"logs,omitempty"`
+	Actions       []Action     `json:"actions,omitempty" yaml:"actions,omitempty"`
+	Receivers     []Receiver   `json:"receivers,omitempty" yaml:"receivers,omitempty"`
+	Downstreams   []Downstream `json:"downstreams,omitempty" yaml:"downstreams,omitempty"`
+
+	resume chan Approval
+	failed failure
+	flow   *Flow
+	start  time.Time
+	end    time.Time
+}
+
+// Approval is whom resumes a pause stage and why.
+type Approval struct {
+	Approver string `json:"approver" yaml:"approver"`
+	Comment  string `json:"comment" yaml:"comment"`
 }
 
-// TODO filter the log print with different color.
+// Log records an INFO log of stage.
 func (s *Stage) Log(log string, verbose, timestamp bool) {
+	s.LogLevel(model.INFO, log, verbose, timestamp)
+}
+
+// LogLevel records a log of stage in the level, the secret values in the log are masked.
+func (s *Stage) LogLevel(level, log string, verbose, timestamp bool) {
+	log = s.flow.MaskSecrets(log)
+	logsLock.Lock()
 	s.Logs = append(s.Logs, fmt.Sprintf("[%s] %s", time.Now().String(), log))
+	logsLock.Unlock()
 	l := new(model.LogV1)
-	l.Create(model.INFO, model.STAGE, s.ID, log)
+	l.Create(level, model.STAGE, s.ID, s.flow.runNumber(), log)
+	s.flow.sinkLog(level, model.STAGE, s.Name, log)
 
-	if verbose == true {
-		if timestamp == true {
-			fmt.Println(Cyan(fmt.Sprintf("[%s] %s", time.Now().String(), strings.TrimSpace(log))))
-		} else {
-			fmt.Println(Cyan(log))
-		}
+	printLog(level, log, verbose, timestamp)
+}
+
+// ActionDependencies returns the indexes of actions which every action depends on. In the sequence stage,
+// an action without depends_on waits for all actions before it; in the parallel stage, it runs at once.
+func (s *Stage) ActionDependencies() ([][]int, error) {
+	names, dependsOn := make([]string, len(s.Actions)), make([][]string, len(s.Actions))
+	for i, action := range s.Actions {
+		names[i], dependsOn[i] = action.Name, action.DependsOn
 	}
+
+	dependencies, err := Dependencies(names, dependsOn, s.Sequencing != Parallel)
+	if err != nil {
+		return nil, fmt.Errorf("Stage [%s] actions error: %s", s.Name, err.Error())
+	}
+
+	return dependencies, nil
 }
 
-func (s *Stage) SequencingRun(verbose, timestamp bool, f *Flow, stageIndex int) (string, error) {
-	s.Status = Running
+// Run runs the actions of stage by the dependencies, at most parallelism actions run at the same time.
+func (s *Stage) Run(ctx context.Context, verbose, timestamp bool, f *Flow, stageIndex int) (string, error) {
+	s.flow = f
+	if s.Sequencing != Sequencing && s.Sequencing != Parallel {
+		return Failure, fmt.Errorf("Stage [%s] has unknown sequencing type: %s", s.Name, s.Sequencing)
+	}
+
+	dependencies, err := s.ActionDependencies()
+	if err != nil {
+		return Failure, err
+	}
+
+	s.Status, s.failed = Running, 0
 
 	s.Log(fmt.Sprintf("Stage [%s] status change to %s", s.Name, s.Status), false, timestamp)
 	f.Log(fmt.Sprintf("Stage [%s] status change to %s", s.Name, s.Status), verbose, timestamp)
@@ -54,7 +115,7 @@ func (s *Stage) SequencingRun(verbose, timestamp bool, f *Flow, stageIndex int)
 	stage := new(model.StageV1)
 	stageID, err := stage.Put(f.ID, s.T, s.Name, s.Title, s.Sequencing)
 	if err != nil {
-		s.Log(fmt.Sprintf("Save Stage [%s] error: %s", s.Name, err.Error()), false, timestamp)
+		s.LogLevel(model.ERROR, fmt.Sprintf("Save Stage [%s] error: %s", s.Name, err.Error()), false, timestamp)
 	}
 	s.ID = stageID
 
@@ -62,40 +123,64 @@ func (s *Stage) SequencingRun(verbose, timestamp bool, f *Flow, stageIndex int)
 	stageData := new(model.StageDataV1)
 	startTime := time.Now()
 
-	for i, _ := range s.Actions {
-		action := &s.Actions[i]
+	s.Status = RunDAG(ctx, dependencies, s.Parallelism, s.FailFast, func(ctx context.Context, index int) (status string) {
+		action := &s.Actions[index]
+		action.start = time.Now()
+		defer func() {
+			action.Status, action.end = status, time.Now()
+			f.emit(Event{Type: ActionFinished, Path: fmt.Sprintf("%s.%s", s.Name, action.Name), Status: status})
+		}()
 
-		s.Log(fmt.Sprintf("The Number [%d] action is running: %s", i, s.Title), false, timestamp)
-		f.Log(fmt.Sprintf("The Number [%d] action is running: %s", i, s.Title), verbose, timestamp)
+		// The action not started before a failure of fail_fast stage is cancelled.
+		if status := ContextStatus(ctx); status != "" {
+			s.failed.Record(status)
+			return status
+		}
 
-		if status, err := action.Run(verbose, timestamp, f, stageIndex, i); err != nil {
-			s.Status = Failure
+		failed := upstreamFailed(dependencies, index, func(i int) string { return s.Actions[i].Status })
+		if run, err := f.When(action.When, failed); err != nil {
+			s.LogLevel(model.ERROR, fmt.Sprintf("Action [%s] when error: %s", action.Name, err.Error()), false, timestamp)
+			f.LogLevel(model.ERROR, fmt.Sprintf("Action [%s] when error: %s", action.Name, err.Error()), verbose, timestamp)
+			s.failed.Record(Failure)
+			return Failure
+		} else if !run {
+			action.Status = Skipped
+			s.Log(fmt.Sprintf("Action [%s] is skipped", action.Name), false, timestamp)
+			f.Log(fmt.Sprintf("Action [%s] is skipped", action.Name), verbose, timestamp)
+			return Skipped
+		}
 
-			s.Log(fmt.Sprintf("Action [%s] run error: %s", action.Name, err.Error()), false, timestamp)
-			f.Log(fmt.Sprintf("Action [%s] run error: %s", action.Name, err.Error()), verbose, timestamp)
+		s.Log(fmt.Sprintf("The Number [%d] action is running: %s", index, action.Title), false, timestamp)
+		f.Log(fmt.Sprintf("The Number [%d] action is running: %s", index, action.Title), verbose, timestamp)
 
-		} else {
-			s.Status = status
+		status, err := action.Run(ctx, verbose, timestamp, f, stageIndex, index)
+		if err != nil {
+			s.LogLevel(model.ERROR, fmt.Sprintf("Action [%s] run error: %s", action.Name, err.Error()), false, timestamp)
+			f.LogLevel(model.ERROR, fmt.Sprintf("Action [%s] run error: %s", action.Name, err.Error()), verbose, timestamp)
+			status = Failure
 		}
 
-		if s.Status == Failure || s.Status == Cancel {
-			break
-		}
-	}
+		s.failed.Record(status)
+		return status
+	})
 
 	currentNumber, err := stageData.GetNumbers(stageID)
 	if err != nil {
-		s.Log(fmt.Sprintf("Get Stage Data [%s] Numbers error: %s", s.Name, err.Error()), verbose, timestamp)
+		s.LogLevel(model.ERROR, fmt.Sprintf("Get Stage Data [%s] Numbers error: %s", s.Name, err.Error()), verbose, timestamp)
 	}
-	if err := stageData.Put(s.ID, currentNumber+1, s.Status, startTime, time.Now()); err != nil {
-		s.Log(fmt.Sprintf("Save Stage Data [%s] error: %s", s.Name, err.Error()), false, timestamp)
+	if err := stageData.Put(s.ID, currentNumber+1, f.Number, s.Status, startTime, time.Now()); err != nil {
+		s.LogLevel(model.ERROR, fmt.Sprintf("Save Stage Data [%s] error: %s", s.Name, err.Error()), false, timestamp)
 	}
 
 	return s.Status, nil
 }
 
-func (s *Stage) ParallelRun(verbose, timestamp bool, f *Flow, stageIndex int) (string, error) {
-	s.Status = Running
+// PauseRun waits for the stage resumed by Resume. When the timeout seconds of stage exceeded,
+// the stage status is failure or cancel according to the timeout policy, and zero timeout means wait forever.
+func (s *Stage) PauseRun(ctx context.Context, verbose, timestamp bool, f *Flow, stageIndex int) (string, error) {
+	pauseLock.Lock()
+	s.Status, s.resume, s.flow = Paused, make(chan Approval, 1), f
+	pauseLock.Unlock()
 
 	s.Log(fmt.Sprintf("Stage [%s] status change to %s", s.Name, s.Status), false, timestamp)
 	f.Log(fmt.Sprintf("Stage [%s] status change to %s", s.Name, s.Status), verbose, timestamp)
@@ -104,55 +189,136 @@ func (s *Stage) ParallelRun(verbose, timestamp bool, f *Flow, stageIndex int) (s
 	stage := new(model.StageV1)
 	stageID, err := stage.Put(f.ID, s.T, s.Name, s.Title, s.Sequencing)
 	if err != nil {
-		s.Log(fmt.Sprintf("Save Stage [%s] error: %s", s.Name, err.Error()), false, timestamp)
+		s.LogLevel(model.ERROR, fmt.Sprintf("Save Stage [%s] error: %s", s.Name, err.Error()), false, timestamp)
 	}
 	s.ID = stageID
 
-	// Record stage data
+	// Record stage data and the pause
 	stageData := new(model.StageDataV1)
 	startTime := time.Now()
 
-	resultChan := make(chan string)
-	defer close(resultChan)
-	count := 0
-	for i, _ := range s.Actions {
-		go func(index int) {
-			action := &s.Actions[index]
-			var tempStaus string
+	pause := new(model.PauseV1)
+	if err := pause.Put(f.ID, s.ID, f.Number, s.Status, startTime); err != nil {
+		s.LogLevel(model.ERROR, fmt.Sprintf("Save Pause [%s] error: %s", s.Name, err.Error()), false, timestamp)
+	}
 
-			s.Log(fmt.Sprintf("The Number [%d] action is running: %s", index, s.Title), false, timestamp)
-			f.Log(fmt.Sprintf("The Number [%d] action is running: %s", index, s.Title), verbose, timestamp)
+	var timeout <-chan time.Time
+	if s.Timeout > 0 {
+		timer := time.NewTimer(time.Duration(s.Timeout) * time.Second)
+		defer timer.Stop()
+		timeout = timer.C
+	}
 
-			if status, err := action.Run(verbose, timestamp, f, stageIndex, index); err != nil {
-				tempStaus = Failure
+	// The cli mode doesn't have API, resume the stage from the terminal. The reading stops when the stage is resumed,
+	// timeout or cancelled.
+	stopTerminal := func() {}
+	if f.Model == CliRun {
+		if lines := terminalInput(); lines != nil {
+			var terminalCtx context.Context
+			terminalCtx, stopTerminal = context.WithCancel(ctx)
+			go s.resumeFromTerminal(terminalCtx, lines, verbose, timestamp, f)
+		}
+	}
 
-				s.Log(fmt.Sprintf("Action [%s] run error: %s", action.Name, err.Error()), false, timestamp)
-				f.Log(fmt.Sprintf("Action [%s] run error: %s", action.Name, err.Error()), verbose, timestamp)
-			} else {
-				tempStaus = status
-			}
-			resultChan <- tempStaus
+	var status string
+	approval := Approval{}
+	select {
+	case approval = <-s.resume:
+		status = Success
+		s.Log(fmt.Sprintf("Stage [%s] is resumed by [%s]: %s", s.Name, approval.Approver, approval.Comment), false, timestamp)
+		f.Log(fmt.Sprintf("Stage [%s] is resumed by [%s]: %s", s.Name, approval.Approver, approval.Comment), verbose, timestamp)
+	case <-timeout:
+		status = Failure
+		if s.TimeoutPolicy == Cancel {
+			status = Cancel
+		}
+		s.Log(fmt.Sprintf("Stage [%s] is not resumed in %d seconds, status change to %s", s.Name, s.Timeout, status), false, timestamp)
+		f.Log(fmt.Sprintf("Stage [%s] is not resumed in %d seconds, status change to %s", s.Name, s.Timeout, status), verbose, timestamp)
+	case <-ctx.Done():
+		status = ContextStatus(ctx)
+	}
+	stopTerminal()
+
+	pauseLock.Lock()
+	s.Status = status
+	if approval.Approver != "" || approval.Comment != "" {
+		s.Approval = &approval
+	}
+	pauseLock.Unlock()
+
+	if err := pause.Resume(s.Status, approval.Approver, approval.Comment, time.Now()); err != nil {
+		s.LogLevel(model.ERROR, fmt.Sprintf("Save Pause [%s] error: %s", s.Name, err.Error()), false, timestamp)
+	}
+
+	currentNumber, err := stageData.GetNumbers(stageID)
+	if err != nil {
+		s.LogLevel(model.ERROR, fmt.Sprintf("Get Stage Data [%s] Numbers error: %s", s.Name, err.Error()), verbose, timestamp)
+	}
+	if err := stageData.Put(s.ID, currentNumber+1, f.Number, s.Status, startTime, time.Now()); err != nil {
+		s.LogLevel(model.ERROR, fmt.Sprintf("Save Stage Data [%s] error: %s", s.Name, err.Error()), false, timestamp)
+	}
+
+	return s.Status, nil
+}
 
-		}(i)
+// Resume resumes a paused stage.
+func (s *Stage) Resume(approval Approval) error {
+	pauseLock.Lock()
+	defer pauseLock.Unlock()
+
+	if s.T != PauseStage || s.Status != Paused {
+		return fmt.Errorf("Stage [%s] is not paused", s.Name)
+	}
+
+	select {
+	case s.resume <- approval:
+		return nil
+	default:
+		return fmt.Errorf("Stage [%s] is resuming", s.Name)
 	}
+}
 
-	for {
-		if result, ok := <-resultChan; ok {
-			count++
-			s.Status = result
-			if result == Failure || result == Cancel || count == len(s.Actions) {
+var (
+	terminalOnce  sync.Once
+	terminalLines chan string
+)
+
+// terminalInput returns the lines input from the terminal. The lines are read by one goroutine shared by all the paused
+// stages, so a stage resumed by API or timeout doesn't leave a reader blocking on the stdin. It's nil when the stdin
+// isn't a terminal.
+func terminalInput() <-chan string {
+	terminalOnce.Do(func() {
+		if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return
+		}
 
-				currentNumber, err := stageData.GetNumbers(stageID)
+		terminalLines = make(chan string)
+		go func() {
+			reader := bufio.NewReader(os.Stdin)
+			for {
+				line, err := reader.ReadString('\n')
 				if err != nil {
-					s.Log(fmt.Sprintf("Get Stage Data [%s] Numbers error: %s", s.Name, err.Error()), verbose, timestamp)
+					close(terminalLines)
+					return
 				}
-				if err := stageData.Put(s.ID, currentNumber+1, s.Status, startTime, time.Now()); err != nil {
-					s.Log(fmt.Sprintf("Save Stage Data [%s] error: %s", s.Name, err.Error()), false, timestamp)
-				}
-
-				return s.Status, nil
+				terminalLines <- line
 			}
-		}
+		}()
+	})
 
+	return terminalLines
+}
+
+func (s *Stage) resumeFromTerminal(ctx context.Context, lines <-chan string, verbose, timestamp bool, f *Flow) {
+	fmt.Println(Yellow(fmt.Sprintf("Stage [%s] is paused, input the comment and press Enter to resume:", s.Name)))
+
+	select {
+	case comment, ok := <-lines:
+		if !ok {
+			f.LogLevel(model.ERROR, fmt.Sprintf("Read the comment of stage [%s] error: the terminal is closed", s.Name), verbose, timestamp)
+			return
+		}
+		s.Resume(Approval{Approver: os.Getenv("USER"), Comment: strings.TrimSpace(comment)})
+	case <-ctx.Done():
 	}
 }
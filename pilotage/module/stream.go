@@ -0,0 +1,141 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// The buffer size of subscriber channel, a subscriber is dropped when it's full.
+const logStreamBuffer = 1024
+
+// LogLine is a line of flow run logs, the job is `stage.action.job` of a job log and empty for a flow log.
+type LogLine struct {
+	Job  string    `json:"job,omitempty"`
+	Time time.Time `json:"time"`
+	Line string    `json:"line"`
+}
+
+type logSubscriber struct {
+	job string
+	ch  chan LogLine
+}
+
+// LogStream buffers the logs of a flow run, and broadcasts the new lines to the subscribers until the run is finished.
+type LogStream struct {
+	lock        sync.Mutex
+	lines       []LogLine
+	subscribers map[<-chan LogLine]logSubscriber
+	closed      bool
+}
+
+func NewLogStream() *LogStream {
+	return &LogStream{subscribers: make(map[<-chan LogLine]logSubscriber)}
+}
+
+// match returns whether the line is the log of job, the job could be a stage, an action or a job.
+func (s logSubscriber) match(line LogLine) bool {
+	return s.job == "" || line.Job == s.job || strings.HasPrefix(line.Job, s.job+".")
+}
+
+// Publish appends a line to the stream, it does nothing on a nil stream.
+func (s *LogStream) Publish(job, line string) {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	l := LogLine{Job: job, Time: time.Now(), Line: strings.TrimRight(line, "\r\n")}
+	s.lines = append(s.lines, l)
+
+	for key, subscriber := range s.subscribers {
+		if !subscriber.match(l) {
+			continue
+		}
+
+		select {
+		case subscriber.ch <- l:
+		default:
+			// Never block the job by a slow subscriber.
+			delete(s.subscribers, key)
+			close(subscriber.ch)
+		}
+	}
+}
+
+// Subscribe returns the buffered lines of job and a channel of the new lines, an empty job subscribes all the lines.
+// The channel is closed when the run is finished, or the subscriber is too slow receiving the lines.
+func (s *LogStream) Subscribe(job string) ([]LogLine, <-chan LogLine) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	subscriber := logSubscriber{job: job, ch: make(chan LogLine, logStreamBuffer)}
+
+	lines := []LogLine{}
+	for _, l := range s.lines {
+		if subscriber.match(l) {
+			lines = append(lines, l)
+		}
+	}
+
+	if s.closed {
+		close(subscriber.ch)
+	} else {
+		s.subscribers[subscriber.ch] = subscriber
+	}
+
+	return lines, subscriber.ch
+}
+
+// Unsubscribe stops sending lines to the channel returned by Subscribe.
+func (s *LogStream) Unsubscribe(ch <-chan LogLine) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if subscriber, ok := s.subscribers[ch]; ok {
+		delete(s.subscribers, ch)
+		close(subscriber.ch)
+	}
+}
+
+// Close closes the channels of all subscribers when the run is finished.
+func (s *LogStream) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	for key, subscriber := range s.subscribers {
+		delete(s.subscribers, key)
+		close(subscriber.ch)
+	}
+}
+
+// Closed returns whether the run of stream is finished.
+func (s *LogStream) Closed() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.closed
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLogStream(t *testing.T) {
+	s := NewLogStream()
+	s.Publish("", "flow started\n")
+	s.Publish("build.compile.job", "compiling\n")
+
+	lines, ch := s.Subscribe("")
+	if len(lines) != 2 || lines[0].Line != "flow started" || lines[1].Job != "build.compile.job" {
+		t.Fatalf("Replayed lines are %v", lines)
+	}
+
+	s.Publish("build.compile.job", "done\n")
+	if line := <-ch; line.Line != "done" {
+		t.Errorf("Tailed line is %q, want %q", line.Line, "done")
+	}
+
+	s.Close()
+	if _, ok := <-ch; ok {
+		t.Errorf("The channel should be closed when the stream is closed")
+	}
+
+	s.Publish("", "after closed")
+	if lines, ch := s.Subscribe(""); len(lines) != 3 {
+		t.Errorf("Replayed lines after closed are %v", lines)
+	} else if _, ok := <-ch; ok {
+		t.Errorf("The channel subscribed after closed should be closed")
+	}
+}
+
+func TestLogStreamJob(t *testing.T) {
+	s := NewLogStream()
+	s.Publish("build.compile.job", "compile")
+	s.Publish("build.compile.job2", "compile2")
+	s.Publish("build.test.job", "test")
+	s.Publish("", "flow")
+
+	for job, want := range map[string]int{"build": 3, "build.compile": 2, "build.compile.job": 1, "build.comp": 0} {
+		if lines, _ := s.Subscribe(job); len(lines) != want {
+			t.Errorf("Lines of %q are %v, want %d lines", job, lines, want)
+		}
+	}
+}
+
+func TestLogStreamSlowSubscriber(t *testing.T) {
+	s := NewLogStream()
+	_, ch := s.Subscribe("")
+
+	for i := 0; i <= logStreamBuffer; i++ {
+		s.Publish("", "line")
+	}
+
+	count := 0
+	for range ch {
+		count++
+	}
+	if count != logStreamBuffer {
+		t.Errorf("The slow subscriber received %d lines, want %d", count, logStreamBuffer)
+	}
+	if s.Closed() {
+		t.Errorf("The stream should not be closed by a slow subscriber")
+	}
+}
+
+func TestLocalRunLogStream(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("first\n", "second\n")
+	if err := f.LocalRun(context.Background(), false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+
+	lines, _ := f.GetLogStream().Subscribe("stage1.action1.job1")
+	if len(lines) != 1 || lines[0].Line != "second" {
+		t.Errorf("Job lines are %v", lines)
+	}
+
+	if lines, _ := f.GetLogStream().Subscribe(""); len(lines) <= 2 {
+		t.Errorf("Flow lines are %v", lines)
+	}
+}
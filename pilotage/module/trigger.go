@@ -0,0 +1,234 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const (
+	// Git Webhook Type
+	GitHubTrigger = "github"
+	GitLabTrigger = "gitlab"
+	GiteaTrigger  = "gitea"
+
+	// Schedule Trigger Type
+	ScheduleTrigger = "schedule"
+
+	// Missed Run Policy, the schedule fired when the daemon is stopped runs once after restart or is skipped.
+	MissedSkip    = "skip"
+	MissedRunOnce = "run_once"
+
+	// Overlap Policy, a schedule fired when the last run is running starts another run, is skipped or cancels the last run.
+	OverlapAllow   = "allow"
+	OverlapForbid  = "forbid"
+	OverlapReplace = "replace"
+
+	// Git Event Type
+	PushEvent        = "push"
+	TagEvent         = "tag"
+	PullRequestEvent = "pull_request"
+)
+
+var ErrInvalidSignature = errors.New("Invalid webhook signature")
+
+var GitWebhooks = make(map[string]GitWebhook)
+
+// GitWebhook receives the webhook of a git provider. Verify checks the signature or token of request with the
+// secret of trigger, and Parse returns the event of payload, the event is nil when it doesn't trigger flows
+// like the ping of GitHub or a deleted branch.
+type GitWebhook interface {
+	Verify(header http.Header, body []byte, secret string) error
+	Parse(header http.Header, body []byte) (*GitEvent, error)
+}
+
+func RegisterGitWebhook(name string, webhook GitWebhook) error {
+	if _, ok := GitWebhooks[name]; ok {
+		return fmt.Errorf("Git webhook %s already exist", name)
+	}
+	GitWebhooks[name] = webhook
+	return nil
+}
+
+// GitEvent is a push, tag or pull request of git repository. The branch is the pushed branch, or the target
+// branch of pull request whose source branch is the head branch. The paths are the changed files of push.
+type GitEvent struct {
+	Provider    string   `json:"provider"`
+	Event       string   `json:"event"`
+	Repository  string   `json:"repository"`
+	Branch      string   `json:"branch,omitempty"`
+	HeadBranch  string   `json:"head_branch,omitempty"`
+	Tag         string   `json:"tag,omitempty"`
+	Commit      string   `json:"commit"`
+	Author      string   `json:"author"`
+	PullRequest int64    `json:"pull_request,omitempty"`
+	Paths       []string `json:"paths,omitempty"`
+}
+
+// Environments returns the flow environments of event like CO_GIT_COMMIT and CO_GIT_BRANCH.
+func (e *GitEvent) Environments() map[string]string {
+	envs := map[string]string{}
+	for k, v := range map[string]string{
+		"CO_GIT_PROVIDER":    e.Provider,
+		"CO_GIT_EVENT":       e.Event,
+		"CO_GIT_REPOSITORY":  e.Repository,
+		"CO_GIT_BRANCH":      e.Branch,
+		"CO_GIT_HEAD_BRANCH": e.HeadBranch,
+		"CO_GIT_TAG":         e.Tag,
+		"CO_GIT_COMMIT":      e.Commit,
+		"CO_GIT_AUTHOR":      e.Author,
+	} {
+		if v != "" {
+			envs[k] = v
+		}
+	}
+	if e.PullRequest > 0 {
+		envs["CO_GIT_PULL_REQUEST"] = fmt.Sprintf("%d", e.PullRequest)
+	}
+
+	return envs
+}
+
+// Trigger runs the flow by the webhook of a git provider. The events are push, tag and pull_request, and
+// push is the default. The branches, tags and paths are glob patterns, `**` in the end matches all the
+// subdirectories. The paths filter only applies to the push event with the changed files.
+// The schedule trigger runs the flow by the cron expression in the daemon, the missed policy is skip by default
+// and the overlap policy is allow by default.
+type Trigger struct {
+	Type       string   `json:"type" yaml:"type"`
+	Secret     string   `json:"secret,omitempty" yaml:"secret,omitempty"`
+	Events     []string `json:"events,omitempty" yaml:"events,omitempty"`
+	Repository string   `json:"repository,omitempty" yaml:"repository,omitempty"`
+	Branches   []string `json:"branches,omitempty" yaml:"branches,omitempty"`
+	Tags       []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Paths      []string `json:"paths,omitempty" yaml:"paths,omitempty"`
+	Schedule   string   `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	Missed     string   `json:"missed,omitempty" yaml:"missed,omitempty"`
+	Overlap    string   `json:"overlap,omitempty" yaml:"overlap,omitempty"`
+}
+
+// Match returns whether the event triggers the flow.
+func (t *Trigger) Match(e *GitEvent) bool {
+	events := t.Events
+	if len(events) == 0 {
+		events = []string{PushEvent}
+	}
+	if !contains(events, e.Event) {
+		return false
+	}
+
+	if t.Repository != "" && !strings.EqualFold(t.Repository, e.Repository) {
+		return false
+	}
+
+	if e.Event == TagEvent {
+		if len(t.Tags) > 0 && !matchAny(t.Tags, e.Tag) {
+			return false
+		}
+	} else if len(t.Branches) > 0 && !matchAny(t.Branches, e.Branch) {
+		return false
+	}
+
+	if e.Event == PushEvent && len(t.Paths) > 0 && len(e.Paths) > 0 {
+		for _, p := range e.Paths {
+			if matchAny(t.Paths, p) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// TriggeredBy returns whether the webhook of provider triggers the flow. The webhook is verified with the secret
+// of every trigger of the provider, and ErrInvalidSignature is returned when none of them verifies it.
+func (f *Flow) TriggeredBy(provider string, header http.Header, body []byte, e *GitEvent) (bool, error) {
+	triggers, err := f.VerifiedTriggers(provider, header, body)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range triggers {
+		if triggers[i].Match(e) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// VerifiedTriggers returns the triggers of provider whose secrets verify the webhook, so the payload is parsed
+// after it's verified. It's empty when the flow has no trigger of provider, and ErrInvalidSignature is returned
+// when none of them verifies it.
+func (f *Flow) VerifiedTriggers(provider string, header http.Header, body []byte) ([]Trigger, error) {
+	webhook, ok := GitWebhooks[provider]
+	if !ok {
+		return nil, fmt.Errorf("Unknown git webhook: %s", provider)
+	}
+
+	triggers, unverified := []Trigger{}, false
+	for _, trigger := range f.Triggers {
+		if trigger.Type != provider {
+			continue
+		}
+		if webhook.Verify(header, body, trigger.Secret) != nil {
+			unverified = true
+			continue
+		}
+		triggers = append(triggers, trigger)
+	}
+
+	if len(triggers) == 0 && unverified {
+		return nil, ErrInvalidSignature
+	}
+	return triggers, nil
+}
+
+// matchAny returns whether the name matches one of the glob patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if pattern == "**" {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/**") && strings.HasPrefix(name, strings.TrimSuffix(pattern, "**")) {
+			return true
+		}
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPattern returns the error of an invalid glob pattern.
+func checkPattern(pattern string) error {
+	_, err := path.Match(strings.TrimSuffix(pattern, "/**"), "")
+	return err
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const githubPush = `{
+  "ref": "refs/heads/release/1.0",
+  "after": "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+  "repository": {"full_name": "Huawei/containerops"},
+  "sender": {"login": "octocat"},
+  "commits": [{"added": ["pilotage/module/trigger.go"], "removed": [], "modified": ["README.md"]}]
+}`
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestGitHubWebhook(t *testing.T) {
+	webhook := GitWebhooks[GitHubTrigger]
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "push")
+	header.Set("X-Hub-Signature-256", "sha256="+sign("secret", githubPush))
+
+	if err := webhook.Verify(header, []byte(githubPush), "secret"); err != nil {
+		t.Errorf("Verify error: %s", err.Error())
+	}
+	if err := webhook.Verify(header, []byte(githubPush), "other"); err != ErrInvalidSignature {
+		t.Errorf("Verify with other secret error is %v", err)
+	}
+	if err := webhook.Verify(header, []byte(githubPush), ""); err != ErrInvalidSignature {
+		t.Errorf("Verify with empty secret error is %v", err)
+	}
+
+	e, err := webhook.Parse(header, []byte(githubPush))
+	if err != nil {
+		t.Fatalf("Parse error: %s", err.Error())
+	}
+	if e.Event != PushEvent || e.Branch != "release/1.0" || e.Commit != "6dcb09b5b57875f334f61aebed695e2e4193db5e" ||
+		e.Author != "octocat" || e.Repository != "Huawei/containerops" || len(e.Paths) != 2 {
+		t.Errorf("Event is %+v", e)
+	}
+
+	header.Set("X-GitHub-Event", "ping")
+	if e, err := webhook.Parse(header, []byte(`{"zen": "Keep it logically awesome."}`)); e != nil || err != nil {
+		t.Errorf("Ping event is %+v, error is %v", e, err)
+	}
+
+	header.Set("X-GitHub-Event", "push")
+	if e, _ := webhook.Parse(header, []byte(`{"ref": "refs/heads/old", "deleted": true, "after": "`+zeroCommit+`"}`)); e != nil {
+		t.Errorf("The deleted branch event is %+v", e)
+	}
+	if e, _ := webhook.Parse(header, []byte(`{"ref": "refs/tags/v1.0.0", "after": "abc"}`)); e == nil || e.Event != TagEvent || e.Tag != "v1.0.0" {
+		t.Errorf("Tag event is %+v", e)
+	}
+
+	header.Set("X-GitHub-Event", "pull_request")
+	pr := `{"action": "synchronize", "number": 42, "pull_request": {"head": {"ref": "feature", "sha": "abc"}, "base": {"ref": "master"}}}`
+	if e, _ := webhook.Parse(header, []byte(pr)); e == nil || e.Event != PullRequestEvent || e.PullRequest != 42 ||
+		e.Branch != "master" || e.HeadBranch != "feature" || e.Commit != "abc" {
+		t.Errorf("Pull request event is %+v", e)
+	} else if envs := e.Environments(); envs["CO_GIT_PULL_REQUEST"] != "42" || envs["CO_GIT_COMMIT"] != "abc" {
+		t.Errorf("Environments are %v", envs)
+	}
+	if e, _ := webhook.Parse(header, []byte(`{"action": "closed", "number": 42}`)); e != nil {
+		t.Errorf("Closed pull request event is %+v", e)
+	}
+}
+
+func TestGitLabWebhook(t *testing.T) {
+	webhook := GitWebhooks[GitLabTrigger]
+	header := http.Header{}
+	header.Set("X-Gitlab-Event", "Merge Request Hook")
+	header.Set("X-Gitlab-Token", "token")
+
+	if err := webhook.Verify(header, nil, "token"); err != nil {
+		t.Errorf("Verify error: %s", err.Error())
+	}
+	if err := webhook.Verify(header, nil, "other"); err != ErrInvalidSignature {
+		t.Errorf("Verify with other token error is %v", err)
+	}
+
+	body := `{"object_kind": "merge_request", "user": {"username": "root"}, "project": {"path_with_namespace": "group/project"},
+	  "object_attributes": {"iid": 7, "action": "open", "source_branch": "feature", "target_branch": "master", "last_commit": {"id": "abc"}}}`
+	e, err := webhook.Parse(header, []byte(body))
+	if err != nil || e == nil {
+		t.Fatalf("Parse event %+v error: %v", e, err)
+	}
+	if e.Event != PullRequestEvent || e.PullRequest != 7 || e.Branch != "master" || e.HeadBranch != "feature" ||
+		e.Author != "root" || e.Repository != "group/project" {
+		t.Errorf("Event is %+v", e)
+	}
+
+	header.Set("X-Gitlab-Event", "Tag Push Hook")
+	body = `{"object_kind": "tag_push", "ref": "refs/tags/v2", "checkout_sha": "def", "user_username": "root"}`
+	if e, _ := webhook.Parse(header, []byte(body)); e == nil || e.Event != TagEvent || e.Tag != "v2" || e.Commit != "def" {
+		t.Errorf("Tag event is %+v", e)
+	}
+}
+
+func TestGiteaWebhook(t *testing.T) {
+	webhook := GitWebhooks[GiteaTrigger]
+	header := http.Header{}
+	header.Set("X-Gitea-Event", "push")
+	header.Set("X-Gitea-Signature", sign("secret", githubPush))
+
+	if err := webhook.Verify(header, []byte(githubPush), "secret"); err != nil {
+		t.Errorf("Verify error: %s", err.Error())
+	}
+	if e, _ := webhook.Parse(header, []byte(githubPush)); e == nil || e.Provider != GiteaTrigger || e.Branch != "release/1.0" {
+		t.Errorf("Event is %+v", e)
+	}
+}
+
+func TestTriggerMatch(t *testing.T) {
+	push := &GitEvent{Event: PushEvent, Repository: "Huawei/containerops", Branch: "release/1.0",
+		Paths: []string{"pilotage/module/trigger.go", "README.md"}}
+
+	cases := []struct {
+		trigger Trigger
+		event   *GitEvent
+		want    bool
+	}{
+		{Trigger{}, push, true},
+		{Trigger{Events: []string{TagEvent}}, push, false},
+		{Trigger{Repository: "huawei/containerops"}, push, true},
+		{Trigger{Repository: "Huawei/other"}, push, false},
+		{Trigger{Branches: []string{"master", "release/*"}}, push, true},
+		{Trigger{Branches: []string{"*"}}, push, false},
+		{Trigger{Paths: []string{"pilotage/**"}}, push, true},
+		{Trigger{Paths: []string{"docs/**", "*.go"}}, push, false},
+		{Trigger{Paths: []string{"*.md"}}, push, true},
+		{Trigger{Events: []string{TagEvent}, Tags: []string{"v*"}}, &GitEvent{Event: TagEvent, Tag: "v1.0"}, true},
+		{Trigger{Events: []string{TagEvent}, Tags: []string{"v*"}}, &GitEvent{Event: TagEvent, Tag: "nightly"}, false},
+		{Trigger{Events: []string{PullRequestEvent}, Branches: []string{"master"}, Paths: []string{"docs/**"}},
+			&GitEvent{Event: PullRequestEvent, Branch: "master", HeadBranch: "feature"}, true},
+	}
+
+	for i, c := range cases {
+		if got := c.trigger.Match(c.event); got != c.want {
+			t.Errorf("Case %d: trigger %+v matches %+v is %v, want %v", i, c.trigger, c.event, got, c.want)
+		}
+	}
+}
+
+func TestTriggeredBy(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Hub-Signature-256", "sha256="+sign("secret", githubPush))
+	e := &GitEvent{Event: PushEvent, Branch: "master"}
+
+	f := &Flow{Triggers: []Trigger{{Type: GitLabTrigger, Secret: "secret"}, {Type: GitHubTrigger, Secret: "secret", Branches: []string{"master"}}}}
+	if triggered, err := f.TriggeredBy(GitHubTrigger, header, []byte(githubPush), e); !triggered || err != nil {
+		t.Errorf("Triggered is %v, error is %v", triggered, err)
+	}
+
+	f.Triggers[1].Branches = []string{"develop"}
+	if triggered, err := f.TriggeredBy(GitHubTrigger, header, []byte(githubPush), e); triggered || err != nil {
+		t.Errorf("The unmatched trigger: triggered is %v, error is %v", triggered, err)
+	}
+
+	f.Triggers[1].Secret = "other"
+	if triggered, err := f.TriggeredBy(GitHubTrigger, header, []byte(githubPush), e); triggered || err != ErrInvalidSignature {
+		t.Errorf("The invalid signature: triggered is %v, error is %v", triggered, err)
+	}
+
+	if triggered, err := f.TriggeredBy(GiteaTrigger, header, []byte(githubPush), e); triggered || err != nil {
+		t.Errorf("The flow without gitea trigger: triggered is %v, error is %v", triggered, err)
+	}
+}
+
+func TestValidateTriggers(t *testing.T) {
+	f := newTestFlow("first\n")
+	f.Triggers = []Trigger{
+		{Type: GitHubTrigger, Secret: "secret", Events: []string{PushEvent, TagEvent}, Branches: []string{"release/*"}, Paths: []string{"docs/**"}},
+		{Type: "svn", Events: []string{"commit"}, Tags: []string{"v[1"}},
+	}
+
+	paths := map[string]bool{}
+	for _, e := range f.Validate() {
+		paths[e.Path] = true
+	}
+	for _, path := range []string{"triggers[1].type", "triggers[1].secret", "triggers[1].events[0]", "triggers[1].tags[0]"} {
+		if !paths[path] {
+			t.Errorf("Validation errors %v should contain %s", paths, path)
+		}
+	}
+	for path := range paths {
+		if strings.HasPrefix(path, "triggers[0]") {
+			t.Errorf("The github trigger should be valid, but errors are %v", paths)
+		}
+	}
+}
+
+func TestVerifiedTriggers(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Hub-Signature-256", "sha256="+sign("secret", githubPush))
+
+	f := &Flow{Triggers: []Trigger{{Type: GitHubTrigger, Secret: "other"}, {Type: GitHubTrigger, Secret: "secret", Branches: []string{"master"}}}}
+	if triggers, err := f.VerifiedTriggers(GitHubTrigger, header, []byte(githubPush)); err != nil || len(triggers) != 1 ||
+		triggers[0].Secret != "secret" {
+		t.Errorf("Verified triggers are %+v, error is %v", triggers, err)
+	}
+
+	// The payload isn't verified by the modified body.
+	if triggers, err := f.VerifiedTriggers(GitHubTrigger, header, []byte(githubPush+" ")); err != ErrInvalidSignature {
+		t.Errorf("The modified payload: verified triggers are %+v, error is %v", triggers, err)
+	}
+
+	if triggers, err := f.VerifiedTriggers(GitLabTrigger, header, []byte(githubPush)); err != nil || len(triggers) != 0 {
+		t.Errorf("The flow without gitlab trigger: verified triggers are %+v, error is %v", triggers, err)
+	}
+}
+
+func TestValidName(t *testing.T) {
+	for name, want := range map[string]bool{"containerops": true, "pilotage.v2": true, "my_repo-1": true,
+		"": false, "..": false, ".hidden": false, "a/b": false, "../etc": false} {
+		if got := ValidName(name); got != want {
+			t.Errorf("Valid name %q is %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,589 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	yamlv3 "gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var (
+	subscriptionPattern = regexp.MustCompile(`^([^.\[\]]*)\.([^.\[\]]*)\.(.*)\[([^\[\]]+)\]$`)
+	envNamePattern      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+	dnsLabelPattern     = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+)
+
+// ValidationError is an invalid field of the flow definition, the path is the YAML path of field
+// like stages[1].actions[0].jobs[0].endpoint and the line is zero when the flow isn't parsed from YAML.
+type ValidationError struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", e.Line, e.Path, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationErrors is all the invalid fields of the flow definition.
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	messages := []string{}
+	for _, e := range errs {
+		messages = append(messages, e.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+type validator struct {
+	lines  map[string]int
+	errors ValidationErrors
+}
+
+// add records an error of the path, the line is the one of path or its nearest parent.
+func (v *validator) add(path, format string, a ...interface{}) {
+	line := 0
+	for p := path; p != ""; {
+		if l, ok := v.lines[p]; ok {
+			line = l
+			break
+		}
+		if i := strings.LastIndexAny(p, ".["); i > 0 {
+			p = p[:i]
+		} else {
+			p = ""
+		}
+	}
+	v.errors = append(v.errors, ValidationError{Path: path, Line: line, Message: fmt.Sprintf(format, a...)})
+}
+
+func (v *validator) when(path, expression string) {
+	if _, err := ParseCondition(expression); err != nil {
+		v.add(path, "%s", err.Error())
+	}
+}
+
+func (v *validator) executor(path, name string) {
+	if _, ok := JobExecutors[name]; name == "" || ok {
+		return
+	}
+	if name == LocalExecutor || name == DockerExecutor {
+		v.add(path, "The %s executor is disabled, it's enabled in `[pilotage.daemon]` of daemon", name)
+	} else {
+		v.add(path, "Unknown job executor: %s", name)
+	}
+}
+
+// secrets checks the secret references in `ENV: secret` format.
+func (v *validator) secrets(path string, secrets []map[string]string) {
+	for i, secret := range secrets {
+		for env, name := range secret {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			if !envNamePattern.MatchString(env) {
+				v.add(p, "Invalid environment name: %q", env)
+			}
+			if !ValidSecretName(name) {
+				v.add(p, "Invalid secret name of %s: %q", env, name)
+			}
+		}
+	}
+}
+
+func (v *validator) positive(path string, value int64) {
+	if value < 0 {
+		v.add(path, "Should not be negative: %d", value)
+	}
+}
+
+// Validate checks the flow definition, it returns all the invalid fields instead of the first one.
+func (f *Flow) Validate() ValidationErrors {
+	v := &validator{lines: f.lines}
+
+	if parts := strings.Split(f.URI, "/"); len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		v.add("uri", "The URI should be namespace/repository/name: %q", f.URI)
+	}
+	v.positive("timeout", f.Timeout)
+	v.positive("parallelism", int64(f.Parallelism))
+	v.positive("concurrency", int64(f.Concurrency))
+	v.executor("executor", f.Executor)
+	v.secrets("secrets", f.Secrets)
+	if f.Workspace != nil {
+		f.Workspace.validate(v, "workspace")
+	}
+
+	if len(f.Stages) == 0 {
+		v.add("stages", "The flow has no stage")
+	}
+	if _, err := f.StageDependencies(); err != nil {
+		v.add("stages", "%s", err.Error())
+	}
+
+	// The outputs of jobs, the subscriptions could only subscribe them.
+	outputs := map[string]bool{}
+	for _, stage := range f.Stages {
+		for _, action := range stage.Actions {
+			for _, job := range action.Jobs {
+				jobs := []Job{job}
+				if len(job.Matrix) > 0 {
+					jobs = job.Expand()
+				}
+				for _, j := range jobs {
+					for _, output := range j.Outputs {
+						outputs[fmt.Sprintf("%s.%s.%s[%s]", stage.Name, action.Name, j.Name, strings.TrimSpace(output))] = true
+					}
+				}
+			}
+		}
+	}
+
+	for i, stage := range f.Stages {
+		stage.validate(v, fmt.Sprintf("stages[%d]", i), outputs)
+	}
+
+	// The artifacts are archived from the workspace of flow.
+	for i, stage := range f.Stages {
+		for j, action := range stage.Actions {
+			for k, job := range action.Jobs {
+				if len(job.Artifacts) > 0 && f.Workspace == nil {
+					v.add(fmt.Sprintf("stages[%d].actions[%d].jobs[%d].artifacts", i, j, k), "The artifacts need the workspace of flow")
+				}
+			}
+		}
+	}
+
+	for i, receiver := range f.Receivers {
+		receiver.validate(v, fmt.Sprintf("receivers[%d]", i))
+	}
+
+	for i, trigger := range f.Triggers {
+		trigger.validate(v, fmt.Sprintf("triggers[%d]", i))
+	}
+
+	return v.errors
+}
+
+func (t *Trigger) validate(v *validator, path string) {
+	if t.Type == ScheduleTrigger {
+		if _, err := ParseCron(t.Schedule); err != nil {
+			v.add(path+".schedule", "%s", err.Error())
+		}
+		if t.Missed != "" && t.Missed != MissedSkip && t.Missed != MissedRunOnce {
+			v.add(path+".missed", "Unknown missed run policy: %s", t.Missed)
+		}
+		switch t.Overlap {
+		case "", OverlapAllow, OverlapForbid, OverlapReplace:
+		default:
+			v.add(path+".overlap", "Unknown overlap policy: %s", t.Overlap)
+		}
+		return
+	}
+
+	if _, ok := GitWebhooks[t.Type]; !ok {
+		v.add(path+".type", "Unknown trigger type: %s", t.Type)
+	}
+	if t.Secret == "" {
+		v.add(path+".secret", "The secret verifying the webhook is required")
+	}
+
+	for i, event := range t.Events {
+		switch event {
+		case PushEvent, TagEvent, PullRequestEvent:
+		default:
+			v.add(fmt.Sprintf("%s.events[%d]", path, i), "Unknown trigger event: %s", event)
+		}
+	}
+
+	for name, patterns := range map[string][]string{"branches": t.Branches, "tags": t.Tags, "paths": t.Paths} {
+		for i, pattern := range patterns {
+			if err := checkPattern(pattern); err != nil {
+				v.add(fmt.Sprintf("%s.%s[%d]", path, name, i), "Invalid pattern %q: %s", pattern, err.Error())
+			}
+		}
+	}
+}
+
+func (r *Receiver) validate(v *validator, path string) {
+	if _, ok := Notifiers[r.Type]; !ok {
+		v.add(path+".type", "Unknown receiver type: %s", r.Type)
+	}
+
+	if r.Address == "" {
+		v.add(path+".address", "The receiver address is required")
+	} else if r.Type != "mail" {
+		if u, err := url.Parse(r.Address); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			v.add(path+".address", "The address of %s receiver must be a HTTP URL", r.Type)
+		}
+	}
+
+	for i, on := range r.On {
+		switch on {
+		case Success, Failure, Cancel, Timeout, ChangedTrigger:
+		default:
+			v.add(fmt.Sprintf("%s.on[%d]", path, i), "Unknown notification trigger: %s", on)
+		}
+	}
+
+	if r.Template != "" {
+		if _, err := template.New("notification").Parse(r.Template); err != nil {
+			v.add(path+".template", "Invalid template: %s", err.Error())
+		}
+	}
+}
+
+func (s *Stage) validate(v *validator, path string, outputs map[string]bool) {
+	if s.Name == "" {
+		v.add(path+".name", "The stage name is required")
+	}
+	v.positive(path+".timeout", s.Timeout)
+	v.positive(path+".parallelism", int64(s.Parallelism))
+	v.when(path+".when", s.When)
+
+	switch s.T {
+	case StartStage, EndStage:
+	case PauseStage:
+		if s.TimeoutPolicy != "" && s.TimeoutPolicy != Failure && s.TimeoutPolicy != Cancel {
+			v.add(path+".timeout_policy", "Unknown timeout policy: %s", s.TimeoutPolicy)
+		}
+	case NormalStage:
+		if s.Sequencing != Sequencing && s.Sequencing != Parallel {
+			v.add(path+".sequencing", "Unknown sequencing type: %s", s.Sequencing)
+		}
+		if len(s.Actions) == 0 {
+			v.add(path+".actions", "The normal stage has no action")
+		}
+	default:
+		v.add(path+".type", "Unknown stage type: %s", s.T)
+	}
+
+	if _, err := s.ActionDependencies(); err != nil {
+		v.add(path+".actions", "%s", err.Error())
+	}
+
+	for i, action := range s.Actions {
+		action.validate(v, fmt.Sprintf("%s.actions[%d]", path, i), outputs)
+	}
+
+	for i, receiver := range s.Receivers {
+		receiver.validate(v, fmt.Sprintf("%s.receivers[%d]", path, i))
+	}
+
+	for i, downstream := range s.Downstreams {
+		downstream.validate(v, fmt.Sprintf("%s.downstreams[%d]", path, i), outputs)
+	}
+}
+
+func (a *Action) validate(v *validator, path string, outputs map[string]bool) {
+	if a.Name == "" {
+		v.add(path+".name", "The action name is required")
+	}
+	v.when(path+".when", a.When)
+
+	if len(a.Jobs) == 0 {
+		v.add(path+".jobs", "The action has no job")
+	}
+	for i, job := range a.Jobs {
+		job.validate(v, fmt.Sprintf("%s.jobs[%d]", path, i), outputs)
+	}
+
+	for i, receiver := range a.Receivers {
+		receiver.validate(v, fmt.Sprintf("%s.receivers[%d]", path, i))
+	}
+
+	for i, downstream := range a.Downstreams {
+		downstream.validate(v, fmt.Sprintf("%s.downstreams[%d]", path, i), outputs)
+	}
+}
+
+func (d *Downstream) validate(v *validator, path string, outputs map[string]bool) {
+	if parts := strings.Split(d.URI, "/"); len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		v.add(path+".uri", "The URI should be namespace/repository/name: %q", d.URI)
+	}
+	if d.Tag == "" {
+		v.add(path+".tag", "The tag of downstream flow is required")
+	}
+	v.when(path+".when", d.When)
+
+	for i, subscription := range d.Outputs {
+		for key, env := range subscription {
+			p := fmt.Sprintf("%s.outputs[%d]", path, i)
+			if !subscriptionPattern.MatchString(key) {
+				v.add(p, "The output should be stage.action.job[KEY]: %q", key)
+			} else if !outputs[key] {
+				v.add(p, "No job outputs %s", key)
+			}
+			if !envNamePattern.MatchString(env) {
+				v.add(p, "Invalid environment name of output %s: %q", key, env)
+			}
+		}
+	}
+}
+
+func (r *Resource) validate(v *validator, path string) {
+	requests := map[string]string{"cpu": r.CPU, "memory": r.Memory}
+	for name, quantity := range requests {
+		if quantity == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(quantity); err != nil {
+			v.add(fmt.Sprintf("%s.%s", path, name), "Invalid quantity %q: %s", quantity, err.Error())
+		}
+	}
+	if r.Limits == nil {
+		return
+	}
+
+	if r.Limits.Limits != nil {
+		v.add(path+".limits.limits", "The limits have no limits")
+	}
+	for name, quantity := range map[string]string{"cpu": r.Limits.CPU, "memory": r.Limits.Memory} {
+		if quantity == "" {
+			continue
+		}
+		limit, err := resource.ParseQuantity(quantity)
+		if err != nil {
+			v.add(fmt.Sprintf("%s.limits.%s", path, name), "Invalid quantity %q: %s", quantity, err.Error())
+			continue
+		}
+		if request, err := resource.ParseQuantity(requests[name]); err == nil && limit.Cmp(request) < 0 {
+			v.add(fmt.Sprintf("%s.limits.%s", path, name), "The limit %s is less than the request %s", quantity, requests[name])
+		}
+	}
+}
+
+// volumeMounts checks the mounts reference the volumes of pod, the workspace is mounted without them.
+func (v *validator) volumeMounts(path string, mounts []VolumeMount, volumes map[string]bool) {
+	for i, mount := range mounts {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		if !volumes[mount.Name] {
+			v.add(p+".name", "No volume of pod is named %q", mount.Name)
+		}
+		if !strings.HasPrefix(mount.Path, "/") {
+			v.add(p+".path", "The mount path should be absolute: %q", mount.Path)
+		}
+	}
+}
+
+// validate checks the options of pod and returns the names of its volumes.
+func (p *Pod) validate(v *validator, path string) map[string]bool {
+	volumes := map[string]bool{}
+	for i, volume := range p.Volumes {
+		vp := fmt.Sprintf("%s.volumes[%d]", path, i)
+		switch {
+		case !dnsLabelPattern.MatchString(volume.Name):
+			v.add(vp+".name", "Invalid volume name: %q", volume.Name)
+		case volume.Name == "workspace":
+			v.add(vp+".name", "The volume name workspace is reserved for the workspace of flow")
+		case volumes[volume.Name]:
+			v.add(vp+".name", "Duplicate volume name: %s", volume.Name)
+		default:
+			volumes[volume.Name] = true
+		}
+		if volume.sources() != 1 {
+			v.add(vp, "The volume should have one of empty_dir, host_path, config_map, secret and persistent_volume_claim")
+		}
+		if volume.HostPath != "" && !HostPathVolumes {
+			v.add(vp+".host_path", "The host_path volume is disabled, it's enabled in `[pilotage.daemon]` of daemon")
+		}
+	}
+
+	for i, toleration := range p.Tolerations {
+		tp := fmt.Sprintf("%s.tolerations[%d]", path, i)
+		switch toleration.Operator {
+		case "", "Equal":
+		case "Exists":
+			if toleration.Value != "" {
+				v.add(tp+".value", "The toleration of Exists operator has no value")
+			}
+		default:
+			v.add(tp+".operator", "Unknown toleration operator: %s", toleration.Operator)
+		}
+		switch toleration.Effect {
+		case "", "NoSchedule", "PreferNoSchedule", "NoExecute":
+		default:
+			v.add(tp+".effect", "Unknown taint effect: %s", toleration.Effect)
+		}
+	}
+
+	for i, secret := range p.ImagePullSecrets {
+		if secret == "" {
+			v.add(fmt.Sprintf("%s.image_pull_secrets[%d]", path, i), "The name of image pull secret is required")
+		}
+	}
+
+	names := map[string]bool{}
+	for _, group := range []struct {
+		field      string
+		containers []Container
+	}{{"init_containers", p.InitContainers}, {"services", p.Services}} {
+		for i, container := range group.containers {
+			cp := fmt.Sprintf("%s.%s[%d]", path, group.field, i)
+			if !dnsLabelPattern.MatchString(container.Name) {
+				v.add(cp+".name", "Invalid container name: %q", container.Name)
+			} else if names[container.Name] {
+				v.add(cp+".name", "Duplicate container name: %s", container.Name)
+			} else {
+				names[container.Name] = true
+			}
+			if container.Image == "" {
+				v.add(cp+".image", "The image of container is required")
+			}
+			for _, environment := range container.Environments {
+				for key := range environment {
+					if !envNamePattern.MatchString(key) {
+						v.add(cp+".environments", "Invalid environment name: %q", key)
+					}
+				}
+			}
+			container.Resources.validate(v, cp+".resources")
+			v.volumeMounts(cp+".volume_mounts", container.VolumeMounts, volumes)
+		}
+	}
+	return volumes
+}
+
+func (w *Workspace) validate(v *validator, path string) {
+	if !strings.HasPrefix(w.MountPath(), "/") {
+		v.add(path+".path", "The path of workspace should be absolute: %q", w.Path)
+	}
+	if _, err := resource.ParseQuantity(w.StorageSize()); err != nil {
+		v.add(path+".size", "Invalid quantity %q: %s", w.Size, err.Error())
+	}
+	switch w.Mode() {
+	case "ReadWriteOnce", "ReadWriteMany":
+	default:
+		v.add(path+".access_mode", "Unknown access mode: %s", w.AccessMode)
+	}
+}
+
+func (j *Job) validate(v *validator, path string, outputs map[string]bool) {
+	if j.Endpoint == "" && j.Kubectl == "" {
+		v.add(path+".endpoint", "The endpoint or kubectl of job is required")
+	}
+	if strings.HasPrefix(j.Endpoint, "-") {
+		v.add(path+".endpoint", "The endpoint of job shouldn't begin with `-`: %q", j.Endpoint)
+	}
+	if j.Kubectl != "" && len(j.Artifacts) > 0 {
+		v.add(path+".artifacts", "The kubectl job doesn't mount the workspace to archive the artifacts")
+	}
+	if j.Kubectl != "" && (len(j.Command) > 0 || len(j.Args) > 0 || len(j.VolumeMounts) > 0 || j.Pod != nil) {
+		v.add(path+".pod", "The kubectl job runs in the pod of kubectl image without the pod options")
+	}
+
+	names := map[string]bool{}
+	for i, artifact := range j.Artifacts {
+		p := fmt.Sprintf("%s.artifacts[%d]", path, i)
+		if clean := filepath.Clean(artifact.Path); artifact.Path == "" || filepath.IsAbs(artifact.Path) || clean == ".." ||
+			strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			v.add(p+".path", "The path of artifact should be relative in the workspace: %q", artifact.Path)
+		} else if !ValidName(artifact.ArtifactName()) {
+			v.add(p+".name", "Invalid artifact name: %q", artifact.ArtifactName())
+		} else if names[artifact.ArtifactName()] {
+			v.add(p+".name", "Duplicate artifact name: %s", artifact.ArtifactName())
+		} else {
+			names[artifact.ArtifactName()] = true
+		}
+	}
+	v.executor(path+".executor", j.Executor)
+	v.secrets(path+".secrets", j.Secrets)
+	v.positive(path+".timeout", j.Timeout)
+	v.when(path+".when", j.When)
+
+	j.Resources.validate(v, path+".resources")
+
+	volumes := map[string]bool{}
+	if j.Pod != nil {
+		volumes = j.Pod.validate(v, path+".pod")
+	}
+	v.volumeMounts(path+".volume_mounts", j.VolumeMounts, volumes)
+
+	if j.Retry != nil {
+		if j.Retry.Attempts < 1 {
+			v.add(path+".retry.attempts", "The retry attempts should be greater than 0")
+		}
+		v.positive(path+".retry.backoff", j.Retry.Backoff)
+		v.positive(path+".retry.max_backoff", j.Retry.MaxBackoff)
+	}
+
+	for key, values := range j.Matrix {
+		if !envNamePattern.MatchString(key) {
+			v.add(path+".matrix."+key, "Invalid environment name: %q", key)
+		}
+		if len(values) == 0 {
+			v.add(path+".matrix."+key, "The matrix variable has no value")
+		}
+	}
+
+	for i, subscription := range j.Subscriptions {
+		for key, env := range subscription {
+			p := fmt.Sprintf("%s.subscriptions[%d]", path, i)
+			if !subscriptionPattern.MatchString(key) {
+				v.add(p, "The subscription should be stage.action.job[KEY]: %q", key)
+			} else if !outputs[key] {
+				v.add(p, "No job outputs %s", key)
+			}
+			if env == "" {
+				v.add(p, "The environment name of subscription %s is required", key)
+			}
+		}
+	}
+}
+
+// yamlLines returns the line of every path in the YAML document, the path of a mapping value is
+// joined by . and the path of a sequence item is suffixed with [index].
+func yamlLines(data []byte) map[string]int {
+	lines := map[string]int{}
+
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &document); err != nil || len(document.Content) == 0 {
+		return lines
+	}
+
+	var walk func(node *yamlv3.Node, path string)
+	walk = func(node *yamlv3.Node, path string) {
+		switch node.Kind {
+		case yamlv3.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				p := node.Content[i].Value
+				if path != "" {
+					p = path + "." + p
+				}
+				lines[p] = node.Content[i].Line
+				walk(node.Content[i+1], p)
+			}
+		case yamlv3.SequenceNode:
+			for i, item := range node.Content {
+				p := fmt.Sprintf("%s[%d]", path, i)
+				lines[p] = item.Line
+				walk(item, p)
+			}
+		}
+	}
+	walk(document.Content[0], "")
+
+	return lines
+}
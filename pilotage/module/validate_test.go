@@ -0,0 +1,111 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"testing"
+)
+
+const invalidFlow = `uri: containerops/test
+stages:
+  - type: start
+    name: start
+  - type: normal
+    name: build
+    sequencing: sequence
+    when: a ==
+    actions:
+      - name: compile
+        jobs:
+          - name: go
+            resources:
+              cpu: two
+            subscriptions:
+              - build.compile.unknown[CO_RESULT]: CO_RESULT
+  - type: unknown
+    name: end
+`
+
+func TestValidate(t *testing.T) {
+	newFakeExecutor()
+
+	f := new(Flow)
+	if err := f.ParseFlow([]byte(invalidFlow)); err != nil {
+		t.Fatalf("Parse flow error: %s", err.Error())
+	}
+
+	want := map[string]int{
+		"uri":                                   1,
+		"stages[1].when":                        8,
+		"stages[1].actions[0].jobs[0].endpoint": 12,
+		"stages[1].actions[0].jobs[0].resources.cpu":    14,
+		"stages[1].actions[0].jobs[0].subscriptions[0]": 16,
+		"stages[2].type": 17,
+	}
+
+	errs := f.Validate()
+	for _, e := range errs {
+		line, ok := want[e.Path]
+		if !ok {
+			t.Errorf("Unexpected validation error: %s", e.Error())
+			continue
+		}
+		if e.Line != line {
+			t.Errorf("The line of %s is %d, want %d", e.Path, e.Line, line)
+		}
+		delete(want, e.Path)
+	}
+	for path := range want {
+		t.Errorf("Missing validation error of %s", path)
+	}
+}
+
+func TestValidateValid(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("[COUT] CO_RESULT = first\n", "second\n")
+	f.Stages[1].Actions[0].Jobs[0].Matrix = map[string][]string{"GO": {"1.8"}}
+	f.Stages[2].Actions[0].Jobs[0].Subscriptions = []map[string]string{{"stage0.action0.job0-1.8[CO_RESULT]": "CO_PREVIOUS"}}
+
+	if errs := f.Validate(); len(errs) > 0 {
+		t.Errorf("Validate flow error: %s", errs.Error())
+	}
+}
+
+func TestValidateHostExecutors(t *testing.T) {
+	newFakeExecutor()
+	delete(JobExecutors, LocalExecutor)
+	delete(JobExecutors, DockerExecutor)
+	defer RegisterHostExecutors(true, true)
+
+	f := newTestFlow("--privileged", "second\n")
+	f.Executor = DockerExecutor
+	f.Stages[2].Actions[0].Jobs[0].Executor = LocalExecutor
+
+	paths := map[string]bool{}
+	for _, e := range f.Validate() {
+		paths[e.Path] = true
+	}
+	for _, path := range []string{"executor", "stages[1].actions[0].jobs[0].endpoint", "stages[2].actions[0].jobs[0].executor"} {
+		if !paths[path] {
+			t.Errorf("Missing validation error of %s in %v", path, paths)
+		}
+	}
+	if len(paths) != 3 {
+		t.Errorf("Unexpected validation errors: %v", paths)
+	}
+}
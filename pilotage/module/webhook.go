@@ -0,0 +1,75 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	WebhookEventHeader     = "X-Pilotage-Event"
+	WebhookSignatureHeader = "X-Pilotage-Signature"
+)
+
+func init() {
+	Register("webhook", &WebhookNotifier{})
+}
+
+// WebhookNotifier posts the notification and message as JSON to the address of receiver, the header
+// `X-Pilotage-Event` is the event of notification. When the receiver has
+// the secret, the body is signed with HMAC-SHA256 in the header `X-Pilotage-Signature: sha256=<hex digest>`.
+type WebhookNotifier struct {
+}
+
+// WebhookPayload is the body of webhook notification.
+type WebhookPayload struct {
+	Notification
+	Message string `json:"message"`
+}
+
+func (w *WebhookNotifier) Notify(flow *Flow, n Notification, receiver Receiver) error {
+	message, err := receiver.Message(n)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(WebhookPayload{Notification: n, Message: message})
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set(WebhookEventHeader, n.Event)
+	if receiver.Secret != "" {
+		header.Set(WebhookSignatureHeader, WebhookSignature(receiver.Secret, body))
+	}
+
+	_, err = postHTTP(notifyClient, receiver.Address, "application/json", body, header)
+	return err
+}
+
+// WebhookSignature returns the HMAC-SHA256 signature of body, the receiver of webhook verifies the body with it.
+func WebhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return fmt.Sprintf("sha256=%s", hex.EncodeToString(mac.Sum(nil)))
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"fmt"
+
+	"github.com/Huawei/containerops/common/utils"
+	"github.com/Huawei/containerops/pilotage/model"
+)
+
+const (
+	// The defaults of workspace
+	DefaultWorkspacePath       = "/workspace"
+	DefaultWorkspaceSize       = "1Gi"
+	DefaultWorkspaceAccessMode = "ReadWriteOnce"
+)
+
+// Workspace is a volume shared by the jobs of a flow run, it's mounted at the path of every job container and
+// the CO_WORKSPACE environment is the path. The Kubernetes executor provisions a PersistentVolumeClaim, the Docker
+// executor creates a volume and the local executor uses a temporary directory, the workspace is deleted after
+// the run finished.
+type Workspace struct {
+	Path         string `json:"path,omitempty" yaml:"path,omitempty"`
+	Size         string `json:"size,omitempty" yaml:"size,omitempty"`
+	StorageClass string `json:"storage_class,omitempty" yaml:"storage_class,omitempty"`
+	AccessMode   string `json:"access_mode,omitempty" yaml:"access_mode,omitempty"`
+}
+
+// MountPath returns the path of workspace in the job containers.
+func (w *Workspace) MountPath() string {
+	if w.Path == "" {
+		return DefaultWorkspacePath
+	}
+	return w.Path
+}
+
+// StorageSize returns the requested size of the PersistentVolumeClaim.
+func (w *Workspace) StorageSize() string {
+	if w.Size == "" {
+		return DefaultWorkspaceSize
+	}
+	return w.Size
+}
+
+// Mode returns the access mode of the PersistentVolumeClaim, the jobs running in parallel on
+// different nodes need ReadWriteMany.
+func (w *Workspace) Mode() string {
+	if w.AccessMode == "" {
+		return DefaultWorkspaceAccessMode
+	}
+	return w.AccessMode
+}
+
+// CreateWorkspace creates the workspace of flow by the executors of its jobs before the stages run,
+// the kubectl jobs don't mount the workspace.
+func (f *Flow) CreateWorkspace(verbose, timestamp bool) error {
+	if f.Workspace == nil {
+		return nil
+	}
+
+	f.workspace = fmt.Sprintf("workspace-%s", utils.RandomString(10))
+	for _, name := range f.workspaceExecutors() {
+		provider, ok := JobExecutors[name].(WorkspaceProvider)
+		if !ok {
+			return fmt.Errorf("Job executor %s doesn't support the workspace", name)
+		}
+
+		f.emit(Event{Type: WorkspaceCreated, Pod: f.workspace, Executor: name})
+		if err := provider.CreateWorkspace(f, f.workspace); err != nil {
+			return fmt.Errorf("Create %s workspace %s error: %s", name, f.workspace, err.Error())
+		}
+		f.workspaces = append(f.workspaces, name)
+		f.Log(fmt.Sprintf("Flow [%s] %s workspace %s is created", f.URI, name, f.workspace), verbose, timestamp)
+	}
+
+	return nil
+}
+
+// DeleteWorkspace deletes the workspace of flow created by the executors after the run finished.
+func (f *Flow) DeleteWorkspace(verbose, timestamp bool) {
+	for _, name := range f.workspaces {
+		if err := JobExecutors[name].(WorkspaceProvider).DeleteWorkspace(f, f.workspace); err != nil {
+			f.LogLevel(model.ERROR, fmt.Sprintf("Delete %s workspace %s error: %s", name, f.workspace, err.Error()), verbose, timestamp)
+		}
+	}
+	f.workspaces = nil
+}
+
+// workspaceExecutors returns the executors of the jobs mounting the workspace.
+func (f *Flow) workspaceExecutors() []string {
+	names, executors := map[string]bool{}, []string{}
+	for _, stage := range f.Stages {
+		for _, action := range stage.Actions {
+			for _, job := range action.Jobs {
+				if job.Kubectl != "" || names[job.executorName(f)] {
+					continue
+				}
+				names[job.executorName(f)] = true
+				executors = append(executors, job.executorName(f))
+			}
+		}
+	}
+	return executors
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2016 - 2017 Huawei Technologies Co., Ltd. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Huawei/containerops/pilotage/config"
+)
+
+// dockyardServer records the files of artifacts uploaded to the binary repository by URL path.
+type dockyardServer struct {
+	*httptest.Server
+
+	lock  sync.Mutex
+	files map[string]map[string]string
+}
+
+func newDockyardServer(status int) *dockyardServer {
+	d := &dockyardServer{files: map[string]map[string]string{}}
+	d.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		files := map[string]string{}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tr := tar.NewReader(zr)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			content, _ := ioutil.ReadAll(tr)
+			files[header.Name] = string(content)
+		}
+
+		d.lock.Lock()
+		d.files[r.URL.Path] = files
+		d.lock.Unlock()
+		w.WriteHeader(status)
+	}))
+
+	dockyard := config.Pilotage.Artifact.Dockyard
+	config.Pilotage.Artifact.Dockyard = d.URL
+	d.Config.RegisterOnShutdown(func() { config.Pilotage.Artifact.Dockyard = dockyard })
+	return d
+}
+
+func newWorkspaceFlow(endpoints ...string) *Flow {
+	f := newTestFlow(endpoints...)
+	f.Executor, f.Workspace = LocalExecutor, &Workspace{}
+	return f
+}
+
+func TestLocalWorkspace(t *testing.T) {
+	newFakeExecutor()
+	dockyard := newDockyardServer(http.StatusOK)
+	defer dockyard.Close()
+
+	f := newWorkspaceFlow(`mkdir -p out && echo hello > out/result.txt && echo "[COUT] CO_RESULT = $CO_WORKSPACE"`, "cat out/result.txt")
+	f.Stages[1].Actions[0].Jobs[0].Artifacts = []Artifact{{Path: "out"}, {Name: "result", Path: "out/result.txt"}}
+	if err := f.LocalRun(context.Background(), false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+	if f.Status != Success {
+		t.Fatalf("Flow status is %s, want %s", f.Status, Success)
+	}
+
+	workspace, _ := f.GetOutputs().Get("stage0.action0.job0[CO_RESULT]")
+	if workspace != localWorkspace(f.workspace) {
+		t.Errorf("CO_WORKSPACE is %q, want %q", workspace, localWorkspace(f.workspace))
+	}
+	if _, err := os.Stat(workspace); !os.IsNotExist(err) {
+		t.Errorf("Workspace %s isn't deleted after the run", workspace)
+	}
+	if logs := strings.Join(f.Stages[2].Actions[0].Jobs[0].Logs, ""); !strings.Contains(logs, "hello") {
+		t.Errorf("The second job doesn't read the file of first job in workspace: %s", logs)
+	}
+
+	if artifacts := f.Stages[1].Actions[0].Jobs[0].ArchivedArtifacts(); len(artifacts) != 2 {
+		t.Errorf("The archived artifacts are %v, want out and result", artifacts)
+	}
+
+	want := map[string]map[string]string{
+		"stage0.action0.job0.out.tar.gz":    {"out/": "", "out/result.txt": "hello\n"},
+		"stage0.action0.job0.result.tar.gz": {"out/result.txt": "hello\n"},
+	}
+	for i, artifact := range f.Stages[1].Actions[0].Jobs[0].Artifacts {
+		binary := fmt.Sprintf("/binary/v1/containerops/test/binary/flow-latest-%d/stage0.action0.job0.%s.tar.gz", f.Number, artifact.ArtifactName())
+		if artifact.URL != dockyard.URL+binary {
+			t.Errorf("The URL of artifact %d is %s, want %s", i, artifact.URL, dockyard.URL+binary)
+		}
+
+		files := dockyard.files[binary]
+		if fmt.Sprint(files) != fmt.Sprint(want[binary[strings.LastIndex(binary, "/")+1:]]) {
+			t.Errorf("The files of artifact %s are %v", artifact.ArtifactName(), files)
+		}
+	}
+}
+
+func TestArchiveArtifactsFailure(t *testing.T) {
+	newFakeExecutor()
+	dockyard := newDockyardServer(http.StatusBadRequest)
+	defer dockyard.Close()
+
+	f := newWorkspaceFlow("echo hello > result.txt")
+	f.Stages[1].Actions[0].Jobs[0].Artifacts = []Artifact{{Path: "result.txt"}}
+	if err := f.LocalRun(context.Background(), false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+
+	if status := f.Stages[1].Actions[0].Jobs[0].Status; status != Failure {
+		t.Errorf("Job status is %s when the artifact isn't archived, want %s", status, Failure)
+	}
+	if url := f.Stages[1].Actions[0].Jobs[0].Artifacts[0].URL; url != "" {
+		t.Errorf("The URL of artifact not archived is %s", url)
+	}
+}
+
+func TestWorkspaceUnsupported(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("first\n")
+	f.Workspace = &Workspace{}
+	if err := f.LocalRun(context.Background(), false, false); err != nil {
+		t.Fatalf("Run flow error: %s", err.Error())
+	}
+	if f.Status != Failure {
+		t.Errorf("Flow status is %s when the executor doesn't support workspace, want %s", f.Status, Failure)
+	}
+}
+
+func TestValidateWorkspace(t *testing.T) {
+	newFakeExecutor()
+
+	f := newTestFlow("first\n", "second\n")
+	f.Stages[1].Actions[0].Jobs[0].Artifacts = []Artifact{{Path: "out"}}
+	paths := map[string]bool{}
+	for _, e := range f.Validate() {
+		paths[e.Path] = true
+	}
+	if !paths["stages[1].actions[0].jobs[0].artifacts"] {
+		t.Errorf("Missing validation error of artifacts without workspace in %v", paths)
+	}
+
+	f.Workspace = &Workspace{Path: "workspace", Size: "large", AccessMode: "ReadOnlyMany"}
+	f.Stages[2].Actions[0].Jobs[0].Artifacts = []Artifact{{Path: "../out"}, {Path: "bin/out"}, {Path: "out"},
+		{Name: "cache", Path: "..cache"}, {Path: "out/../.."}}
+	paths = map[string]bool{}
+	for _, e := range f.Validate() {
+		paths[e.Path] = true
+	}
+	for _, path := range []string{"workspace.path", "workspace.size", "workspace.access_mode",
+		"stages[2].actions[0].jobs[0].artifacts[0].path", "stages[2].actions[0].jobs[0].artifacts[2].name",
+		"stages[2].actions[0].jobs[0].artifacts[4].path"} {
+		if !paths[path] {
+			t.Errorf("Missing validation error of %s in %v", path, paths)
+		}
+	}
+	if len(paths) != 6 {
+		t.Errorf("Unexpected validation errors: %v", paths)
+	}
+}
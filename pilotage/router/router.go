@@ -27,6 +27,12 @@ func SetRunDaemonRouters(m *macaron.Macaron) {
 	m.Group("/flow", func() {
 		m.Group("/v1", func() {
 			m.Get("/:namespace/:repository/:flow/:tag/:number/runtime/:type", handler.GetFlowRuntime)
+			m.Post("/:namespace/:repository/:flow/:tag/:number/resume", handler.PostFlowResume)
+			m.Delete("/:namespace/:repository/:flow/:tag/:number", handler.DeleteFlowRuntime)
+			m.Get("/:namespace/:repository/:flow/:tag/:number/outputs", handler.GetFlowOutputs)
+			m.Get("/:namespace/:repository/:flow/:tag/:number/logs", handler.GetFlowLogStream)
+			m.Get("/:namespace/:repository/:flow/:tag/:number/logs/:job", handler.GetFlowLogStream)
+			m.Get("/:namespace/:repository/:flow/:tag/:number/events", handler.GetFlowEventStream)
 		})
 	})
 }
@@ -36,12 +42,48 @@ func SetStartDaemonRouters(m *macaron.Macaron) {
 	m.Group("/flow", func() {
 		m.Group("/v1", func() {
 			m.Post("/:namespace/:repository/:flow/:tag/:type", handler.PostFlowRuntime)
+			m.Post("/:namespace/:repository/:flow/:tag/:number/resume", handler.PostFlowResume)
+			m.Delete("/:namespace/:repository/:flow/:tag/:number", handler.DeleteFlowRuntime)
+			m.Get("/:namespace/:repository/:flow/:tag/:number/outputs", handler.GetFlowOutputs)
+			m.Get("/:namespace/:repository/:flow/:tag/:number/logs", handler.GetFlowLogStream)
+			m.Get("/:namespace/:repository/:flow/:tag/:number/logs/:job", handler.GetFlowLogStream)
+			m.Get("/:namespace/:repository/:flow/:tag/:number/events", handler.GetFlowEventStream)
+
+			m.Get("/:namespace", handler.GetFlows)
+			m.Get("/:namespace/:repository", handler.GetFlows)
+			m.Get("/:namespace/:repository/:flow/:tag/runs", handler.GetFlowRuns)
+			m.Get("/:namespace/:repository/:flow/:tag/runs/:number", handler.GetFlowRun)
+			m.Get("/:namespace/:repository/:flow/:tag/runs/:number/jobs/:job/log", handler.GetFlowJobLog)
+			m.Get("/:namespace/:repository/:flow/:tag/schedules", handler.GetSchedules)
+			m.Get("/:namespace/:repository/:flow/:tag/queue", handler.GetQueuedRuns)
+		})
+	})
+
+	m.Group("/schedule", func() {
+		m.Group("/v1", func() {
+			m.Get("", handler.GetSchedules)
+		})
+	})
+
+	m.Group("/secret", func() {
+		m.Group("/v1", func() {
+			m.Get("/:namespace", handler.GetSecrets)
+			m.Put("/:namespace/:name", handler.PutSecret)
+			m.Delete("/:namespace/:name", handler.DeleteSecret)
+		})
+	})
+
+	m.Group("/queue", func() {
+		m.Group("/v1", func() {
+			m.Get("", handler.GetQueuedRuns)
+			m.Delete("/:id", handler.DeleteQueuedRun)
 		})
 	})
 
 	m.Group("/hook", func() {
 		m.Group("/v1", func() {
 			m.Post("/:namespace/:repository/:flow/:tag", handler.WebHook)
+			m.Post("/:provider/:namespace/:repository", handler.GitWebHook)
 		})
 	})
 }